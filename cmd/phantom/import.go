@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"phantom/internal/secrets"
+	"phantom/internal/ui/tabs/http"
+)
+
+const importUsage = "usage: phantom import postman [-o out.http] [-f] [-s secrets file] <file>"
+
+// runImport implements `phantom import postman <file>`, converting a Postman
+// collection into a .http file that phantom loads on start, or adding a
+// Postman environment to the project's named environments.
+func runImport(args []string) error {
+	if len(args) == 0 || args[0] != "postman" {
+		return errors.New(importUsage)
+	}
+	fs := flag.NewFlagSet("import postman", flag.ContinueOnError)
+	out := fs.String("o", "", "output .http file of a collection (default: named after the collection)")
+	force := fs.Bool("f", false, "overwrite the output file if it exists")
+	secretsFile := fs.String("s", secrets.DefaultVaultFile, "secrets file to store the secret values of an environment in")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New(importUsage)
+	}
+
+	written, err := http.ImportPostmanFile(fs.Arg(0), *out, *force, func() (*secrets.Vault, error) {
+		_, statErr := os.Stat(*secretsFile)
+		pass, err := readPassphrase(errors.Is(statErr, os.ErrNotExist))
+		if err != nil {
+			return nil, err
+		}
+		return secrets.OpenVault(*secretsFile, pass)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %s into %s\n", fs.Arg(0), written)
+	return nil
+}
@@ -5,12 +5,22 @@ import (
 	"log"
 	"os"
 
+	"phantom/internal/secrets"
 	"phantom/internal/ui"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func main() {
+	// Subcommands run without the TUI
+	if len(os.Args) > 1 {
+		if err := run(os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, "phantom:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Setup logging
 	f, err := tea.LogToFile("debug.log", "debug")
 	if err != nil {
@@ -18,6 +28,7 @@ func main() {
 		os.Exit(1)
 	}
 	defer f.Close()
+	log.SetOutput(secrets.RedactWriter(f)) // resolved secrets never reach debug.log
 
 	// Check for config file
 	if _, err := os.Stat("config.lua"); os.IsNotExist(err) {
@@ -31,3 +42,16 @@ func main() {
 		log.Fatalf("Error running program: %v", err)
 	}
 }
+
+// run dispatches a subcommand.
+func run(args []string) error {
+	switch args[0] {
+	case "import":
+		return runImport(args[1:])
+	case "run":
+		return runRun(args[1:])
+	case "secrets":
+		return runSecrets(args[1:])
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"phantom/internal/config"
+	"phantom/internal/secrets"
+	"phantom/internal/ui/tabs/http"
+)
+
+const runUsage = `usage: phantom run [flags] [request name...]
+
+Runs the requests of config.lua, the project's .http files and its OpenAPI
+spec, checks their assertions and exits with status 1 if any of them fail.
+
+Flags:`
+
+// runRun implements `phantom run`, the headless collection runner for CI.
+func runRun(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), runUsage)
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("c", config.DefaultFile, "Lua configuration to load")
+	collection := fs.String("collection", "", `only run this collection: a request group, a .http file name, "config" or "openapi"`)
+	junitPath := fs.String("junit", "", "write a JUnit XML report to this file")
+	jsonPath := fs.String("json", "", "write a JSON report to this file")
+	envName := fs.String("env", "", "named environment of config.lua, or imported from Postman, to use (default: its active_environment)")
+	verbose := fs.Bool("v", false, "show every check, not only failed ones")
+	vars := map[string]string{}
+	fs.Func("var", "set an environment variable, as name=value (repeatable)", func(s string) error {
+		k, v, ok := strings.Cut(s, "=")
+		if !ok {
+			return errors.New("expected name=value")
+		}
+		vars[k] = v
+		return nil
+	})
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	log.SetFlags(0)
+	log.SetPrefix("phantom: ")
+	log.SetOutput(secrets.RedactWriter(os.Stderr))
+	out := secrets.RedactWriter(os.Stdout)
+
+	sources, env, secretsFile, err := loadRunCollections(*configPath, *envName, isFlagSet(fs, "c"))
+	if err != nil {
+		return err
+	}
+	for k, v := range vars {
+		env[k] = v
+	}
+	if err := resolveSecrets(env, secretsFile); err != nil {
+		return err
+	}
+	items := selectRequests(sources, *collection, fs.Args())
+	if len(items) == 0 {
+		return errors.New("no requests to run")
+	}
+
+	name := *collection
+	if name == "" {
+		name = "phantom"
+	}
+	start := time.Now()
+	var cases []http.TestCase
+	for _, item := range items {
+		c := http.RunRequest(context.Background(), item, env)
+		for k, v := range c.Captured { // later requests see captured values
+			env[k] = v
+		}
+		printCase(out, c, *verbose)
+		cases = append(cases, c)
+	}
+	passed, failed := http.SummarizeCases(cases)
+	fmt.Fprintf(out, "\n%d passed, %d failed in %s\n", passed, failed, time.Since(start).Round(time.Millisecond))
+
+	if *junitPath != "" {
+		if err := writeReport(*junitPath, func(w io.Writer) error { return http.WriteJUnit(w, name, start, cases) }); err != nil {
+			return err
+		}
+	}
+	if *jsonPath != "" {
+		if err := writeReport(*jsonPath, func(w io.Writer) error { return http.WriteJSONReport(w, name, start, cases) }); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d requests failed", failed, len(cases))
+	}
+	return nil
+}
+
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) { set = set || f.Name == name })
+	return set
+}
+
+// runSource is where a request of the run came from, for -collection.
+type runSource struct {
+	item http.RequestItem
+	from string // "config", "openapi" or the .http file name without extension
+}
+
+// loadRunCollections gathers the requests and variables the TUI would show,
+// with the variables of the named environment envName (or the configured
+// active one) on top, and the timeouts of config.lua applied. A missing
+// config file is only an error when it was asked for explicitly. It also
+// returns the secrets file to resolve ${secret:name} references from.
+func loadRunCollections(configPath, envName string, required bool) ([]runSource, map[string]string, string, error) {
+	cfg := config.ConfigLoadedMsg{Timeouts: http.DefaultTimeouts}
+	if _, err := os.Stat(configPath); err == nil || required {
+		if cfg, err = config.LoadConfigFile(configPath); err != nil {
+			return nil, nil, "", fmt.Errorf("loading %s: %w", configPath, err)
+		}
+	}
+
+	var specItems []runSource
+	env := map[string]string{}
+
+	specPath := http.FindOpenAPISpec(".")
+	if cfg.OpenAPI != "" {
+		specPath = cfg.OpenAPI
+		if !filepath.IsAbs(specPath) {
+			specPath = filepath.Join(filepath.Dir(configPath), specPath)
+		}
+	}
+	if specPath != "" {
+		spec, err := http.ParseOpenAPI(specPath)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		for _, v := range spec.Variables {
+			env[v.Name] = v.Value
+		}
+		for _, r := range spec.Requests {
+			specItems = append(specItems, runSource{r, "openapi"})
+		}
+	}
+
+	paths, err := http.FindHTTPFiles(".")
+	if err != nil {
+		return nil, nil, "", err
+	}
+	var fileItems []runSource
+	for _, p := range paths {
+		f, err := http.ParseHTTPFile(p)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		for _, v := range f.Variables {
+			env[v.Name] = v.Value
+		}
+		base := strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+		for _, r := range f.Requests {
+			fileItems = append(fileItems, runSource{r, base})
+		}
+	}
+
+	for k, v := range cfg.Environment {
+		env[k] = v
+	}
+	if envName == "" {
+		envName = cfg.ActiveEnvironment
+	}
+	envs := cfg.Environments
+	if store, err := http.NewEnvironmentStore(); err == nil {
+		imported, err := store.Load()
+		if err != nil {
+			return nil, nil, "", err
+		}
+		envs = http.MergeEnvironments(envs, imported)
+	}
+	if i := http.FindEnvironment(envs, envName); i >= 0 {
+		named := envs[i]
+		for k, v := range named.Vars {
+			env[k] = v
+		}
+		if named.Production() {
+			log.Printf("running against %s, a production environment", named.Name)
+		}
+	} else if envName != "" {
+		return nil, nil, "", fmt.Errorf("no environment called %q in %s or imported", envName, configPath)
+	}
+	// Same order as the collections list: config templates, .http files, spec.
+	var ordered []runSource
+	for _, t := range cfg.Templates {
+		if r, ok := t.(http.RequestItem); ok {
+			ordered = append(ordered, runSource{r, "config"})
+		}
+	}
+	ordered = append(ordered, fileItems...)
+	ordered = append(ordered, specItems...)
+	for i := range ordered {
+		ordered[i].item = cfg.Timeouts.Apply(ordered[i].item)
+	}
+	secretsFile := secrets.DefaultVaultFile
+	if cfg.SecretsFile != "" {
+		secretsFile = cfg.SecretsFile
+	}
+	if !filepath.IsAbs(secretsFile) {
+		secretsFile = filepath.Join(filepath.Dir(configPath), secretsFile)
+	}
+	return ordered, env, secretsFile, nil
+}
+
+// selectRequests narrows the run to a collection and to requests by name.
+func selectRequests(sources []runSource, collection string, names []string) []http.RequestItem {
+	var items []http.RequestItem
+	for _, s := range sources {
+		if s.item.Method == http.MethodWS { // connections are interactive
+			continue
+		}
+		if collection != "" && !strings.EqualFold(s.from, collection) &&
+			!strings.EqualFold(s.item.Group, collection) && !strings.HasPrefix(s.item.Group, collection+"/") {
+			continue
+		}
+		if len(names) > 0 && !containsFold(names, s.item.Name) {
+			continue
+		}
+		items = append(items, s.item)
+	}
+	return items
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func printCase(w io.Writer, c http.TestCase, verbose bool) {
+	mark := "✓"
+	if !c.Passed() {
+		mark = "✗"
+	}
+	outcome := fmt.Sprintf("%d · %s", c.Status, c.Duration.Round(time.Millisecond))
+	if c.Err != nil {
+		outcome = "error: " + c.Err.Error()
+	}
+	name := c.Request.Name
+	if c.Request.Group != "" {
+		name = c.Request.Group + " › " + name
+	}
+	fmt.Fprintf(w, "%s %s %s  %s\n", mark, c.Request.Method, name, outcome)
+	for _, r := range c.Results {
+		if verbose || !r.Passed {
+			line := "✓ " + r.Name
+			if !r.Passed {
+				line = "✗ " + r.Name + ": " + r.Message
+			}
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+	}
+}
+
+func writeReport(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"phantom/internal/secrets"
+
+	"github.com/charmbracelet/x/term"
+)
+
+const secretsUsage = `usage: phantom secrets [-f file] list
+       phantom secrets [-f file] set <name> [value]
+       phantom secrets [-f file] rm <name>
+
+Manages the passphrase-encrypted secrets file that ${secret:name}
+references in config.lua are read from. The passphrase is taken from
+$` + secrets.PassphraseEnv + ` or asked for. Without a value, set reads it
+from the terminal or stdin.`
+
+// runSecrets implements `phantom secrets`.
+func runSecrets(args []string) error {
+	fs := flag.NewFlagSet("secrets", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprintln(fs.Output(), secretsUsage) }
+	path := fs.String("f", secrets.DefaultVaultFile, "secrets file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) == 0 {
+		return errors.New(secretsUsage)
+	}
+
+	_, statErr := os.Stat(*path)
+	creating := errors.Is(statErr, os.ErrNotExist)
+	if creating && args[0] != "set" {
+		return fmt.Errorf("%s does not exist yet; add a secret with `phantom secrets set`", *path)
+	}
+	pass, err := readPassphrase(creating)
+	if err != nil {
+		return err
+	}
+	vault, err := secrets.OpenVault(*path, pass)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		for _, name := range vault.Names() {
+			fmt.Println(name)
+		}
+		return nil
+	case args[0] == "set" && (len(args) == 2 || len(args) == 3):
+		value := ""
+		if len(args) == 3 {
+			value = args[2]
+		} else if value, err = readSecret("Value for " + args[1] + ": "); err != nil {
+			return err
+		}
+		vault.Set(args[1], value)
+	case args[0] == "rm" && len(args) == 2:
+		if _, ok := vault.Get(args[1]); !ok {
+			return fmt.Errorf("no secret called %q", args[1])
+		}
+		vault.Delete(args[1])
+	default:
+		return errors.New(secretsUsage)
+	}
+	return vault.Save()
+}
+
+// readPassphrase returns the secrets file passphrase from the environment
+// or the terminal. A new passphrase is asked for twice.
+func readPassphrase(confirm bool) (string, error) {
+	if pass, ok := os.LookupEnv(secrets.PassphraseEnv); ok {
+		return pass, nil
+	}
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return "", fmt.Errorf("set %s to unlock the secrets file", secrets.PassphraseEnv)
+	}
+	pass, err := readSecret("Secrets passphrase: ")
+	if err != nil || !confirm {
+		return pass, err
+	}
+	if pass == "" {
+		return "", errors.New("empty passphrase")
+	}
+	again, err := readSecret("Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if again != pass {
+		return "", errors.New("passphrases do not match")
+	}
+	return pass, nil
+}
+
+// readSecret reads a line without echo from the terminal, or from stdin
+// when it is not a terminal.
+func readSecret(prompt string) (string, error) {
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	b, err := term.ReadPassword(os.Stdin.Fd())
+	fmt.Fprintln(os.Stderr)
+	return string(b), err
+}
+
+// resolveSecrets expands the secret references of env, unlocking the
+// secrets file at vaultPath if any of them need it.
+func resolveSecrets(env map[string]string, vaultPath string) error {
+	var vault *secrets.Vault
+	for _, v := range env {
+		if secrets.NeedsVault(v) {
+			pass, err := readPassphrase(false)
+			if err != nil {
+				return err
+			}
+			if vault, err = secrets.OpenVault(vaultPath, pass); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	var problems []string
+	for k, v := range env {
+		if !secrets.HasRefs(v) {
+			continue
+		}
+		resolved, err := secrets.Expand(v, vault)
+		if err != nil {
+			problems = append(problems, k+": "+err.Error())
+		}
+		env[k] = resolved
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -11,8 +11,11 @@
 --]]
 
 Config = {
-    -- Define the layout of panels. Max 4 panels in a 2x2 grid for now.
-    -- Available panel types: "workspace", "system", "http", and any custom panels you register.
+    -- Define the layout of panels, shown in the "Layout" tab.
+    -- Available panel types: "workspace", "system", "http", any other tab name, and any custom panels you register.
+    -- Besides the 2x2 grid below, arbitrary splits are supported, e.g.:
+    --   layout = { rows = { { "system", { panel = "Clock", size = 2 } }, "http" } }
+    -- A list nested inside `rows` is laid out as columns (and vice versa); `size` sets a relative weight.
     layout = {
         top_left = "workspace",
         top_right = "system",
@@ -20,23 +23,50 @@ Config = {
         bottom_right = "Clock" -- This is a custom panel defined below
     },
 
-    -- Define custom shell commands that can be run from the workspace panel
-    -- This feature is planned for a future update.
+    -- Define custom shell commands that can be run from the Tasks tab ("workspace" in the layout).
+    -- Each command runs through `sh -c`; output streams live and the last runs are kept per task.
     commands = {
         { name = "test", command = "go test ./..." },
         { name = "run", command = "go run ." },
         { name = "build", command = "go build -o phantom" }
     },
 
+    -- Defaults of the gRPC tab. Services come from server reflection (Alt+R) and the
+    -- project's .proto files, whose imports are resolved against import_paths.
+    -- grpc = {
+    --     address = "localhost:50051",
+    --     metadata = "authorization: Bearer {{auth_token}}",
+    --     import_paths = { "proto" },
+    -- },
+
     -- Pre-defined HTTP request templates for the HTTP panel
      http = {
+        -- Generate requests from an OpenAPI 3 spec (openapi.yaml/json in the project is found automatically)
+        -- openapi = "api/spec.yaml",
+
+        -- Limits for every request, as durations or milliseconds (0: none); templates
+        -- can set their own timeout and connect_timeout
+        -- timeout = "1m",
+        -- connect_timeout = "10s",
+
         -- Environment variables can be used in requests with {{variable_name}}
         environment = {
             base_url = "https://jsonplaceholder.typicode.com",
             reqres_url = "https://reqres.in/api",
-            auth_token = "Bearer your_jwt_token_here"
+            -- Secrets are referenced rather than written here: ${env:NAME}, ${dotenv:NAME}
+            -- or ${secret:name} from the encrypted file managed with `phantom secrets`
+            auth_token = "Bearer ${env:API_TOKEN}"
         },
 
+        -- Named environments override the variables above; switch with Alt+N.
+        -- Environments tagged "production" are shown in a warning style.
+        -- environments = {
+        --     { name = "local", vars = { base_url = "http://localhost:8080" } },
+        --     { name = "staging", vars = { base_url = "https://staging.example.com" } },
+        --     { name = "prod", tags = { "production" }, vars = { base_url = "https://api.example.com" } }
+        -- },
+        -- active_environment = "local",
+
         -- A collection of pre-defined request templates
         templates = {
             {
@@ -51,13 +81,30 @@ Config = {
                 method = "GET",
                 url = "{{base_url}}/posts/1",
                 headers = "",
-                body = ""
+                body = "",
+                -- Checked on every response; Alt+R runs the whole collection
+                assert = {
+                    status = 200,
+                    headers = { ["Content-Type"] = "application/json" },
+                    json = { ["$.id"] = 1 },
+                    max_time = 2000 -- ms
+                },
+                -- Scripted checks: raise an error, return false, or return named results
+                test = function(res)
+                    return { ["has a title"] = res.json.title ~= nil }
+                end,
+                -- Stored as {{post_title}} for later requests
+                capture = { post_title = "$.title" }
             },
             {
                 name = "Create a Post",
                 method = "POST",
                 url = "{{base_url}}/posts",
+                -- Credentials for the request: basic, bearer, apikey, digest or oauth2
+                -- auth = "bearer {{token}}",
                 headers = 'Content-Type: application/json; charset=UTF-8',
+                -- raw (default), json, xml, text, form, multipart or file
+                -- body_mode = "json",
                 body = [[
 {
     "title": "foo",
@@ -93,7 +140,13 @@ Config = {
                 url = "https://api.example.com/me",
                 headers = "Authorization: {{auth_token}}",
                 body = ""
-            }
+            },
+            -- {
+            --     name = "Echo Socket",
+            --     method = "WS",
+            --     url = "wss://echo.example.com/socket",
+            --     messages = { '{"type": "ping"}' }
+            -- }
         }
     }
 }
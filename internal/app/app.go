@@ -1,7 +1,11 @@
 package app
 
 import (
+	"crypto/sha1"
+	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -19,3 +23,34 @@ type CheckBinaryMsg struct {
 	AppName string
 	Found   bool
 }
+
+// DataDir returns the directory phantom stores its data in, creating it if needed.
+// It follows XDG_DATA_HOME and defaults to ~/.local/share/phantom.
+func DataDir() (string, error) {
+	base := os.Getenv("XDG_DATA_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".local", "share")
+	}
+	dir := filepath.Join(base, "phantom")
+	return dir, os.MkdirAll(dir, 0o755)
+}
+
+// ProjectDataDir returns a data directory specific to the current working
+// directory, so every project gets its own history and caches.
+func ProjectDataDir() (string, error) {
+	root, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum([]byte(cwd))
+	dir := filepath.Join(root, "projects", fmt.Sprintf("%s-%x", filepath.Base(cwd), sum[:4]))
+	return dir, os.MkdirAll(dir, 0o755)
+}
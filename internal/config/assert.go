@@ -0,0 +1,240 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"phantom/internal/ui/tabs/http"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// parseAssertions reads the `assert` table of a request template:
+//
+//	assert = {
+//	    status = 200,                       -- or "2xx"
+//	    headers = { "ETag", ["Content-Type"] = "json" },
+//	    json = { ["$.id"] = 1 },
+//	    max_time = 500,                     -- milliseconds
+//	}
+//
+// Listed header names must be present; keyed ones must contain the value.
+func parseAssertions(t *lua.LTable) []http.Assertion {
+	var out []http.Assertion
+	if v := t.RawGetString("status"); v != lua.LNil {
+		out = append(out, http.Assertion{Kind: http.AssertStatus, Expected: v.String()})
+	}
+
+	// Lua tables have no key order, so keyed entries are sorted for a stable display.
+	byTarget := func(as []http.Assertion) []http.Assertion {
+		sort.SliceStable(as, func(i, j int) bool { return as[i].Target < as[j].Target })
+		return as
+	}
+	if headers, ok := t.RawGetString("headers").(*lua.LTable); ok {
+		var keyed []http.Assertion
+		headers.ForEach(func(k, v lua.LValue) {
+			if _, isIndex := k.(lua.LNumber); isIndex {
+				out = append(out, http.Assertion{Kind: http.AssertHeader, Target: v.String()})
+				return
+			}
+			keyed = append(keyed, http.Assertion{Kind: http.AssertHeader, Target: k.String(), Expected: v.String()})
+		})
+		out = append(out, byTarget(keyed)...)
+	}
+	if paths, ok := t.RawGetString("json").(*lua.LTable); ok {
+		var keyed []http.Assertion
+		paths.ForEach(func(k, v lua.LValue) {
+			keyed = append(keyed, http.Assertion{Kind: http.AssertJSONPath, Target: k.String(), Expected: fromLua(v)})
+		})
+		out = append(out, byTarget(keyed)...)
+	}
+
+	if ms, ok := t.RawGetString("max_time").(lua.LNumber); ok {
+		out = append(out, http.Assertion{Kind: http.AssertMaxTime, Expected: time.Duration(float64(ms) * float64(time.Millisecond))})
+	}
+	return out
+}
+
+// parseCaptures reads the `capture` table of a request template, mapping
+// variable names to expressions such as "$.token", "header:ETag" or
+// "regex:id=(\d+)". Invalid entries are logged and skipped.
+func parseCaptures(t *lua.LTable) []http.Capture {
+	var out []http.Capture
+	t.ForEach(func(k, v lua.LValue) {
+		c, err := http.ParseCapture(k.String(), v.String())
+		if err != nil {
+			log.Printf("config: %v", err)
+			return
+		}
+		out = append(out, c)
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// parseAuth reads the table form of a template's `auth`, e.g.
+//
+//	auth = { type = "oauth2", grant = "client_credentials", token_url = "...", client_id = "...", client_secret = "..." }
+//
+// and returns it as the one-line form the HTTP tab edits.
+func parseAuth(t *lua.LTable) string {
+	a := http.Auth{
+		Username:     luaString(t, "username"),
+		Password:     luaString(t, "password"),
+		Token:        luaString(t, "token"),
+		In:           luaString(t, "in"),
+		Name:         luaString(t, "name"),
+		Value:        luaString(t, "value"),
+		Grant:        luaString(t, "grant"),
+		TokenURL:     luaString(t, "token_url"),
+		ClientID:     luaString(t, "client_id"),
+		ClientSecret: luaString(t, "client_secret"),
+		Scope:        luaString(t, "scope"),
+	}
+	switch strings.ToLower(luaString(t, "type")) {
+	case "basic":
+		a.Kind = http.AuthBasic
+	case "bearer":
+		a.Kind = http.AuthBearer
+	case "apikey":
+		a.Kind = http.AuthAPIKey
+		if a.In == "" {
+			a.In = "header"
+		}
+	case "digest":
+		a.Kind = http.AuthDigest
+	case "oauth2":
+		a.Kind = http.AuthOAuth2
+		if a.Grant == "" {
+			a.Grant = "client_credentials"
+		}
+	default:
+		log.Printf("config: unknown auth type %q", luaString(t, "type"))
+		return ""
+	}
+	spec := a.String()
+	if _, err := http.ParseAuth(spec); err != nil {
+		log.Printf("config: %v", err)
+	}
+	return spec
+}
+
+// testFunc wraps the `test` function of a request template. It is called
+// with a response table:
+//
+//	{ status = 200, status_text = "200 OK", headers = {...}, body = "...", json = {...}, time_ms = 12 }
+//
+// The test fails if it raises an error or returns false. It may also return
+// a table of named checks, e.g. { ["has id"] = res.json.id ~= nil }.
+func (rt *runtime) testFunc(fn *lua.LFunction) http.TestFunc {
+	rt.keepAlive = true
+	return func(resp *http.Response) ([]http.AssertionResult, error) {
+		rt.mu.Lock()
+		defer rt.mu.Unlock()
+
+		L := rt.L
+		if err := L.CallByParam(lua.P{Fn: fn, NRet: 1, Protect: true}, responseTable(L, resp)); err != nil {
+			if apiErr, ok := err.(*lua.ApiError); ok {
+				return nil, fmt.Errorf("%s", apiErr.Object.String())
+			}
+			return nil, err
+		}
+		ret := L.Get(-1)
+		L.Pop(1)
+
+		switch ret := ret.(type) {
+		case *lua.LTable:
+			var results []http.AssertionResult
+			ret.ForEach(func(k, v lua.LValue) {
+				r := http.AssertionResult{Name: k.String(), Passed: lua.LVAsBool(v)}
+				if !r.Passed {
+					r.Message = "failed"
+				}
+				results = append(results, r)
+			})
+			sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+			return results, nil
+		case lua.LBool:
+			if !ret {
+				return []http.AssertionResult{{Name: "test", Message: "returned false"}}, nil
+			}
+		}
+		return []http.AssertionResult{{Name: "test", Passed: true}}, nil
+	}
+}
+
+func responseTable(L *lua.LState, resp *http.Response) *lua.LTable {
+	t := L.NewTable()
+	t.RawSetString("status", lua.LNumber(resp.StatusCode))
+	t.RawSetString("status_text", lua.LString(resp.Status))
+	t.RawSetString("body", lua.LString(resp.Body))
+	t.RawSetString("time_ms", lua.LNumber(resp.Timing.Total.Milliseconds()))
+
+	headers := L.NewTable()
+	for k, v := range resp.Header { // already canonical, e.g. "Content-Type"
+		if len(v) > 0 {
+			headers.RawSetString(k, lua.LString(v[0]))
+		}
+	}
+	t.RawSetString("headers", headers)
+
+	if doc, err := http.DecodeJSON(resp.Body); err == nil {
+		t.RawSetString("json", toLua(L, doc))
+	}
+	return t
+}
+
+// toLua converts decoded JSON to Lua values.
+func toLua(L *lua.LState, v any) lua.LValue {
+	switch v := v.(type) {
+	case map[string]any:
+		t := L.NewTable()
+		for k, val := range v {
+			t.RawSetString(k, toLua(L, val))
+		}
+		return t
+	case []any:
+		t := L.NewTable()
+		for _, val := range v {
+			t.Append(toLua(L, val))
+		}
+		return t
+	case json.Number:
+		f, _ := strconv.ParseFloat(string(v), 64)
+		return lua.LNumber(f)
+	case string:
+		return lua.LString(v)
+	case bool:
+		return lua.LBool(v)
+	}
+	return lua.LNil
+}
+
+// fromLua converts a Lua value to the Go value an assertion expects.
+func fromLua(v lua.LValue) any {
+	switch v := v.(type) {
+	case lua.LNumber:
+		return float64(v)
+	case lua.LString:
+		return string(v)
+	case lua.LBool:
+		return bool(v)
+	case *lua.LTable:
+		if v.MaxN() > 0 {
+			var arr []any
+			for i := 1; i <= v.MaxN(); i++ {
+				arr = append(arr, fromLua(v.RawGetInt(i)))
+			}
+			return arr
+		}
+		obj := map[string]any{}
+		v.ForEach(func(k, val lua.LValue) { obj[k.String()] = fromLua(val) })
+		return obj
+	}
+	return nil
+}
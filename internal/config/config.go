@@ -1,9 +1,16 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"slices"
+	"strings"
+	"time"
 
+	"phantom/internal/ui/layout"
+	"phantom/internal/ui/tabs/grpc"
 	"phantom/internal/ui/tabs/http"
+	"phantom/internal/ui/tabs/tasks"
 
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
@@ -13,60 +20,233 @@ import (
 // ConfigLoadedMsg is sent when the Lua configuration is successfully loaded.
 type ConfigLoadedMsg struct {
 	Templates   []list.Item
-	Environment map[string]string
+	Environment map[string]string // shared by all named environments
+	// Named environments, such as "local" and "prod", and the one to start with
+	Environments      []http.NamedEnvironment
+	ActiveEnvironment string
+	Panels            []Panel
+	Layout            *layout.Node
+	Commands          []tasks.Command
+	OpenAPI           string // spec to generate HTTP requests from
+	SecretsFile       string // encrypted file ${secret:name} references are read from
+	Timeouts          http.Timeouts
+	GRPC              grpc.Config
 }
 
+// DefaultFile is the configuration file phantom loads from the working directory.
+const DefaultFile = "config.lua"
+
 // LoadConfig reads and parses the config.lua file.
 func LoadConfig() tea.Cmd {
 	return func() tea.Msg {
-		L := lua.NewState()
-		defer L.Close()
+		msg, err := LoadConfigFile(DefaultFile)
+		if err != nil {
+			log.Printf("could not load %s: %v. Using defaults.", DefaultFile, err)
+		}
+		return msg
+	}
+}
+
+// LoadConfigFile runs the Lua configuration at path. When it cannot be run,
+// the error is returned along with the defaults.
+func LoadConfigFile(path string) (ConfigLoadedMsg, error) {
+	rt := newRuntime()
+	L := rt.L
 
-		if err := L.DoFile("config.lua"); err != nil {
-			log.Printf("could not load config.lua: %v. Using defaults.", err)
-			return ConfigLoadedMsg{Templates: []list.Item{}, Environment: map[string]string{}}
+	if err := L.DoFile(path); err != nil {
+		L.Close()
+		return ConfigLoadedMsg{Templates: []list.Item{}, Environment: map[string]string{}, Timeouts: http.DefaultTimeouts}, err
+	}
+
+	// The Lua state is only kept alive when panels or tests need to call back into it.
+	defer func() {
+		if len(rt.panels) == 0 && !rt.keepAlive {
+			L.Close()
 		}
+	}()
+	msg := ConfigLoadedMsg{Templates: []list.Item{}, Environment: map[string]string{}, Panels: rt.panels, Timeouts: http.DefaultTimeouts}
+
+	configTable, ok := L.GetGlobal("Config").(*lua.LTable)
+	if !ok {
+		log.Printf("'Config' table not found in %s. Using defaults.", path)
+		return msg, nil
+	}
 
-		configTable, ok := L.GetGlobal("Config").(*lua.LTable)
-		if !ok {
-			log.Println("'Config' table not found in config.lua. Using defaults.")
-			return ConfigLoadedMsg{Templates: []list.Item{}, Environment: map[string]string{}}
+	if lv := configTable.RawGetString("layout"); lv != lua.LNil {
+		l, err := parseLayout(lv)
+		if err != nil {
+			log.Printf("invalid layout in %s: %v. Ignoring it.", path, err)
 		}
+		msg.Layout = l
+	}
+
+	// Load commands
+	if commandsTable, ok := configTable.RawGetString("commands").(*lua.LTable); ok {
+		commandsTable.ForEach(func(_, val lua.LValue) {
+			t, ok := val.(*lua.LTable)
+			if !ok || luaString(t, "command") == "" {
+				return
+			}
+			name := luaString(t, "name")
+			if name == "" {
+				name = luaString(t, "command")
+			}
+			msg.Commands = append(msg.Commands, tasks.Command{Name: name, Command: luaString(t, "command")})
+		})
+	}
 
-		httpTable, ok := configTable.RawGetString("http").(*lua.LTable)
-		if !ok {
-			log.Println("'http' table not found in Config. Using defaults.")
-			return ConfigLoadedMsg{Templates: []list.Item{}, Environment: map[string]string{}}
+	if grpcTable, ok := configTable.RawGetString("grpc").(*lua.LTable); ok {
+		msg.GRPC.Address = luaString(grpcTable, "address")
+		msg.GRPC.Metadata = luaString(grpcTable, "metadata")
+		if paths, ok := grpcTable.RawGetString("import_paths").(*lua.LTable); ok {
+			paths.ForEach(func(_, path lua.LValue) { msg.GRPC.ImportPaths = append(msg.GRPC.ImportPaths, path.String()) })
 		}
+	}
 
-		// Load templates
-		var templates []list.Item
-		templatesTable, ok := httpTable.RawGetString("templates").(*lua.LTable)
-		if ok {
-			templatesTable.ForEach(func(_, val lua.LValue) {
-				t, ok := val.(*lua.LTable)
-				if !ok {
-					return
+	httpTable, ok := configTable.RawGetString("http").(*lua.LTable)
+	if !ok {
+		log.Println("'http' table not found in Config. Using defaults.")
+		return msg, nil
+	}
+
+	msg.OpenAPI = luaString(httpTable, "openapi")
+	msg.SecretsFile = luaString(httpTable, "secrets_file")
+	if d, ok := luaDuration(httpTable, "timeout"); ok {
+		msg.Timeouts.Total = d
+	}
+	if d, ok := luaDuration(httpTable, "connect_timeout"); ok {
+		msg.Timeouts.Connect = d
+	}
+
+	// Load templates
+	var templates []list.Item
+	templatesTable, ok := httpTable.RawGetString("templates").(*lua.LTable)
+	if ok {
+		templatesTable.ForEach(func(_, val lua.LValue) {
+			t, ok := val.(*lua.LTable)
+			if !ok {
+				return
+			}
+			item := http.RequestItem{
+				Name:    luaString(t, "name"),
+				Method:  strings.ToUpper(luaString(t, "method")),
+				URL:     luaString(t, "url"),
+				Headers: luaString(t, "headers"),
+				Body:    luaString(t, "body"),
+				Group:   luaString(t, "group"),
+			}
+			mode, err := bodyMode(luaString(t, "body_mode"))
+			if err != nil {
+				log.Printf("config: template %q: %v. Ignoring it.", item.Name, err)
+				return
+			}
+			item.BodyMode = mode
+			if item.Method == "" {
+				item.Method = "GET"
+			}
+			item.Timeout, _ = luaDuration(t, "timeout")
+			item.ConnectTimeout, _ = luaDuration(t, "connect_timeout")
+			item.Stream = lua.LVAsBool(t.RawGetString("stream"))
+			if item.Method == http.MethodGraphQL { // the query may be given as query instead of body
+				if q := luaString(t, "query"); q != "" {
+					item.Body = q
 				}
-				templates = append(templates, http.RequestItem{
-					Name:    t.RawGetString("name").String(),
-					Method:  t.RawGetString("method").String(),
-					URL:     t.RawGetString("url").String(),
-					Headers: t.RawGetString("headers").String(),
-					Body:    t.RawGetString("body").String(),
-				})
-			})
-		}
+				item.Variables = luaString(t, "variables")
+			}
+			if at, ok := t.RawGetString("assert").(*lua.LTable); ok {
+				item.Assertions = parseAssertions(at)
+			}
+			if fn, ok := t.RawGetString("test").(*lua.LFunction); ok {
+				item.Test = rt.testFunc(fn)
+			}
+			switch auth := t.RawGetString("auth").(type) {
+			case lua.LString:
+				item.Auth = string(auth)
+			case *lua.LTable:
+				item.Auth = parseAuth(auth)
+			}
+			if ct, ok := t.RawGetString("capture").(*lua.LTable); ok {
+				item.Captures = parseCaptures(ct)
+			}
+			if mt, ok := t.RawGetString("messages").(*lua.LTable); ok { // saved messages of a WS request
+				mt.ForEach(func(_, v lua.LValue) { item.Messages = append(item.Messages, v.String()) })
+			}
+			templates = append(templates, item)
+		})
+	}
+
+	// Load environment
+	environment := make(map[string]string)
+	envTable, ok := httpTable.RawGetString("environment").(*lua.LTable)
+	if ok {
+		envTable.ForEach(func(key, val lua.LValue) {
+			environment[key.String()] = val.String()
+		})
+	}
+
+	msg.Templates, msg.Environment = templates, environment
+	msg.Environments = parseEnvironments(httpTable)
+	msg.ActiveEnvironment = luaString(httpTable, "active_environment")
+	return msg, nil
+}
+
+// bodyMode checks the body_mode of a template: "raw" (or nothing), "json",
+// "form", "multipart", "file", ...; see http.BodyModes. Raw is stored as "".
+func bodyMode(mode string) (string, error) {
+	if mode == "raw" {
+		return "", nil
+	}
+	if mode == "" || slices.Contains(http.BodyModes, mode) {
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown body_mode %q, want one of %s", mode, strings.Join(http.BodyModes, ", "))
+}
 
-		// Load environment
-		environment := make(map[string]string)
-		envTable, ok := httpTable.RawGetString("environment").(*lua.LTable)
-		if ok {
-			envTable.ForEach(func(key, val lua.LValue) {
-				environment[key.String()] = val.String()
-			})
+// luaDuration reads a duration such as "30s". A bare number is a number of
+// milliseconds. ok is false if the key is not set or invalid.
+func luaDuration(t *lua.LTable, key string) (d time.Duration, ok bool) {
+	switch v := t.RawGetString(key).(type) {
+	case lua.LNumber:
+		return time.Duration(float64(v) * float64(time.Millisecond)), true
+	case lua.LString:
+		d, err := time.ParseDuration(string(v))
+		if err != nil {
+			log.Printf("config: %s: %v", key, err)
+			return 0, false
 		}
+		return d, true
+	}
+	return 0, false
+}
 
-		return ConfigLoadedMsg{Templates: templates, Environment: environment}
+// parseEnvironments reads the named environments of the http table:
+//
+//	environments = {
+//	    { name = "local", vars = { base_url = "http://localhost:8080" } },
+//	    { name = "prod", tags = { "production" }, vars = { base_url = "https://api.example.com" } },
+//	}
+//
+// A list keeps them in the order they are offered by the switcher.
+func parseEnvironments(httpTable *lua.LTable) []http.NamedEnvironment {
+	envsTable, ok := httpTable.RawGetString("environments").(*lua.LTable)
+	if !ok {
+		return nil
 	}
+	var envs []http.NamedEnvironment
+	envsTable.ForEach(func(_, val lua.LValue) {
+		t, ok := val.(*lua.LTable)
+		if !ok || luaString(t, "name") == "" {
+			log.Println("http.environments: skipping an entry without a name")
+			return
+		}
+		env := http.NamedEnvironment{Name: luaString(t, "name"), Vars: map[string]string{}}
+		if tags, ok := t.RawGetString("tags").(*lua.LTable); ok {
+			tags.ForEach(func(_, tag lua.LValue) { env.Tags = append(env.Tags, tag.String()) })
+		}
+		if vars, ok := t.RawGetString("vars").(*lua.LTable); ok {
+			vars.ForEach(func(key, val lua.LValue) { env.Vars[key.String()] = val.String() })
+		}
+		envs = append(envs, env)
+	})
+	return envs
 }
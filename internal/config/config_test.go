@@ -0,0 +1,172 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"phantom/internal/ui/tabs/http"
+)
+
+// loadConfig runs src as config.lua.
+func loadConfig(t *testing.T, src string) ConfigLoadedMsg {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), DefaultFile)
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := LoadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestTemplateBodyMode(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+		ok   bool
+	}{
+		{"", "", true},
+		{"raw", "", true},
+		{"json", "json", true},
+		{"form", http.BodyForm, true},
+		{"multipart", http.BodyMultipart, true},
+		{"file", http.BodyFile, true},
+		{"rawjson", "", false},
+		{"JSON", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			msg := loadConfig(t, `Config = { http = { templates = {
+				{ name = "t", url = "https://example.com", body_mode = "`+tt.mode+`" },
+			} } }`)
+			if !tt.ok {
+				if len(msg.Templates) != 0 {
+					t.Errorf("template with body_mode %q was loaded, want it ignored", tt.mode)
+				}
+				return
+			}
+			if len(msg.Templates) != 1 {
+				t.Fatalf("loaded %d templates, want 1", len(msg.Templates))
+			}
+			if got := msg.Templates[0].(http.RequestItem).BodyMode; got != tt.want {
+				t.Errorf("BodyMode = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string // a template table
+		want http.RequestItem
+	}{
+		{"defaults", `{ name = "list", url = "https://example.com/items" }`,
+			http.RequestItem{Name: "list", Method: "GET", URL: "https://example.com/items"}},
+		{"fields", `{ name = "create", method = "post", url = "/items", group = "Items/Admin",
+			headers = "Content-Type: application/json", body = '{"a":1}', body_mode = "json", stream = true }`,
+			http.RequestItem{Name: "create", Method: "POST", URL: "/items", Group: "Items/Admin",
+				Headers: "Content-Type: application/json", Body: `{"a":1}`, BodyMode: "json", Stream: true}},
+		{"graphql query", `{ name = "me", method = "graphql", url = "/graphql", query = "{ me { id } }", variables = '{"id":1}' }`,
+			http.RequestItem{Name: "me", Method: http.MethodGraphQL, URL: "/graphql", Body: "{ me { id } }", Variables: `{"id":1}`}},
+		{"auth string", `{ name = "a", url = "/", auth = "bearer abc" }`,
+			http.RequestItem{Name: "a", Method: "GET", URL: "/", Auth: "bearer abc"}},
+		{"auth table", `{ name = "a", url = "/", auth = { type = "bearer", token = "abc" } }`,
+			http.RequestItem{Name: "a", Method: "GET", URL: "/", Auth: http.Auth{Kind: http.AuthBearer, Token: "abc"}.String()}},
+		{"messages", `{ name = "ws", method = "WS", url = "ws://localhost", messages = { "ping", "pong" } }`,
+			http.RequestItem{Name: "ws", Method: "WS", URL: "ws://localhost", Messages: []string{"ping", "pong"}}},
+		{"timeouts", `{ name = "t", url = "/", timeout = "2s", connect_timeout = 250 }`,
+			http.RequestItem{Name: "t", Method: "GET", URL: "/", Timeout: 2 * time.Second, ConnectTimeout: 250 * time.Millisecond}},
+		{"invalid timeout", `{ name = "t", url = "/", timeout = "soon" }`,
+			http.RequestItem{Name: "t", Method: "GET", URL: "/"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := loadConfig(t, `Config = { http = { templates = { `+tt.src+` } } }`)
+			if len(msg.Templates) != 1 {
+				t.Fatalf("loaded %d templates, want 1", len(msg.Templates))
+			}
+			if got := msg.Templates[0].(http.RequestItem); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("template = %+v,\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateCaptures(t *testing.T) {
+	msg := loadConfig(t, `Config = { http = { templates = {
+		{ name = "login", url = "/login", capture = { token = "$.token", etag = "header:ETag", id = "regex:id=(\\d+)", bad = "regex:(" } },
+	} } }`)
+	var got []string
+	for _, c := range msg.Templates[0].(http.RequestItem).Captures {
+		got = append(got, c.Name+" "+c.Expr)
+	}
+	want := []string{"etag ETag", `id id=(\d+)`, "token $.token"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("captures = %q, want %q", got, want)
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string // fields of the http table
+		want http.Timeouts
+	}{
+		{"unset", ``, http.DefaultTimeouts},
+		{"durations", `timeout = "30s", connect_timeout = "1m"`, http.Timeouts{Connect: time.Minute, Total: 30 * time.Second}},
+		{"milliseconds", `timeout = 1500, connect_timeout = 200`, http.Timeouts{Connect: 200 * time.Millisecond, Total: 1500 * time.Millisecond}},
+		{"invalid", `timeout = "forever", connect_timeout = true`, http.DefaultTimeouts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := loadConfig(t, `Config = { http = { `+tt.src+` } }`)
+			if msg.Timeouts != tt.want {
+				t.Errorf("timeouts = %+v, want %+v", msg.Timeouts, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string // the assert table
+		want []http.Assertion
+	}{
+		{"none", `{}`, nil},
+		{"status", `{ status = 200 }`, []http.Assertion{{Kind: http.AssertStatus, Expected: "200"}}},
+		{"status class", `{ status = "2xx" }`, []http.Assertion{{Kind: http.AssertStatus, Expected: "2xx"}}},
+		{"headers", `{ headers = { "ETag", ["X-B"] = "b", ["Content-Type"] = "json" } }`, []http.Assertion{
+			{Kind: http.AssertHeader, Target: "ETag"},
+			{Kind: http.AssertHeader, Target: "Content-Type", Expected: "json"},
+			{Kind: http.AssertHeader, Target: "X-B", Expected: "b"},
+		}},
+		{"json", `{ json = { ["$.name"] = "a", ["$.id"] = 1, ["$.ok"] = true, ["$.tags"] = { "x", "y" } } }`, []http.Assertion{
+			{Kind: http.AssertJSONPath, Target: "$.id", Expected: float64(1)},
+			{Kind: http.AssertJSONPath, Target: "$.name", Expected: "a"},
+			{Kind: http.AssertJSONPath, Target: "$.ok", Expected: true},
+			{Kind: http.AssertJSONPath, Target: "$.tags", Expected: []any{"x", "y"}},
+		}},
+		{"max time", `{ max_time = 500 }`, []http.Assertion{{Kind: http.AssertMaxTime, Expected: 500 * time.Millisecond}}},
+		{"all", `{ max_time = 100, json = { ["$.id"] = 1 }, status = 201, headers = { "ETag" } }`, []http.Assertion{
+			{Kind: http.AssertStatus, Expected: "201"},
+			{Kind: http.AssertHeader, Target: "ETag"},
+			{Kind: http.AssertJSONPath, Target: "$.id", Expected: float64(1)},
+			{Kind: http.AssertMaxTime, Expected: 100 * time.Millisecond},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := loadConfig(t, `Config = { http = { templates = { { name = "t", url = "/", assert = `+tt.src+` } } } }`)
+			if got := msg.Templates[0].(http.RequestItem).Assertions; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("assertions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+
+	"phantom/internal/ui/layout"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// parseLayout converts Config.layout into a layout tree. It accepts the 2x2
+// form (top_left, top_right, bottom_left, bottom_right) as well as nested
+// splits such as { rows = { { "workspace", "system" }, "http" } }.
+func parseLayout(v lua.LValue) (*layout.Node, error) {
+	t, ok := v.(*lua.LTable)
+	if !ok {
+		return nil, fmt.Errorf("layout must be a table, got %s", v.Type())
+	}
+	if isGrid(t) {
+		n := layout.Grid(
+			luaString(t, "top_left"),
+			luaString(t, "top_right"),
+			luaString(t, "bottom_left"),
+			luaString(t, "bottom_right"),
+		)
+		return &n, nil
+	}
+	n, err := parseLayoutNode(t, layout.Rows)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// parseLayoutNode parses one node. A bare array of entries is split along the
+// opposite axis of its parent, so { rows = { { "a", "b" } } } puts a and b side by side.
+func parseLayoutNode(v lua.LValue, parent layout.Direction) (layout.Node, error) {
+	switch v := v.(type) {
+	case lua.LString:
+		return layout.Node{Panel: string(v)}, nil
+	case *lua.LTable:
+		size := int(lua.LVAsNumber(v.RawGetString("size")))
+		if name := luaString(v, "panel"); name != "" {
+			return layout.Node{Panel: name, Size: size}, nil
+		}
+
+		dir, entries := opposite(parent), lua.LValue(v)
+		if rows := v.RawGetString("rows"); rows != lua.LNil {
+			dir, entries = layout.Rows, rows
+		} else if cols := v.RawGetString("columns"); cols != lua.LNil {
+			dir, entries = layout.Columns, cols
+		}
+		list, ok := entries.(*lua.LTable)
+		if !ok {
+			return layout.Node{}, fmt.Errorf("layout split must be a list, got %s", entries.Type())
+		}
+
+		n := layout.Node{Direction: dir, Size: size}
+		for i := 1; i <= list.Len(); i++ {
+			child, err := parseLayoutNode(list.RawGetInt(i), dir)
+			if err != nil {
+				return layout.Node{}, err
+			}
+			n.Children = append(n.Children, child)
+		}
+		if len(n.Children) == 0 {
+			return layout.Node{}, fmt.Errorf("layout split has no panels")
+		}
+		return n, nil
+	default:
+		return layout.Node{}, fmt.Errorf("unexpected %s in layout", v.Type())
+	}
+}
+
+func isGrid(t *lua.LTable) bool {
+	for _, k := range []string{"top_left", "top_right", "bottom_left", "bottom_right"} {
+		if t.RawGetString(k) != lua.LNil {
+			return true
+		}
+	}
+	return false
+}
+
+func opposite(d layout.Direction) layout.Direction {
+	if d == layout.Rows {
+		return layout.Columns
+	}
+	return layout.Rows
+}
+
+// luaString returns the string field key of t, or "" if it is unset.
+func luaString(t *lua.LTable, key string) string {
+	if v := t.RawGetString(key); v != lua.LNil {
+		return v.String()
+	}
+	return ""
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// DefaultPanelInterval is how often a custom panel is refreshed when
+// register_panel is called without an explicit interval.
+const DefaultPanelInterval = time.Second
+
+// Panel is a custom panel registered from config.lua via phantom.register_panel.
+type Panel struct {
+	Name     string
+	Interval time.Duration
+	fn       *lua.LFunction
+	rt       *runtime
+}
+
+// Render calls the panel's Lua function and returns the string it produced.
+func (p Panel) Render() (string, error) {
+	p.rt.mu.Lock()
+	defer p.rt.mu.Unlock()
+
+	L := p.rt.L
+	if err := L.CallByParam(lua.P{Fn: p.fn, NRet: 1, Protect: true}); err != nil {
+		return "", err
+	}
+	ret := L.Get(-1)
+	L.Pop(1)
+	if ret == lua.LNil {
+		return "", nil
+	}
+	return ret.String(), nil
+}
+
+// runtime owns the Lua state that outlives LoadConfig so panel functions can
+// be called later. gopher-lua states are not safe for concurrent use, hence the mutex.
+type runtime struct {
+	mu        sync.Mutex
+	L         *lua.LState
+	panels    []Panel
+	keepAlive bool // request test functions still need the state
+}
+
+func newRuntime() *runtime {
+	rt := &runtime{L: lua.NewState()}
+	rt.L.PreloadModule("phantom", rt.loader)
+	// config.lua uses the module as a global without require(), so expose it directly too.
+	rt.L.SetGlobal("phantom", rt.module(rt.L))
+	return rt
+}
+
+func (rt *runtime) loader(L *lua.LState) int {
+	L.Push(rt.module(L))
+	return 1
+}
+
+func (rt *runtime) module(L *lua.LState) *lua.LTable {
+	return L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"register_panel": rt.registerPanel,
+		"exec":           luaExec,
+	})
+}
+
+// registerPanel implements phantom.register_panel(name, fn [, interval_seconds]).
+func (rt *runtime) registerPanel(L *lua.LState) int {
+	name := L.CheckString(1)
+	fn := L.CheckFunction(2)
+	interval := DefaultPanelInterval
+	if secs := float64(L.OptNumber(3, 0)); secs > 0 {
+		interval = time.Duration(secs * float64(time.Second))
+	}
+
+	panel := Panel{Name: name, Interval: interval, fn: fn, rt: rt}
+	for i, p := range rt.panels {
+		if p.Name == name {
+			rt.panels[i] = panel
+			return 0
+		}
+	}
+	rt.panels = append(rt.panels, panel)
+	return 0
+}
+
+// luaExec implements phantom.exec(command), returning stdout and an error string on failure.
+func luaExec(L *lua.LState) int {
+	command := L.CheckString(1)
+	out, err := exec.Command("sh", "-c", command).Output()
+	L.Push(lua.LString(out))
+	if err != nil {
+		L.Push(lua.LString(fmt.Sprintf("%v", err)))
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,94 @@
+package secrets
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DotEnvFile is the file ${dotenv:NAME} references are read from.
+const DotEnvFile = ".env"
+
+// dotEnvCache holds the parsed DotEnvFile, reread when it changes.
+var dotEnvCache struct {
+	sync.Mutex
+	modTime time.Time
+	values  map[string]string
+}
+
+func dotEnv(name string) (string, bool) {
+	dotEnvCache.Lock()
+	defer dotEnvCache.Unlock()
+	info, err := os.Stat(DotEnvFile)
+	if err != nil {
+		return "", false
+	}
+	if !info.ModTime().Equal(dotEnvCache.modTime) {
+		values, err := ParseDotEnv(DotEnvFile)
+		if err != nil {
+			return "", false
+		}
+		dotEnvCache.modTime, dotEnvCache.values = info.ModTime(), values
+	}
+	v, ok := dotEnvCache.values[name]
+	return v, ok
+}
+
+// ParseDotEnv reads a .env file: NAME=value lines, optionally prefixed
+// with "export", with # comments and single or double quoted values.
+func ParseDotEnv(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		values[strings.TrimSpace(name)] = dotEnvValue(strings.TrimSpace(value))
+	}
+	return values, scanner.Err()
+}
+
+// dotEnvValue unquotes a value. Double quoted values understand \n, \"
+// and \\; unquoted values end at a " #" comment.
+func dotEnvValue(v string) string {
+	if len(v) >= 2 && v[0] == '\'' {
+		if end := strings.IndexByte(v[1:], '\''); end >= 0 {
+			return v[1 : end+1]
+		}
+	}
+	if len(v) >= 2 && v[0] == '"' {
+		var b strings.Builder
+		for i := 1; i < len(v); i++ {
+			switch {
+			case v[i] == '"':
+				return b.String()
+			case v[i] == '\\' && i+1 < len(v) && v[i+1] == 'n':
+				b.WriteByte('\n')
+				i++
+			case v[i] == '\\' && i+1 < len(v) && (v[i+1] == '"' || v[i+1] == '\\'):
+				b.WriteByte(v[i+1])
+				i++
+			default:
+				b.WriteByte(v[i])
+			}
+		}
+	}
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = strings.TrimSpace(v[:i])
+	}
+	return v
+}
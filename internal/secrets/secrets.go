@@ -0,0 +1,138 @@
+// Package secrets resolves secret references in environment values and
+// keeps track of the resolved values so they can be masked in the UI and
+// redacted from logs.
+//
+// A value may reference an OS environment variable, a variable of the
+// project's .env file or an entry of the encrypted secrets file:
+//
+//	auth_token = "Bearer ${env:API_TOKEN}"
+//	db_password = "${dotenv:DB_PASSWORD}"
+//	client_secret = "${secret:client_secret}"
+package secrets
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Masked replaces secret values wherever they are displayed.
+const Masked = "••••••"
+
+// refPattern matches ${env:NAME}, ${dotenv:NAME} and ${secret:NAME}.
+var refPattern = regexp.MustCompile(`\$\{(env|dotenv|secret):([A-Za-z0-9_.-]+)\}`)
+
+// HasRefs reports whether s references a secret.
+func HasRefs(s string) bool { return refPattern.MatchString(s) }
+
+// NeedsVault reports whether s references the encrypted secrets file.
+func NeedsVault(s string) bool {
+	for _, m := range refPattern.FindAllStringSubmatch(s, -1) {
+		if m[1] == "secret" {
+			return true
+		}
+	}
+	return false
+}
+
+// Expand replaces the secret references in s and registers the values for
+// masking. References that cannot be resolved are left in place and
+// reported in the error. vault may be nil while the secrets file is locked.
+func Expand(s string, vault *Vault) (string, error) {
+	var missing []string
+	out := refPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		m := refPattern.FindStringSubmatch(ref)
+		kind, name := m[1], m[2]
+		var v string
+		var ok bool
+		switch kind {
+		case "env":
+			v, ok = os.LookupEnv(name)
+		case "dotenv":
+			v, ok = dotEnv(name)
+		case "secret":
+			if vault == nil {
+				missing = append(missing, ref+" (secrets file is locked)")
+				return ref
+			}
+			v, ok = vault.Get(name)
+		}
+		if !ok {
+			missing = append(missing, ref+" is not set")
+			return ref
+		}
+		Register(v)
+		return v
+	})
+	if len(missing) > 0 {
+		return out, fmt.Errorf("%s", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// minSecretLen keeps short values such as "1" or "yes" from being masked
+// all over the place.
+const minSecretLen = 4
+
+var registry struct {
+	sync.RWMutex
+	values   map[string]bool
+	replacer *strings.Replacer
+}
+
+// Register marks value as a secret, to be masked from now on.
+func Register(value string) {
+	if len(value) < minSecretLen {
+		return
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if registry.values[value] {
+		return
+	}
+	if registry.values == nil {
+		registry.values = make(map[string]bool)
+	}
+	registry.values[value] = true
+
+	// Longer values first, so a secret containing another is masked whole.
+	values := make([]string, 0, len(registry.values))
+	for v := range registry.values {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	var pairs []string
+	for _, v := range values {
+		pairs = append(pairs, v, Masked)
+	}
+	registry.replacer = strings.NewReplacer(pairs...)
+}
+
+// Mask replaces every registered secret in s.
+func Mask(s string) string {
+	registry.RLock()
+	defer registry.RUnlock()
+	if registry.replacer == nil {
+		return s
+	}
+	return registry.replacer.Replace(s)
+}
+
+// RedactWriter returns a writer that masks registered secrets before
+// writing to w. It is meant for line-oriented output such as a log.
+func RedactWriter(w io.Writer) io.Writer {
+	return redactWriter{w}
+}
+
+type redactWriter struct{ w io.Writer }
+
+func (r redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, Mask(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
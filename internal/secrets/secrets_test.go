@@ -0,0 +1,100 @@
+package secrets
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile(DotEnvFile, []byte("API_KEY=dotenv-key\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PHANTOM_TEST_USER", "env-user")
+	vault, err := OpenVault(filepath.Join(t.TempDir(), DefaultVaultFile), "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault.Set("prod.token", "vault-token")
+
+	tests := []struct {
+		name      string
+		in        string
+		vault     *Vault
+		want      string
+		wantErr   string
+		needVault bool
+	}{
+		{name: "plain", in: "https://{{host}}/x", want: "https://{{host}}/x"},
+		{name: "env", in: "user=${env:PHANTOM_TEST_USER}", want: "user=env-user"},
+		{name: "dotenv", in: "${dotenv:API_KEY}", want: "dotenv-key"},
+		{name: "secret", in: "Bearer ${secret:prod.token}", vault: vault, want: "Bearer vault-token", needVault: true},
+		{name: "all", in: "${env:PHANTOM_TEST_USER}:${secret:prod.token}@${dotenv:API_KEY}", vault: vault, want: "env-user:vault-token@dotenv-key", needVault: true},
+		{name: "locked", in: "${secret:prod.token}", want: "${secret:prod.token}", wantErr: "secrets file is locked", needVault: true},
+		{name: "unknown secret", in: "${secret:nope}", vault: vault, want: "${secret:nope}", wantErr: "${secret:nope} is not set", needVault: true},
+		{name: "unset env", in: "a ${env:PHANTOM_TEST_UNSET} b", want: "a ${env:PHANTOM_TEST_UNSET} b", wantErr: "is not set"},
+		{name: "unknown kind", in: "${vault:x}", want: "${vault:x}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Expand(tt.in, tt.vault)
+			if got != tt.want {
+				t.Errorf("Expand(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if (err != nil) != (tt.wantErr != "") || err != nil && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expand(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+			}
+			if got := NeedsVault(tt.in); got != tt.needVault {
+				t.Errorf("NeedsVault(%q) = %v, want %v", tt.in, got, tt.needVault)
+			}
+		})
+	}
+
+	// Resolved values are masked from then on.
+	var log bytes.Buffer
+	RedactWriter(&log).Write([]byte("sent vault-token and env-user, not ok\n"))
+	if got := log.String(); got != "sent "+Masked+" and "+Masked+", not ok\n" {
+		t.Errorf("redacted log = %q", got)
+	}
+}
+
+func TestParseDotEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DotEnvFile)
+	src := `# comment
+PLAIN=value
+export EXPORTED = spaced
+COMMENTED=abc # trailing comment
+HASH=a#b
+DOUBLE="line\nbreak \"quoted\" # kept"
+SINGLE='raw \n # kept'
+EMPTY=
+not a pair
+`
+	if err := os.WriteFile(path, []byte(src), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseDotEnv(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"PLAIN":     "value",
+		"EXPORTED":  "spaced",
+		"COMMENTED": "abc",
+		"HASH":      "a#b",
+		"DOUBLE":    "line\nbreak \"quoted\" # kept",
+		"SINGLE":    `raw \n # kept`,
+		"EMPTY":     "",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("parsed %d values, want %d: %v", len(got), len(want), got)
+	}
+}
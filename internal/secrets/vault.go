@@ -0,0 +1,143 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// DefaultVaultFile is the encrypted secrets file, next to config.lua.
+const DefaultVaultFile = ".phantom-secrets"
+
+// PassphraseEnv is the environment variable the secrets file passphrase is
+// taken from, if set.
+const PassphraseEnv = "PHANTOM_SECRETS_PASSPHRASE"
+
+const (
+	kdfIterations = 600_000
+	keyLen        = 32 // AES-256
+	saltLen       = 16
+)
+
+// ErrWrongPassphrase is returned when the secrets file cannot be decrypted.
+var ErrWrongPassphrase = errors.New("wrong passphrase or damaged secrets file")
+
+// vaultFile is the on-disk format of the secrets file. Data is the JSON
+// object of secrets, sealed with AES-GCM under a PBKDF2-SHA256 key.
+type vaultFile struct {
+	Version    int    `json:"version"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// Vault is an unlocked secrets file.
+type Vault struct {
+	path, passphrase string
+	values           map[string]string
+}
+
+// OpenVault decrypts the secrets file at path. A missing file is an empty
+// vault, created on Save.
+func OpenVault(path, passphrase string) (*Vault, error) {
+	v := &Vault{path: path, passphrase: passphrase, values: map[string]string{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return v, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var f vaultFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if f.Version != 1 || f.Iterations <= 0 {
+		return nil, fmt.Errorf("%s: unsupported secrets file", path)
+	}
+	gcm, err := newGCM(passphrase, f.Salt, f.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Nonce) != gcm.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+	plain, err := gcm.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	if err := json.Unmarshal(plain, &v.values); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return v, nil
+}
+
+// Get returns the secret called name.
+func (v *Vault) Get(name string) (string, bool) {
+	s, ok := v.values[name]
+	return s, ok
+}
+
+// Set adds or replaces a secret. Call Save to write it.
+func (v *Vault) Set(name, value string) { v.values[name] = value }
+
+// Delete removes a secret. Call Save to write the change.
+func (v *Vault) Delete(name string) { delete(v.values, name) }
+
+// Names returns the names of the secrets, sorted.
+func (v *Vault) Names() []string {
+	names := make([]string, 0, len(v.values))
+	for k := range v.values {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save encrypts the secrets with a fresh salt and nonce and writes them,
+// readable by the current user only.
+func (v *Vault) Save() error {
+	plain, err := json.Marshal(v.values)
+	if err != nil {
+		return err
+	}
+	f := vaultFile{Version: 1, Iterations: kdfIterations, Salt: make([]byte, saltLen)}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return err
+	}
+	gcm, err := newGCM(v.passphrase, f.Salt, f.Iterations)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return err
+	}
+	f.Data = gcm.Seal(nil, f.Nonce, plain, nil)
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(v.path, append(data, '\n'), 0o600)
+}
+
+func newGCM(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, keyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
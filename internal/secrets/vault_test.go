@@ -0,0 +1,99 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVaultRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultVaultFile)
+	v, err := OpenVault(path, "correct horse")
+	if err != nil {
+		t.Fatalf("opening a missing file: %v", err)
+	}
+	if len(v.Names()) != 0 {
+		t.Fatalf("a missing file has secrets %v", v.Names())
+	}
+	v.Set("prod.token", "s3cr3t-token")
+	v.Set("db_password", "pa55 wörd\n")
+	v.Set("gone", "x")
+	v.Delete("gone")
+	if err := v.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("secrets file mode = %v (%v), want 0600", info.Mode().Perm(), err)
+	}
+	data, _ := os.ReadFile(path)
+	for _, plain := range []string{"s3cr3t-token", "prod.token", "db_password"} {
+		if strings.Contains(string(data), plain) {
+			t.Errorf("secrets file holds %q in plain text", plain)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		passphrase string
+		tamper     func(f *vaultFile)
+		wantErr    error
+	}{
+		{name: "reopen", passphrase: "correct horse"},
+		{name: "wrong passphrase", passphrase: "battery staple", wantErr: ErrWrongPassphrase},
+		{name: "damaged data", passphrase: "correct horse", tamper: func(f *vaultFile) { f.Data[0] ^= 1 }, wantErr: ErrWrongPassphrase},
+		{name: "damaged nonce", passphrase: "correct horse", tamper: func(f *vaultFile) { f.Nonce = f.Nonce[1:] }, wantErr: ErrWrongPassphrase},
+		{name: "other salt", passphrase: "correct horse", tamper: func(f *vaultFile) { f.Salt[0] ^= 1 }, wantErr: ErrWrongPassphrase},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := path
+			if tt.tamper != nil {
+				var f vaultFile
+				if err := json.Unmarshal(data, &f); err != nil {
+					t.Fatal(err)
+				}
+				tt.tamper(&f)
+				p = filepath.Join(t.TempDir(), DefaultVaultFile)
+				b, _ := json.Marshal(f)
+				if err := os.WriteFile(p, b, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := OpenVault(p, tt.passphrase)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("OpenVault error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if names := got.Names(); !reflect.DeepEqual(names, []string{"db_password", "prod.token"}) {
+				t.Errorf("Names = %v", names)
+			}
+			if s, _ := got.Get("db_password"); s != "pa55 wörd\n" {
+				t.Errorf("db_password = %q", s)
+			}
+		})
+	}
+}
+
+func TestOpenVaultUnsupported(t *testing.T) {
+	for name, content := range map[string]string{
+		"not json":      "secret=1",
+		"other version": `{"version": 2, "iterations": 1}`,
+		"no iterations": `{"version": 1}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), DefaultVaultFile)
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := OpenVault(path, "x"); err == nil || errors.Is(err, ErrWrongPassphrase) {
+				t.Errorf("OpenVault error = %v, want an unsupported file error", err)
+			}
+		})
+	}
+}
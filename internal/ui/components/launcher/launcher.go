@@ -38,6 +38,11 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	return m, nil
 }
 
+// SetSize sets the size of the launcher model.
+func (m *Model) SetSize(w, h int) {
+	m.Width, m.Height = w, h
+}
+
 // View renders the launcher model.
 func (m Model) View() string {
 	var b strings.Builder
@@ -38,6 +38,9 @@ var (
 	SuccessStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("70"))
 	ErrorStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
 	SpinnerStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("69"))
+	EnvironmentStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFFFF")).Background(lipgloss.Color("#575B7E")).Padding(0, 1)
+	ProductionStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FFFFFF")).Background(lipgloss.Color("160")).Padding(0, 1)
+	SelectedCellStyle = lipgloss.NewStyle().Reverse(true)
 )
 
 // System Panel styles
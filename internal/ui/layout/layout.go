@@ -0,0 +1,195 @@
+package layout
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Direction is the axis along which a split node lays out its children.
+type Direction int
+
+const (
+	Columns Direction = iota // children side by side
+	Rows                     // children stacked top to bottom
+)
+
+// Node is either a leaf cell showing a single panel, or a split holding children.
+type Node struct {
+	Panel     string // set for leaves only
+	Size      int    // relative weight within the parent split, defaults to 1
+	Direction Direction
+	Children  []Node
+}
+
+// Cell is a leaf of the layout with its absolute position and size.
+type Cell struct {
+	Panel               string
+	X, Y, Width, Height int
+}
+
+// Move is a direction for moving focus between cells.
+type Move int
+
+const (
+	Left Move = iota
+	Right
+	Up
+	Down
+)
+
+// Grid builds the classic 2x2 layout. Empty names leave their cell out, and
+// a row left without cells is dropped entirely.
+func Grid(topLeft, topRight, bottomLeft, bottomRight string) Node {
+	root := Node{Direction: Rows}
+	for _, row := range [][]string{{topLeft, topRight}, {bottomLeft, bottomRight}} {
+		r := Node{Direction: Columns}
+		for _, name := range row {
+			if name != "" {
+				r.Children = append(r.Children, Node{Panel: name})
+			}
+		}
+		if len(r.Children) > 0 {
+			root.Children = append(root.Children, r)
+		}
+	}
+	return root
+}
+
+// IsLeaf reports whether the node shows a single panel.
+func (n Node) IsLeaf() bool {
+	return n.Panel != ""
+}
+
+// Cells flattens the layout into cells for a width x height area, in reading order.
+func (n Node) Cells(width, height int) []Cell {
+	var cells []Cell
+	n.walk(0, 0, width, height, func(c Cell) { cells = append(cells, c) })
+	return cells
+}
+
+func (n Node) walk(x, y, w, h int, visit func(Cell)) {
+	if n.IsLeaf() || len(n.Children) == 0 {
+		visit(Cell{Panel: n.Panel, X: x, Y: y, Width: w, Height: h})
+		return
+	}
+	total := w
+	if n.Direction == Rows {
+		total = h
+	}
+	for i, size := range n.distribute(total) {
+		child := n.Children[i]
+		if n.Direction == Rows {
+			child.walk(x, y, w, size, visit)
+			y += size
+		} else {
+			child.walk(x, y, size, h, visit)
+			x += size
+		}
+	}
+}
+
+// distribute splits total between the children according to their weights,
+// handing any remainder to the last child so the sizes always add up.
+func (n Node) distribute(total int) []int {
+	weights, sum := make([]int, len(n.Children)), 0
+	for i, c := range n.Children {
+		weights[i] = c.Size
+		if weights[i] <= 0 {
+			weights[i] = 1
+		}
+		sum += weights[i]
+	}
+	sizes, used := make([]int, len(weights)), 0
+	for i, wt := range weights {
+		sizes[i] = total * wt / sum
+		used += sizes[i]
+	}
+	sizes[len(sizes)-1] += total - used
+	return sizes
+}
+
+// Render draws the layout into a width x height block. render is called once
+// per cell, in the same order as Cells, and must return a block of exactly
+// the cell's size.
+func (n Node) Render(width, height int, render func(i int, c Cell) string) string {
+	cells := n.Cells(width, height)
+	if len(cells) == 0 {
+		return ""
+	}
+
+	// Paint each cell's lines into a row-indexed canvas. The tree walk visits
+	// the cells crossing any given line from left to right, so appending works.
+	lines := make([]strings.Builder, height)
+	for i, c := range cells {
+		block := strings.Split(render(i, c), "\n")
+		for row := 0; row < c.Height && c.Y+row < height; row++ {
+			line := ""
+			if row < len(block) {
+				line = block[row]
+			}
+			lines[c.Y+row].WriteString(pad(line, c.Width))
+		}
+	}
+
+	out := make([]string, height)
+	for i := range lines {
+		out[i] = lines[i].String()
+	}
+	return strings.Join(out, "\n")
+}
+
+// pad truncates or right-pads a rendered line to exactly width cells.
+func pad(line string, width int) string {
+	line = lipgloss.NewStyle().MaxWidth(width).Render(line)
+	if w := lipgloss.Width(line); w < width {
+		line += strings.Repeat(" ", width-w)
+	}
+	return line
+}
+
+// Neighbor returns the index of the cell reached by moving from cell from in
+// direction dir, or from itself if there is nothing in that direction.
+func Neighbor(cells []Cell, from int, dir Move) int {
+	if from < 0 || from >= len(cells) {
+		return from
+	}
+	cur := cells[from]
+	best, bestDist := from, -1
+	for i, c := range cells {
+		if i == from {
+			continue
+		}
+		var dist int
+		switch dir {
+		case Left:
+			if c.X+c.Width > cur.X || !overlaps(c.Y, c.Height, cur.Y, cur.Height) {
+				continue
+			}
+			dist = cur.X - (c.X + c.Width)
+		case Right:
+			if c.X < cur.X+cur.Width || !overlaps(c.Y, c.Height, cur.Y, cur.Height) {
+				continue
+			}
+			dist = c.X - (cur.X + cur.Width)
+		case Up:
+			if c.Y+c.Height > cur.Y || !overlaps(c.X, c.Width, cur.X, cur.Width) {
+				continue
+			}
+			dist = cur.Y - (c.Y + c.Height)
+		case Down:
+			if c.Y < cur.Y+cur.Height || !overlaps(c.X, c.Width, cur.X, cur.Width) {
+				continue
+			}
+			dist = c.Y - (cur.Y + cur.Height)
+		}
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
+
+func overlaps(a, alen, b, blen int) bool {
+	return a < b+blen && b < a+alen
+}
@@ -2,18 +2,24 @@ package ui
 
 import (
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"phantom/internal/app"
 	"phantom/internal/config"
 	"phantom/internal/ui/components/launcher"
 	"phantom/internal/ui/components/styles"
+	"phantom/internal/ui/layout"
 	"phantom/internal/ui/tabs/dashboard"
 	"phantom/internal/ui/tabs/docker"
 	"phantom/internal/ui/tabs/git"
+	"phantom/internal/ui/tabs/grpc"
 	"phantom/internal/ui/tabs/http"
 	"phantom/internal/ui/tabs/kind"
 	"phantom/internal/ui/tabs/nvim"
+	"phantom/internal/ui/tabs/panel"
+	"phantom/internal/ui/tabs/tasks"
 
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
@@ -28,19 +34,45 @@ type Model struct {
 	Ready          bool
 	DashboardModel dashboard.Model
 	HTTPModel      http.Model
+	GRPCModel      grpc.Model
+	TasksModel     tasks.Model
 	GitModel       launcher.Model
 	DockerModel    launcher.Model
 	KindModel      kind.Model
 	NvimModel      launcher.Model
+	Panels         []panel.Model
+	// Layout
+	Layout      *layout.Node
+	LayoutCells []layout.Cell
+	FocusedCell int
+}
+
+// layoutTab is the tab that shows Config.layout.
+const layoutTab = "Layout"
+
+// layoutAliases maps the panel names used in config.lua onto tab names.
+var layoutAliases = map[string]string{
+	"system":    "Dashboard",
+	"workspace": "Tasks",
+}
+
+// layoutMoves are the keys that move focus between layout cells.
+var layoutMoves = map[string]layout.Move{
+	"alt+left": layout.Left, "alt+h": layout.Left,
+	"alt+right": layout.Right, "alt+l": layout.Right,
+	"alt+up": layout.Up, "alt+k": layout.Up,
+	"alt+down": layout.Down, "alt+j": layout.Down,
 }
 
 // InitialModel creates the initial state of the application.
 func InitialModel() Model {
 	m := Model{
-		Tabs:           []string{"Dashboard", "HTTP", "Git", "Docker", "Kind", "Nvim"},
+		Tabs:           []string{"Dashboard", "HTTP", "gRPC", "Tasks", "Git", "Docker", "Kind", "Nvim"},
 		ActiveTab:      0,
 		DashboardModel: dashboard.Model{},
 		HTTPModel:      http.New(),
+		GRPCModel:      grpc.New(),
+		TasksModel:     tasks.New(),
 		GitModel:       git.New(),
 		DockerModel:    docker.New(),
 		KindModel:      kind.New(),
@@ -54,6 +86,8 @@ func (m Model) Init() tea.Cmd {
 	return tea.Batch(
 		m.DashboardModel.Init(),
 		m.HTTPModel.Init(),
+		m.GRPCModel.Init(),
+		m.TasksModel.Init(),
 		m.KindModel.Init(),
 		app.CheckBinary(m.GitModel.BinaryName),
 		app.CheckBinary(m.DockerModel.BinaryName),
@@ -71,30 +105,42 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch {
-		case key.Matches(msg, key.NewBinding(key.WithKeys("ctrl+c", "q"))):
+		case msg.String() == "ctrl+c" && (m.HTTPModel.Sending || m.HTTPModel.Running()) && m.focusedTab() == "HTTP":
+			// Cancels the request or collection run in flight rather than quitting.
+			return m, m.updateTab("HTTP", msg)
+		case msg.String() == "ctrl+c" && m.GRPCModel.Calling && m.focusedTab() == "gRPC":
+			return m, m.updateTab("gRPC", msg)
+		case msg.String() == "ctrl+c", msg.String() == "q" && !m.editing():
 			return m, tea.Quit
 		case key.Matches(msg, key.NewBinding(key.WithKeys("tab"))):
 			m.ActiveTab = (m.ActiveTab + 1) % len(m.Tabs)
+			m.resize()
 			return m, nil
 		case key.Matches(msg, key.NewBinding(key.WithKeys("shift+tab"))):
 			m.ActiveTab--
 			if m.ActiveTab < 0 {
 				m.ActiveTab = len(m.Tabs) - 1
 			}
+			m.resize()
 			return m, nil
 		}
+		if m.inLayout() {
+			if move, ok := layoutMoves[msg.String()]; ok {
+				m.FocusedCell = layout.Neighbor(m.LayoutCells, m.FocusedCell, move)
+				return m, nil
+			}
+			if m.FocusedCell < len(m.LayoutCells) {
+				cmd = m.updateTab(m.resolveTab(m.LayoutCells[m.FocusedCell].Panel), msg)
+			}
+			return m, cmd
+		}
 
 	case tea.WindowSizeMsg:
 		m.Width = msg.Width
 		m.Height = msg.Height
 		m.Ready = true
-		modelHeight := m.Height - 5 // Account for header and footer
-		m.DashboardModel.Width, m.DashboardModel.Height = m.Width, modelHeight
-		m.HTTPModel.SetSize(m.Width, modelHeight)
-		m.GitModel.Width, m.GitModel.Height = m.Width, modelHeight
-		m.DockerModel.Width, m.DockerModel.Height = m.Width, modelHeight
-		m.KindModel.Width, m.KindModel.Height = m.Width, modelHeight
-		m.NvimModel.Width, m.NvimModel.Height = m.Width, modelHeight
+		m.resize()
+		return m, nil
 
 	// Custom messages
 	case app.CheckBinaryMsg:
@@ -109,30 +155,67 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.NvimModel.IsInstalled = msg.Found
 		}
 	case config.ConfigLoadedMsg:
-		m.HTTPModel.Collections.SetItems(msg.Templates)
-		m.HTTPModel.Environment = msg.Environment
-	}
+		m.HTTPModel.SetTemplates(msg.Templates)
+		cmds = append(cmds, m.HTTPModel.SetSecretsFile(msg.SecretsFile))
+		m.HTTPModel.SetEnvironment(msg.Environment)
+		m.HTTPModel.SetEnvironments(msg.Environments, msg.ActiveEnvironment)
+		m.HTTPModel.Timeouts = msg.Timeouts
+		cmds = append(cmds, m.HTTPModel.SetOpenAPI(msg.OpenAPI))
+		m.GRPCModel.Timeout = msg.Timeouts.Total
+		cmds = append(cmds, m.GRPCModel.SetConfig(msg.GRPC))
+		m.TasksModel.SetCommands(msg.Commands)
+		cmds = append(cmds, m.addPanels(msg.Panels))
+		if msg.Layout != nil {
+			m.Layout = msg.Layout
+			m.Tabs = append([]string{layoutTab}, m.Tabs...)
+			m.ActiveTab = 0
+			m.FocusedCell = 0
+		}
+		m.resize()
 
-	// Delegate updates to the active model
-	switch m.Tabs[m.ActiveTab] {
-	case "Dashboard":
-		m.DashboardModel, cmd = m.DashboardModel.Update(msg)
-	case "HTTP":
+	// Custom panels refresh on their own schedule, whichever tab is active.
+	case panel.RefreshMsg, panel.ContentMsg:
+		for i := range m.Panels {
+			m.Panels[i], cmd = m.Panels[i].Update(msg)
+			cmds = append(cmds, cmd)
+		}
+		return m, tea.Batch(cmds...)
+
+	// HTTP results must not be lost when the HTTP tab is hidden.
+	case http.HTTPResponseMsg, http.HistoryLoadedMsg, http.HTTPFilesLoadedMsg, http.HTTPFileSavedMsg, http.OpenAPILoadedMsg, http.RunStepMsg, http.SecretsUnlockedMsg, http.EnvironmentsLoadedMsg,
+		http.WSConnectedMsg, http.WSFrameMsg, http.WSClosedMsg, http.StreamMsg, http.GraphQLSchemaMsg:
 		m.HTTPModel, cmd = m.HTTPModel.Update(msg)
-	case "Git":
-		m.GitModel, cmd = m.GitModel.Update(msg)
-	case "Docker":
-		m.DockerModel, cmd = m.DockerModel.Update(msg)
-	case "Kind":
-		var updated tea.Model
-		updated, cmd = m.KindModel.Update(msg)
-		if km, ok := updated.(kind.Model); ok {
-			m.KindModel = km
+		return m, cmd
+
+	// gRPC results must not be lost when the gRPC tab is hidden. Finished
+	// calls go into the history of the HTTP tab, and open back in the gRPC tab.
+	case grpc.MethodsLoadedMsg, grpc.ResponseMsg:
+		m.GRPCModel, cmd = m.GRPCModel.Update(msg)
+		return m, cmd
+	case grpc.CallDoneMsg:
+		m.GRPCModel, cmd = m.GRPCModel.Update(msg)
+		return m, tea.Batch(cmd, m.HTTPModel.AddHistory(msg.HistoryEntry()))
+	case http.OpenGRPCMsg:
+		m.GRPCModel.Load(msg.Entry)
+		if !m.inLayout() {
+			for i, t := range m.Tabs {
+				if t == "gRPC" {
+					m.ActiveTab = i
+				}
+			}
 		}
-	case "Nvim":
-		m.NvimModel, cmd = m.NvimModel.Update(msg)
+		return m, nil
+
+	// Task output keeps streaming while the Tasks tab is hidden.
+	case tasks.OutputMsg, tasks.DoneMsg:
+		m.TasksModel, cmd = m.TasksModel.Update(msg)
+		return m, cmd
+	}
+
+	// Delegate updates to the active model, or to every model visible in the layout
+	for _, name := range m.visibleTabs() {
+		cmds = append(cmds, m.updateTab(name, msg))
 	}
-	cmds = append(cmds, cmd)
 
 	return m, tea.Batch(cmds...)
 }
@@ -154,22 +237,228 @@ func (m Model) View() string {
 	tabHeader := lipgloss.JoinHorizontal(lipgloss.Top, renderedTabs...)
 
 	var tabContent string
-	switch m.Tabs[m.ActiveTab] {
+	if m.inLayout() {
+		tabContent = m.Layout.Render(m.Width-4, m.Height-5, func(i int, c layout.Cell) string {
+			style := styles.BlurredPaneStyle
+			if i == m.FocusedCell {
+				style = styles.FocusedPaneStyle
+			}
+			content := lipgloss.NewStyle().MaxWidth(c.Width - 2).MaxHeight(c.Height - 2).Render(m.viewTab(m.resolveTab(c.Panel)))
+			return style.Width(c.Width - 2).Height(c.Height - 2).Render(content)
+		})
+	} else {
+		tabContent = m.viewTab(m.Tabs[m.ActiveTab])
+	}
+
+	help := "Tab/Shift+Tab: Switch"
+	if m.inLayout() {
+		help += " | Alt+Arrows: Focus"
+	}
+	statusBar := lipgloss.NewStyle().Foreground(lipgloss.Color("#FFF")).Background(lipgloss.Color("57")).Width(m.Width).Render(fmt.Sprintf("Phantom | %s | q: Quit | Time: %s", help, time.Now().Format("15:04:05")))
+
+	return lipgloss.JoinVertical(lipgloss.Left, tabHeader, styles.DocStyle.Render(tabContent), statusBar)
+}
+
+// updateTab delegates msg to the model behind the named tab.
+func (m *Model) updateTab(name string, msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	switch name {
+	case "Dashboard":
+		m.DashboardModel, cmd = m.DashboardModel.Update(msg)
+	case "HTTP":
+		m.HTTPModel, cmd = m.HTTPModel.Update(msg)
+	case "gRPC":
+		m.GRPCModel.Environment = m.HTTPModel.Environment
+		m.GRPCModel, cmd = m.GRPCModel.Update(msg)
+	case "Tasks":
+		m.TasksModel, cmd = m.TasksModel.Update(msg)
+	case "Git":
+		m.GitModel, cmd = m.GitModel.Update(msg)
+	case "Docker":
+		m.DockerModel, cmd = m.DockerModel.Update(msg)
+	case "Kind":
+		var updated tea.Model
+		updated, cmd = m.KindModel.Update(msg)
+		if km, ok := updated.(kind.Model); ok {
+			m.KindModel = km
+		}
+	case "Nvim":
+		m.NvimModel, cmd = m.NvimModel.Update(msg)
+	default:
+		if i := m.panelIndex(name); i >= 0 {
+			m.Panels[i], cmd = m.Panels[i].Update(msg)
+		}
+	}
+	return cmd
+}
+
+// viewTab renders the model behind the named tab.
+func (m Model) viewTab(name string) string {
+	switch name {
+	case "Dashboard":
+		return m.DashboardModel.View()
+	case "HTTP":
+		return m.HTTPModel.View()
+	case "gRPC":
+		return m.GRPCModel.View()
+	case "Tasks":
+		return m.TasksModel.View()
+	case "Git":
+		return m.GitModel.View()
+	case "Docker":
+		return m.DockerModel.View()
+	case "Kind":
+		return m.KindModel.View()
+	case "Nvim":
+		return m.NvimModel.View()
+	}
+	if i := m.panelIndex(name); i >= 0 {
+		return m.Panels[i].View()
+	}
+	return styles.ErrorStyle.Render(fmt.Sprintf("Unknown panel %q", name))
+}
+
+// setTabSize resizes the model behind the named tab.
+func (m *Model) setTabSize(name string, w, h int) {
+	switch name {
 	case "Dashboard":
-		tabContent = m.DashboardModel.View()
+		m.DashboardModel.SetSize(w, h)
 	case "HTTP":
-		tabContent = m.HTTPModel.View()
+		m.HTTPModel.SetSize(w, h)
+	case "gRPC":
+		m.GRPCModel.SetSize(w, h)
+	case "Tasks":
+		m.TasksModel.SetSize(w, h)
 	case "Git":
-		tabContent = m.GitModel.View()
+		m.GitModel.SetSize(w, h)
 	case "Docker":
-		tabContent = m.DockerModel.View()
+		m.DockerModel.SetSize(w, h)
 	case "Kind":
-		tabContent = m.KindModel.View()
+		m.KindModel.SetSize(w, h)
 	case "Nvim":
-		tabContent = m.NvimModel.View()
+		m.NvimModel.SetSize(w, h)
+	default:
+		if i := m.panelIndex(name); i >= 0 {
+			m.Panels[i].SetSize(w, h)
+		}
+	}
+}
+
+// resize sizes every model for the full content area, then shrinks the
+// models shown in the layout to their cells when the layout tab is active.
+func (m *Model) resize() {
+	if !m.Ready {
+		return
+	}
+	modelHeight := m.Height - 5 // Account for header and footer
+	for _, t := range m.Tabs {
+		m.setTabSize(t, m.Width, modelHeight)
+	}
+	if !m.inLayout() {
+		return
 	}
+	m.LayoutCells = m.Layout.Cells(m.Width-4, modelHeight)
+	for _, c := range m.LayoutCells {
+		m.setTabSize(m.resolveTab(c.Panel), c.Width-2, c.Height-2)
+	}
+	if m.FocusedCell >= len(m.LayoutCells) {
+		m.FocusedCell = 0
+	}
+}
 
-	statusBar := lipgloss.NewStyle().Foreground(lipgloss.Color("#FFF")).Background(lipgloss.Color("57")).Width(m.Width).Render(fmt.Sprintf("Phantom | Tab/Shift+Tab: Switch | q: Quit | Time: %s", time.Now().Format("15:04:05")))
+func (m Model) inLayout() bool {
+	return m.Layout != nil && m.Tabs[m.ActiveTab] == layoutTab
+}
 
-	return lipgloss.JoinVertical(lipgloss.Left, tabHeader, styles.DocStyle.Render(tabContent), statusBar)
+// editing reports whether the focused tab is taking typed text, in which
+// case q is a letter rather than quit.
+func (m Model) editing() bool {
+	switch m.focusedTab() {
+	case "HTTP":
+		return m.HTTPModel.Editing()
+	case "gRPC":
+		return m.GRPCModel.Editing()
+	case "Tasks":
+		return m.TasksModel.Editing()
+	case "Kind":
+		return m.KindModel.Editing()
+	}
+	return false
+}
+
+// focusedTab is the tab keys go to: the active one, or the one in the
+// focused cell of the layout.
+func (m Model) focusedTab() string {
+	if m.inLayout() {
+		if m.FocusedCell < len(m.LayoutCells) {
+			return m.resolveTab(m.LayoutCells[m.FocusedCell].Panel)
+		}
+		return ""
+	}
+	return m.Tabs[m.ActiveTab]
+}
+
+// visibleTabs lists the distinct tabs currently on screen.
+func (m Model) visibleTabs() []string {
+	if !m.inLayout() {
+		return []string{m.Tabs[m.ActiveTab]}
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, c := range m.LayoutCells {
+		name := m.resolveTab(c.Panel)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// resolveTab maps a panel name from Config.layout onto a tab name.
+func (m Model) resolveTab(panelName string) string {
+	if name, ok := layoutAliases[strings.ToLower(panelName)]; ok {
+		panelName = name
+	}
+	for _, t := range m.Tabs {
+		if strings.EqualFold(t, panelName) && t != layoutTab {
+			return t
+		}
+	}
+	return panelName
+}
+
+// addPanels creates a tab for every custom panel registered in config.lua.
+// Panels whose name clashes with an existing tab are skipped.
+func (m *Model) addPanels(panels []config.Panel) tea.Cmd {
+	var cmds []tea.Cmd
+	for _, p := range panels {
+		if m.hasTab(p.Name) {
+			log.Printf("panel %q clashes with an existing tab, skipping", p.Name)
+			continue
+		}
+		pm := panel.New(p.Name, p.Interval, p.Render)
+		m.Panels = append(m.Panels, pm)
+		m.Tabs = append(m.Tabs, p.Name)
+		cmds = append(cmds, pm.Init())
+	}
+	return tea.Batch(cmds...)
+}
+
+func (m Model) hasTab(name string) bool {
+	for _, t := range m.Tabs {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (m Model) panelIndex(name string) int {
+	for i, p := range m.Panels {
+		if p.Name == name {
+			return i
+		}
+	}
+	return -1
 }
@@ -0,0 +1,72 @@
+package ui
+
+import (
+	"testing"
+
+	"phantom/internal/ui/tabs/tasks"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// quits reports whether cmd, or one of the commands it batches, quits.
+func quits(cmd tea.Cmd) bool {
+	if cmd == nil {
+		return false
+	}
+	switch msg := cmd().(type) {
+	case tea.QuitMsg:
+		return true
+	case tea.BatchMsg:
+		for _, c := range msg {
+			if quits(c) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestQuitKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		tab   string
+		keys  string // typed before q
+		setup func(m *Model)
+		want  bool
+	}{
+		{name: "dashboard", tab: "Dashboard", want: true},
+		{name: "tasks list", tab: "Tasks", want: true},
+		{name: "tasks filter", tab: "Tasks", keys: "/te"},
+		{name: "http method selector", tab: "HTTP", want: true},
+		{name: "http url", tab: "HTTP", setup: func(m *Model) { m.HTTPModel.FocusedInput = 1 }},
+		{name: "http history search", tab: "HTTP", setup: func(m *Model) { m.HTTPModel.FocusedPane, m.HTTPModel.ListFocus = 0, 1 }, keys: "/"},
+		{name: "grpc services", tab: "gRPC", setup: func(m *Model) { m.GRPCModel.FocusedPane = 0 }, want: true},
+		{name: "grpc message", tab: "gRPC", setup: func(m *Model) { m.GRPCModel.FocusedPane = 1 }},
+		{name: "kind list", tab: "Kind", want: true},
+		{name: "kind prompt", tab: "Kind", keys: "n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_DATA_HOME", t.TempDir())
+			m := InitialModel()
+			m.TasksModel.SetCommands([]tasks.Command{{Name: "test", Command: "go test ./..."}})
+			m.resize()
+			for i, name := range m.Tabs {
+				if name == tt.tab {
+					m.ActiveTab = i
+				}
+			}
+			if tt.setup != nil {
+				tt.setup(&m)
+			}
+			for _, r := range tt.keys {
+				next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+				m = next.(Model)
+			}
+			_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'q'}})
+			if got := quits(cmd); got != tt.want {
+				t.Errorf("q quits = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -72,6 +72,11 @@ func (m Model) View() string {
 	)
 }
 
+// SetSize sets the size of the dashboard model.
+func (m *Model) SetSize(w, h int) {
+	m.Width, m.Height = w, h
+}
+
 // Helper functions for system info
 func TickCmd() tea.Cmd {
 	return tea.Tick(time.Second*2, func(t time.Time) tea.Msg { return TickMsg(t) })
@@ -0,0 +1,300 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"phantom/internal/secrets"
+	"phantom/internal/ui/tabs/http"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// errCancelled is the cause of calls stopped from the keyboard.
+var errCancelled = errors.New("cancelled")
+
+// dial opens a client for address. grpcs:// and https:// addresses use TLS,
+// anything else is plaintext, as local development servers usually are.
+func dial(address string) (*grpc.ClientConn, error) {
+	target, secure := splitAddress(address)
+	if target == "" {
+		return nil, errors.New("no address: type host:port in the Address field")
+	}
+	creds := insecure.NewCredentials()
+	if secure {
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+	return grpc.NewClient(target, grpc.WithTransportCredentials(creds))
+}
+
+// splitAddress strips the scheme off address and tells whether it asks for TLS.
+func splitAddress(address string) (target string, secure bool) {
+	address = strings.TrimSpace(address)
+	for _, scheme := range []string{"grpcs://", "https://"} {
+		if strings.HasPrefix(address, scheme) {
+			return strings.TrimSuffix(strings.TrimPrefix(address, scheme), "/"), true
+		}
+	}
+	for _, scheme := range []string{"grpc://", "http://"} {
+		address = strings.TrimPrefix(address, scheme)
+	}
+	return strings.TrimSuffix(address, "/"), false
+}
+
+// withMetadata adds the "key: value" lines of text to the outgoing metadata
+// of ctx. Keys are lowercased, as gRPC requires.
+func withMetadata(ctx context.Context, text string) context.Context {
+	header, err := http.ParseHeaders(text)
+	if err != nil || len(header) == 0 {
+		return ctx
+	}
+	md := metadata.MD{}
+	for k, v := range header {
+		md.Append(strings.ToLower(k), v...)
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// Call is one invocation of a method, as sent.
+type Call struct {
+	Address  string
+	Method   string // full name, e.g. "/shop.v1.Orders/Get"
+	Metadata string
+	Request  string // JSON
+}
+
+// URL names the call in the history, e.g. "grpc://localhost:50051/shop.v1.Orders/Get".
+func (c Call) URL() string {
+	target, secure := splitAddress(c.Address)
+	scheme := "grpc://"
+	if secure {
+		scheme = "grpcs://"
+	}
+	return scheme + target + c.Method
+}
+
+// ResponseMsg carries the messages a server-streaming call received so far.
+type ResponseMsg struct {
+	Stream   *callStream
+	Messages []string
+}
+
+// CallDoneMsg is sent when a call ends, with its status and metadata.
+type CallDoneMsg struct {
+	Stream    *callStream
+	Call      Call // as typed, before substitution
+	Code      codes.Code
+	Message   string // of the status, for codes other than OK
+	Header    metadata.MD
+	Trailer   metadata.MD
+	Messages  []string // every response, as JSON
+	Duration  time.Duration
+	Cancelled bool
+	Err       error // the request could not be made at all, e.g. invalid JSON
+}
+
+// callStream carries the responses of a call, then its CallDoneMsg.
+type callStream struct {
+	messages chan string
+	done     chan CallDoneMsg
+}
+
+// invoke substitutes env into call, sends it to m and streams back what it
+// answers. Unary calls are limited to timeout; streams run until the server
+// ends them or ctx is cancelled.
+func invoke(ctx context.Context, m Method, call Call, env map[string]string, timeout time.Duration) *callStream {
+	st := &callStream{messages: make(chan string, 256), done: make(chan CallDoneMsg, 1)}
+	go func() {
+		start := time.Now()
+		done := CallDoneMsg{Stream: st, Call: call}
+		sent := Call{
+			Address:  http.Substitute(call.Address, env),
+			Method:   call.Method,
+			Metadata: http.Substitute(call.Metadata, env),
+			Request:  http.Substitute(call.Request, env),
+		}
+		done.Header, done.Trailer, done.Err = run(ctx, m, sent, timeout, func(resp string) {
+			done.Messages = append(done.Messages, resp)
+			if over := len(done.Messages) - maxMessages; over > 0 {
+				done.Messages = done.Messages[over:]
+			}
+			st.messages <- resp
+		})
+		close(st.messages)
+		done.Duration = time.Since(start)
+		if s, ok := status.FromError(done.Err); ok && done.Err != nil {
+			done.Code, done.Message, done.Err = s.Code(), s.Message(), nil
+		} else if done.Err != nil {
+			done.Code = codes.Unknown
+		}
+		done.Cancelled = errors.Is(context.Cause(ctx), errCancelled)
+		st.done <- done
+	}()
+	return st
+}
+
+// run makes the call, handing each response to received as JSON. Errors
+// of the call itself are gRPC statuses; any other error means it was never
+// sent.
+func run(ctx context.Context, m Method, call Call, timeout time.Duration, received func(string)) (header, trailer metadata.MD, err error) {
+	switch {
+	case m.Desc == nil:
+		return nil, nil, fmt.Errorf("unknown method %s: load the services first", call.Method)
+	case m.Desc.IsStreamingClient():
+		return nil, nil, fmt.Errorf("%s calls are not supported", m.Kind())
+	}
+	req := dynamicpb.NewMessage(m.Desc.Input())
+	text := call.Request
+	if strings.TrimSpace(text) == "" {
+		text = "{}"
+	}
+	if err := (protojson.UnmarshalOptions{Resolver: m.types}).Unmarshal([]byte(text), req); err != nil {
+		return nil, nil, fmt.Errorf("request message: %w", err)
+	}
+	conn, err := dial(call.Address)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+	ctx = withMetadata(ctx, call.Metadata)
+	format := protojson.MarshalOptions{Resolver: m.types}
+
+	if !m.Desc.IsStreamingServer() {
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		resp := dynamicpb.NewMessage(m.Desc.Output())
+		err := conn.Invoke(ctx, call.Method, req, resp, grpc.Header(&header), grpc.Trailer(&trailer))
+		if err == nil {
+			received(formatJSON(format, resp))
+		}
+		return header, trailer, err
+	}
+
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, call.Method)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := stream.SendMsg(req); err != nil && err != io.EOF { // on EOF, RecvMsg has the status
+		return nil, nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, nil, err
+	}
+	for {
+		resp := dynamicpb.NewMessage(m.Desc.Output())
+		err = stream.RecvMsg(resp)
+		if err != nil {
+			break
+		}
+		received(formatJSON(format, resp))
+	}
+	header, _ = stream.Header()
+	trailer = stream.Trailer()
+	if err == io.EOF {
+		err = nil
+	}
+	return header, trailer, err
+}
+
+// formatJSON renders msg as indented JSON. protojson varies its own
+// whitespace on purpose, so it is indented here instead.
+func formatJSON(opts protojson.MarshalOptions, msg proto.Message) string {
+	b, err := opts.Marshal(msg)
+	if err != nil {
+		return err.Error()
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, b, "", "  "); err != nil {
+		return string(b)
+	}
+	return out.String()
+}
+
+// wait returns a command that delivers the next responses of st, or its
+// CallDoneMsg once the call is over.
+func (st *callStream) wait() tea.Cmd {
+	return func() tea.Msg {
+		resp, ok := <-st.messages
+		if !ok {
+			return <-st.done
+		}
+		msg := ResponseMsg{Stream: st, Messages: []string{resp}}
+		// Coalesce whatever else is already buffered to keep redraws down.
+		for len(msg.Messages) < 500 {
+			select {
+			case resp, ok := <-st.messages:
+				if !ok {
+					return msg
+				}
+				msg.Messages = append(msg.Messages, resp)
+			default:
+				return msg
+			}
+		}
+		return msg
+	}
+}
+
+// formatMetadata renders md as sorted "key: value" lines.
+func formatMetadata(md metadata.MD) string {
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		for _, v := range md[k] {
+			fmt.Fprintf(&b, "%s: %s\n", k, v)
+		}
+	}
+	return b.String()
+}
+
+// HistoryEntry records the outcome of a call in the shared request history.
+func (msg CallDoneMsg) HistoryEntry() http.HistoryEntry {
+	body := strings.Join(msg.Messages, "\n")
+	e := http.HistoryEntry{
+		Time:       time.Now(),
+		Method:     http.MethodGRPC,
+		URL:        secrets.Mask(msg.Call.URL()),
+		Headers:    secrets.Mask(msg.Call.Metadata),
+		Body:       secrets.Mask(msg.Call.Request),
+		Status:     int(msg.Code),
+		StatusText: msg.Code.String(),
+		Proto:      "gRPC",
+		Error:      secrets.Mask(msg.Message),
+		DurationMS: msg.Duration.Milliseconds(),
+		Size:       len(body),
+		Response:   &http.Snapshot{Headers: secrets.Mask(formatMetadata(msg.Header) + formatMetadata(msg.Trailer)), Body: secrets.Mask(body)},
+	}
+	if msg.Err != nil {
+		e.Error = secrets.Mask(msg.Err.Error())
+	}
+	if msg.Cancelled {
+		e.Outcome = "cancelled"
+	} else if msg.Code == codes.DeadlineExceeded {
+		e.Outcome = "timeout"
+	}
+	return e
+}
@@ -0,0 +1,379 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"phantom/internal/ui/tabs/http"
+
+	"github.com/bufbuild/protocompile"
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/grpc/codes"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	rpbalpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Method is one RPC of a discovered service.
+type Method struct {
+	Service string // fully qualified, e.g. "shop.v1.Orders"
+	Name    string
+	Source  string // "reflection" or the .proto file that declared it
+	Desc    protoreflect.MethodDescriptor
+	types   *dynamicpb.Types // resolves the messages of Desc, and the ones packed in Any
+}
+
+// FullName is the name the method is invoked by, e.g. "/shop.v1.Orders/Get".
+func (m Method) FullName() string {
+	return "/" + m.Service + "/" + m.Name
+}
+
+// Kind describes how the method streams.
+func (m Method) Kind() string {
+	switch {
+	case m.Desc.IsStreamingClient() && m.Desc.IsStreamingServer():
+		return "bidi streaming"
+	case m.Desc.IsStreamingClient():
+		return "client streaming"
+	case m.Desc.IsStreamingServer():
+		return "server streaming"
+	}
+	return "unary"
+}
+
+// methodItem adapts a Method to the services list.
+type methodItem struct{ Method }
+
+func (i methodItem) Title() string { return i.Service + "/" + i.Name }
+func (i methodItem) Description() string {
+	return fmt.Sprintf("%s · %s → %s · %s", i.Kind(), i.Desc.Input().Name(), i.Desc.Output().Name(), i.Source)
+}
+func (i methodItem) FilterValue() string { return i.Service + "/" + i.Name }
+
+// MethodsLoadedMsg carries the methods discovered from one source: the
+// .proto files of the project, or the reflection service of Address.
+type MethodsLoadedMsg struct {
+	Address string // empty for .proto files
+	Methods []Method
+	Errs    []string // files that failed to compile, reported without failing the rest
+	Err     error
+}
+
+// methods lists the RPCs of the services in files, sorted by name.
+func methods(files *protoregistry.Files, source func(protoreflect.FileDescriptor) string, only map[string]bool) []Method {
+	types := dynamicpb.NewTypes(files)
+	var out []Method
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		for i := 0; i < fd.Services().Len(); i++ {
+			sd := fd.Services().Get(i)
+			if only != nil && !only[string(sd.FullName())] {
+				continue
+			}
+			for j := 0; j < sd.Methods().Len(); j++ {
+				md := sd.Methods().Get(j)
+				out = append(out, Method{Service: string(sd.FullName()), Name: string(md.Name()), Source: source(fd), Desc: md, types: types})
+			}
+		}
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].FullName() < out[j].FullName() })
+	return out
+}
+
+// skipDirs are not searched for .proto files.
+var skipDirs = map[string]bool{".git": true, "node_modules": true, "vendor": true, "third_party": true, "dist": true, "build": true}
+
+// loadProtoFiles compiles the .proto files under the working directory.
+// Imports are resolved against importPaths, the working directory by
+// default, and the well-known types bundled with the compiler.
+func loadProtoFiles(importPaths []string) tea.Cmd {
+	return func() tea.Msg {
+		if len(importPaths) == 0 {
+			importPaths = []string{"."}
+		}
+		var names []string
+		err := filepath.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() && path != "." && (skipDirs[d.Name()] || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			if !d.IsDir() && strings.HasSuffix(path, ".proto") {
+				names = append(names, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return MethodsLoadedMsg{Err: err}
+		}
+		msg := MethodsLoadedMsg{}
+		files := new(protoregistry.Files)
+		compiler := protocompile.Compiler{
+			Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{ImportPaths: importPaths}),
+		}
+		// One file at a time, so that a broken file only loses its own services.
+		for _, path := range names {
+			name, ok := importName(path, importPaths)
+			if !ok {
+				continue // outside the import paths, as generated or vendored copies often are
+			}
+			compiled, err := compiler.Compile(context.Background(), name)
+			if err != nil {
+				msg.Errs = append(msg.Errs, err.Error())
+				continue
+			}
+			for _, fd := range compiled {
+				register(files, fd)
+			}
+		}
+		msg.Methods = methods(files, func(fd protoreflect.FileDescriptor) string { return fd.Path() }, nil)
+		return msg
+	}
+}
+
+// importName is path relative to the first import path containing it, the
+// name other files import it by.
+func importName(path string, importPaths []string) (string, bool) {
+	for _, dir := range importPaths {
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel), true
+		}
+	}
+	return "", false
+}
+
+// register adds fd and its imports to files, skipping the ones already there.
+func register(files *protoregistry.Files, fd protoreflect.FileDescriptor) {
+	if _, err := files.FindFileByPath(fd.Path()); err == nil {
+		return
+	}
+	for i := 0; i < fd.Imports().Len(); i++ {
+		register(files, fd.Imports().Get(i).FileDescriptor)
+	}
+	files.RegisterFile(fd) // a conflicting duplicate keeps the first definition
+}
+
+// loadReflection lists the services of the server at address through its
+// reflection service, trying v1 first and v1alpha for older servers. env is
+// substituted into address and metadata.
+func loadReflection(address, metadata string, env map[string]string, timeout time.Duration) tea.Cmd {
+	return func() tea.Msg {
+		msg := MethodsLoadedMsg{Address: address}
+		metadata = http.Substitute(metadata, env)
+		conn, err := dial(http.Substitute(address, env))
+		if err != nil {
+			msg.Err = err
+			return msg
+		}
+		defer conn.Close()
+		ctx := withMetadata(context.Background(), metadata)
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		r := &reflector{stream: v1Stream{rpb.NewServerReflectionClient(conn)}, ctx: ctx}
+		services, err := r.listServices()
+		if err != nil && status.Code(err) == codes.Unimplemented {
+			r = &reflector{stream: alphaStream{rpbalpha.NewServerReflectionClient(conn)}, ctx: ctx}
+			services, err = r.listServices()
+		}
+		if err != nil {
+			msg.Err = fmt.Errorf("server reflection: %w", err)
+			return msg
+		}
+		only := make(map[string]bool)
+		for _, s := range services {
+			if s == "grpc.reflection.v1.ServerReflection" || s == "grpc.reflection.v1alpha.ServerReflection" {
+				continue
+			}
+			only[s] = true
+			if err := r.fileContainingSymbol(s); err != nil {
+				msg.Errs = append(msg.Errs, fmt.Sprintf("%s: %v", s, err))
+			}
+		}
+		files, err := protodesc.NewFiles(&descriptorpb.FileDescriptorSet{File: r.fileList()})
+		if err != nil {
+			msg.Err = fmt.Errorf("server reflection: %w", err)
+			return msg
+		}
+		msg.Methods = methods(files, func(protoreflect.FileDescriptor) string { return "reflection" }, only)
+		return msg
+	}
+}
+
+// reflectionStream is the part of a reflection stream the reflector uses,
+// so that v1alpha servers can be spoken to in v1 messages.
+type reflectionStream interface {
+	Send(*rpb.ServerReflectionRequest) error
+	Recv() (*rpb.ServerReflectionResponse, error)
+}
+
+// reflector collects the file descriptors a reflection service returns,
+// along with their imports.
+type reflector struct {
+	stream interface {
+		open(context.Context) (reflectionStream, error)
+	}
+	ctx   context.Context
+	s     reflectionStream
+	files map[string]*descriptorpb.FileDescriptorProto
+	order []string
+}
+
+// roundTrip sends req and returns the answer, or the error the server gave.
+func (r *reflector) roundTrip(req *rpb.ServerReflectionRequest) (*rpb.ServerReflectionResponse, error) {
+	if r.s == nil {
+		s, err := r.stream.open(r.ctx)
+		if err != nil {
+			return nil, err
+		}
+		r.s = s
+	}
+	if err := r.s.Send(req); err != nil {
+		if err == io.EOF { // the real error comes from Recv
+			_, err = r.s.Recv()
+		}
+		return nil, err
+	}
+	resp, err := r.s.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if e := resp.GetErrorResponse(); e != nil {
+		return nil, status.Error(codes.Code(e.ErrorCode), e.ErrorMessage)
+	}
+	return resp, nil
+}
+
+func (r *reflector) listServices() ([]string, error) {
+	resp, err := r.roundTrip(&rpb.ServerReflectionRequest{MessageRequest: &rpb.ServerReflectionRequest_ListServices{}})
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		names = append(names, s.GetName())
+	}
+	return names, nil
+}
+
+func (r *reflector) fileContainingSymbol(symbol string) error {
+	resp, err := r.roundTrip(&rpb.ServerReflectionRequest{MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: symbol}})
+	if err != nil {
+		return err
+	}
+	return r.add(resp)
+}
+
+// add keeps the files of resp, then fetches the imports not seen yet.
+// Imports the server does not know, such as well-known types, are taken
+// from the ones compiled into phantom.
+func (r *reflector) add(resp *rpb.ServerReflectionResponse) error {
+	if r.files == nil {
+		r.files = make(map[string]*descriptorpb.FileDescriptorProto)
+	}
+	var deps []string
+	for _, raw := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+		fd := new(descriptorpb.FileDescriptorProto)
+		if err := proto.Unmarshal(raw, fd); err != nil {
+			return err
+		}
+		if _, ok := r.files[fd.GetName()]; ok {
+			continue
+		}
+		r.files[fd.GetName()] = fd
+		r.order = append(r.order, fd.GetName())
+		deps = append(deps, fd.GetDependency()...)
+	}
+	for _, dep := range deps {
+		if _, ok := r.files[dep]; ok {
+			continue
+		}
+		resp, err := r.roundTrip(&rpb.ServerReflectionRequest{MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: dep}})
+		if err != nil {
+			known, findErr := protoregistry.GlobalFiles.FindFileByPath(dep)
+			if findErr != nil {
+				return fmt.Errorf("import %s: %w", dep, err)
+			}
+			r.files[dep] = protodesc.ToFileDescriptorProto(known)
+			r.order = append(r.order, dep)
+			continue
+		}
+		if err := r.add(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *reflector) fileList() []*descriptorpb.FileDescriptorProto {
+	list := make([]*descriptorpb.FileDescriptorProto, len(r.order))
+	for i, name := range r.order {
+		list[i] = r.files[name]
+	}
+	return list
+}
+
+type v1Stream struct{ client rpb.ServerReflectionClient }
+
+func (s v1Stream) open(ctx context.Context) (reflectionStream, error) {
+	return s.client.ServerReflectionInfo(ctx)
+}
+
+// alphaStream speaks v1alpha, whose messages are wire compatible with v1.
+type alphaStream struct {
+	client rpbalpha.ServerReflectionClient
+}
+
+func (s alphaStream) open(ctx context.Context) (reflectionStream, error) {
+	st, err := s.client.ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return alphaAdapter{st}, nil
+}
+
+type alphaAdapter struct {
+	rpbalpha.ServerReflection_ServerReflectionInfoClient
+}
+
+func (a alphaAdapter) Send(req *rpb.ServerReflectionRequest) error {
+	var alpha rpbalpha.ServerReflectionRequest
+	if err := convert(req, &alpha); err != nil {
+		return err
+	}
+	return a.ServerReflection_ServerReflectionInfoClient.Send(&alpha)
+}
+
+func (a alphaAdapter) Recv() (*rpb.ServerReflectionResponse, error) {
+	alpha, err := a.ServerReflection_ServerReflectionInfoClient.Recv()
+	if err != nil {
+		return nil, err
+	}
+	var resp rpb.ServerReflectionResponse
+	return &resp, convert(alpha, &resp)
+}
+
+func convert(from, to proto.Message) error {
+	b, err := proto.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, to)
+}
@@ -0,0 +1,587 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"phantom/internal/secrets"
+	"phantom/internal/ui/components/styles"
+	"phantom/internal/ui/tabs/http"
+	"phantom/internal/utils"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const maxMessages = 1000
+
+// Inputs of the request pane, in focus order.
+const (
+	addressInput = iota
+	metadataInput
+	messageInput
+	numInputs
+)
+
+var responseViews = []string{"Messages", "Metadata"}
+
+// Config is the grpc table of config.lua.
+type Config struct {
+	Address     string   // server to call, e.g. "localhost:50051"
+	Metadata    string   // "key: value" lines sent with every call
+	ImportPaths []string // where .proto imports are resolved, the project root by default
+}
+
+// Model represents the gRPC tab.
+type Model struct {
+	Width, Height int
+	Address       textinput.Model
+	Metadata      textarea.Model
+	Message       textarea.Model
+	Response      viewport.Model
+	Spinner       spinner.Model
+	// Environment is substituted into calls as {{name}}. It is the active
+	// environment of the HTTP tab.
+	Environment  map[string]string
+	Timeout      time.Duration // of unary calls
+	ImportPaths  []string
+	FocusedPane  int // 0: services, 1: request, 2: response
+	FocusedInput int
+	ResponseView int
+	Calling      bool
+	Notice       string
+	services     list.Model
+	fileMethods  []Method
+	reflected    map[string][]Method // by the address they were listed from
+	method       string              // full name of the selected method
+	template     string              // the request skeleton last put in the Message editor
+	cancel       context.CancelCauseFunc
+	stream       *callStream
+	// The current or last call
+	messages []string
+	received int
+	done     *CallDoneMsg
+}
+
+// New creates a new gRPC model.
+func New() Model {
+	m := Model{
+		Address:  textinput.New(),
+		Metadata: textarea.New(),
+		Message:  textarea.New(),
+		Response: viewport.New(0, 0),
+		Spinner:  spinner.New(spinner.WithSpinner(spinner.Dot), spinner.WithStyle(styles.SpinnerStyle)),
+		services: list.New(nil, list.NewDefaultDelegate(), 0, 0),
+	}
+	m.Address.Placeholder = "localhost:50051 · grpcs://api.example.com:443"
+	m.Metadata.Placeholder = "authorization: Bearer {{token}}"
+	m.Metadata.SetHeight(3)
+	m.Message.Placeholder = `{"id": "1"}`
+	m.Message.SetHeight(12)
+	m.services.Title = "Services"
+	m.services.SetShowHelp(false)
+	m.focus()
+	return m
+}
+
+// Init loads the services declared in the .proto files of the project.
+func (m Model) Init() tea.Cmd {
+	return loadProtoFiles(m.ImportPaths)
+}
+
+// SetConfig applies the grpc table of config.lua, reloading the .proto
+// files if their import paths changed.
+func (m *Model) SetConfig(c Config) tea.Cmd {
+	if c.Address != "" && m.Address.Value() == "" {
+		m.Address.SetValue(c.Address)
+	}
+	if c.Metadata != "" && m.Metadata.Value() == "" {
+		m.Metadata.SetValue(c.Metadata)
+	}
+	if strings.Join(c.ImportPaths, "\n") == strings.Join(m.ImportPaths, "\n") {
+		return nil
+	}
+	m.ImportPaths = c.ImportPaths
+	return loadProtoFiles(m.ImportPaths)
+}
+
+// Update handles messages for the gRPC model.
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if m.Calling {
+			if s := msg.String(); (s == "esc" || s == "ctrl+c") && m.cancel != nil {
+				m.cancel(errCancelled)
+				return m, nil
+			}
+		}
+		if m.services.FilterState() == list.Filtering {
+			break
+		}
+		switch msg.String() {
+		case "ctrl+l": // Switch focused pane
+			m.FocusedPane = (m.FocusedPane + 1) % 3
+			m.focus()
+			return m, nil
+		case "ctrl+s":
+			return m, m.invoke()
+		case "alt+r": // List the services of the server through reflection
+			return m, m.reflect()
+		case "alt+p": // Compile the .proto files again
+			m.Notice = styles.HelpStyle.Render("Loading .proto files…")
+			return m, loadProtoFiles(m.ImportPaths)
+		case "alt+t": // Reset the message to the skeleton of the request type
+			if method, ok := m.selected(); ok {
+				m.template = requestTemplate(method)
+				m.Message.SetValue(m.template)
+			}
+			return m, nil
+		}
+		switch m.FocusedPane {
+		case 1:
+			return m, m.updateRequestInputs(msg)
+		case 2:
+			switch msg.String() {
+			case "h", "left":
+				m.ResponseView = (m.ResponseView + len(responseViews) - 1) % len(responseViews)
+				m.refreshResponse(true)
+			case "l", "right":
+				m.ResponseView = (m.ResponseView + 1) % len(responseViews)
+				m.refreshResponse(true)
+			default:
+				m.Response, cmd = m.Response.Update(msg)
+			}
+			return m, cmd
+		}
+		if msg.String() == "enter" {
+			if item, ok := m.services.SelectedItem().(methodItem); ok {
+				m.selectMethod(item.Method)
+			}
+			return m, nil
+		}
+
+	case MethodsLoadedMsg:
+		if msg.Err != nil {
+			m.Notice = styles.ErrorStyle.Render(msg.Err.Error())
+			return m, nil
+		}
+		source := "the .proto files"
+		if msg.Address == "" {
+			m.fileMethods = msg.Methods
+		} else {
+			if m.reflected == nil {
+				m.reflected = make(map[string][]Method)
+			}
+			m.reflected[msg.Address] = msg.Methods
+			source = secrets.Mask(msg.Address)
+		}
+		switch {
+		case len(msg.Errs) > 0:
+			m.Notice = styles.ErrorStyle.Render(fmt.Sprintf("Loaded %d methods from %s, %d failed: %s", len(msg.Methods), source, len(msg.Errs), msg.Errs[0]))
+		case len(msg.Methods) > 0 || msg.Address != "":
+			m.Notice = styles.SuccessStyle.Render(fmt.Sprintf("Loaded %d methods from %s", len(msg.Methods), source))
+		}
+		m.refreshMethods()
+		return m, nil
+
+	case ResponseMsg:
+		if msg.Stream != m.stream {
+			return m, nil
+		}
+		m.logMessages(msg.Messages...)
+		m.refreshResponse(false)
+		return m, msg.Stream.wait()
+
+	case CallDoneMsg:
+		if msg.Stream != m.stream {
+			return m, nil
+		}
+		m.Calling, m.cancel = false, nil
+		m.done = &msg
+		m.refreshResponse(false)
+		return m, nil
+
+	case spinner.TickMsg:
+		if m.Calling {
+			m.Spinner, cmd = m.Spinner.Update(msg)
+		}
+		return m, cmd
+	}
+
+	if m.FocusedPane == 0 {
+		m.services, cmd = m.services.Update(msg)
+	}
+	return m, cmd
+}
+
+// Editing reports whether keys are typed into an input or the service
+// filter, so they must not be taken as global shortcuts.
+func (m Model) Editing() bool {
+	return m.FocusedPane == 1 || m.services.FilterState() == list.Filtering
+}
+
+// updateRequestInputs moves between the inputs with up and down, and types
+// into the focused one otherwise.
+func (m *Model) updateRequestInputs(msg tea.KeyMsg) tea.Cmd {
+	var cmd tea.Cmd
+	switch msg.String() {
+	case "up":
+		m.FocusedInput = (m.FocusedInput + numInputs - 1) % numInputs
+		m.focus()
+		return nil
+	case "down":
+		m.FocusedInput = (m.FocusedInput + 1) % numInputs
+		m.focus()
+		return nil
+	}
+	switch m.FocusedInput {
+	case addressInput:
+		before := m.Address.Value()
+		m.Address, cmd = m.Address.Update(msg)
+		if m.Address.Value() != before {
+			m.refreshMethods()
+		}
+	case metadataInput:
+		m.Metadata, cmd = m.Metadata.Update(msg)
+	case messageInput:
+		m.Message, cmd = m.Message.Update(msg)
+	}
+	return cmd
+}
+
+func (m *Model) focus() {
+	m.Address.Blur()
+	m.Metadata.Blur()
+	m.Message.Blur()
+	if m.FocusedPane != 1 {
+		return
+	}
+	switch m.FocusedInput {
+	case addressInput:
+		m.Address.Focus()
+	case metadataInput:
+		m.Metadata.Focus()
+	case messageInput:
+		m.Message.Focus()
+	}
+}
+
+// methods lists what can be called at the current address: the methods its
+// reflection service listed, then those of the .proto files it did not.
+func (m Model) methods() []Method {
+	reflected := m.reflected[strings.TrimSpace(m.Address.Value())]
+	all := append([]Method(nil), reflected...)
+	seen := make(map[string]bool)
+	for _, r := range reflected {
+		seen[r.FullName()] = true
+	}
+	for _, f := range m.fileMethods {
+		if !seen[f.FullName()] {
+			all = append(all, f)
+		}
+	}
+	return all
+}
+
+func (m *Model) refreshMethods() {
+	methods := m.methods()
+	items := make([]list.Item, len(methods))
+	for i, method := range methods {
+		items[i] = methodItem{method}
+	}
+	m.services.SetItems(items)
+}
+
+// selected returns the selected method, if it can be found at the address.
+func (m Model) selected() (Method, bool) {
+	for _, method := range m.methods() {
+		if method.FullName() == m.method {
+			return method, true
+		}
+	}
+	return Method{}, false
+}
+
+// selectMethod makes method the one to call. The Message editor gets the
+// skeleton of its request type, unless something other than the previous
+// skeleton was typed there.
+func (m *Model) selectMethod(method Method) {
+	m.method = method.FullName()
+	if value := strings.TrimSpace(m.Message.Value()); value == "" || value == strings.TrimSpace(m.template) {
+		m.template = requestTemplate(method)
+		m.Message.SetValue(m.template)
+	}
+	m.Notice = ""
+	m.FocusedPane, m.FocusedInput = 1, messageInput
+	m.focus()
+}
+
+// requestTemplate is the JSON of an empty request to method, every field
+// present with its default value.
+func requestTemplate(method Method) string {
+	msg := dynamicpb.NewMessage(method.Desc.Input())
+	return formatJSON(protojson.MarshalOptions{EmitUnpopulated: true, Resolver: method.types}, msg)
+}
+
+// reflect lists the services of the server at the address.
+func (m *Model) reflect() tea.Cmd {
+	address := strings.TrimSpace(m.Address.Value())
+	if address == "" {
+		m.Notice = styles.ErrorStyle.Render("Type the address of the server first")
+		return nil
+	}
+	m.Notice = styles.HelpStyle.Render("Asking " + secrets.Mask(address) + " for its services…")
+	return loadReflection(address, m.Metadata.Value(), m.Environment, m.Timeout)
+}
+
+// invoke calls the selected method with the request in the editor.
+func (m *Model) invoke() tea.Cmd {
+	if m.Calling {
+		return nil
+	}
+	method, ok := m.selected()
+	if !ok {
+		m.Notice = styles.ErrorStyle.Render("Select a method first: Enter in the Services list")
+		if m.method != "" {
+			m.Notice = styles.ErrorStyle.Render(m.method + " is not known at this address: Alt+R loads its services")
+		}
+		return nil
+	}
+	call := Call{Address: strings.TrimSpace(m.Address.Value()), Method: method.FullName(), Metadata: m.Metadata.Value(), Request: m.Message.Value()}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	m.Calling, m.cancel = true, cancel
+	m.messages, m.received, m.done = nil, 0, nil
+	m.Notice = ""
+	m.stream = invoke(ctx, method, call, m.Environment, m.Timeout)
+	m.refreshResponse(true)
+	return tea.Batch(m.Spinner.Tick, m.stream.wait())
+}
+
+// Load puts a call from the history back into the editor, with the
+// response it got.
+func (m *Model) Load(e http.HistoryEntry) {
+	address, method := splitCallURL(e.URL)
+	m.Address.SetValue(address)
+	m.Metadata.SetValue(e.Headers)
+	m.Message.SetValue(e.Body)
+	m.method, m.template = method, ""
+	m.refreshMethods()
+	m.messages, m.received, m.stream = nil, 0, nil
+	done := CallDoneMsg{Code: codes.Code(e.Status), Message: e.Error, Duration: time.Duration(e.DurationMS) * time.Millisecond, Cancelled: e.Outcome == "cancelled"}
+	if e.Response != nil {
+		m.logMessages(splitMessages(e.Response.Body)...)
+		done.Messages = m.messages
+	}
+	m.done = &done
+	m.Notice = styles.HelpStyle.Render("Loaded from history: Ctrl+S calls it again")
+	m.FocusedPane, m.FocusedInput = 1, messageInput
+	m.focus()
+	m.refreshResponse(true)
+}
+
+// splitCallURL undoes Call.URL.
+func splitCallURL(url string) (address, method string) {
+	scheme := ""
+	for _, s := range []string{"grpc://", "grpcs://"} {
+		if strings.HasPrefix(url, s) {
+			scheme, url = s, strings.TrimPrefix(url, s)
+		}
+	}
+	host, path, _ := strings.Cut(url, "/")
+	if scheme == "grpcs://" {
+		host = scheme + host
+	}
+	return host, "/" + path
+}
+
+// splitMessages splits the stored responses of a call, JSON objects one
+// after the other, back into messages.
+func splitMessages(body string) []string {
+	var out []string
+	dec := json.NewDecoder(strings.NewReader(body))
+	for {
+		var msg json.RawMessage
+		if err := dec.Decode(&msg); err != nil {
+			return out // a truncated snapshot ends in an incomplete message
+		}
+		out = append(out, string(msg))
+	}
+}
+
+// logMessages appends responses, dropping the oldest past maxMessages.
+func (m *Model) logMessages(messages ...string) {
+	m.received += len(messages)
+	m.messages = append(m.messages, messages...)
+	if over := len(m.messages) - maxMessages; over > 0 {
+		m.messages = m.messages[over:]
+	}
+}
+
+// refreshResponse redraws the response view, following the end of a stream
+// unless scrolled away from it, or always with jump.
+func (m *Model) refreshResponse(jump bool) {
+	follow := !jump && m.Response.AtBottom()
+	if m.ResponseView == 1 {
+		m.Response.SetContent(m.renderMetadata())
+	} else {
+		m.Response.SetContent(m.renderMessages())
+	}
+	switch {
+	case follow:
+		m.Response.GotoBottom()
+	case jump:
+		m.Response.GotoTop()
+	}
+}
+
+func (m Model) renderMessages() string {
+	var b strings.Builder
+	if dropped := m.received - len(m.messages); dropped > 0 {
+		b.WriteString(styles.HelpStyle.Render(fmt.Sprintf("%d earlier messages not shown", dropped)) + "\n")
+	}
+	for i, msg := range m.messages {
+		if len(m.messages) > 1 {
+			b.WriteString(styles.HelpStyle.Render(fmt.Sprintf("#%d", m.received-len(m.messages)+i+1)) + "\n")
+		}
+		b.WriteString(secrets.Mask(utils.PrettyPrintJSON(msg)) + "\n")
+	}
+	switch d := m.done; {
+	case d == nil || d.Cancelled:
+	case d.Err != nil:
+		b.WriteString(styles.ErrorStyle.Render(d.Err.Error()) + "\n")
+	case d.Code != codes.OK:
+		b.WriteString(styles.ErrorStyle.Render(fmt.Sprintf("%s: %s", d.Code, d.Message)) + "\n")
+	}
+	if m.done == nil && !m.Calling {
+		b.WriteString(styles.HelpStyle.Render("Pick a method, edit its request and press Ctrl+S."))
+	}
+	return b.String()
+}
+
+func (m Model) renderMetadata() string {
+	if m.done == nil {
+		return styles.HelpStyle.Render("Response metadata arrives with the end of the call.")
+	}
+	section := func(title, lines string) string {
+		if lines == "" {
+			lines = styles.HelpStyle.Render("(none)") + "\n"
+		}
+		return styles.FocusedInputStyle.Render(title) + "\n" + secrets.Mask(lines)
+	}
+	return section("Headers", formatMetadata(m.done.Header)) + "\n" + section("Trailers", formatMetadata(m.done.Trailer))
+}
+
+// renderStatus summarises the call in the response header.
+func (m Model) renderStatus() string {
+	switch d := m.done; {
+	case m.Calling:
+		return fmt.Sprintf("%s %d messages", m.Spinner.View(), m.received)
+	case d == nil:
+		return ""
+	case d.Cancelled:
+		return styles.HelpStyle.Render(fmt.Sprintf("cancelled after %s", d.Duration.Round(time.Millisecond)))
+	case d.Err != nil:
+		return styles.ErrorStyle.Render("error")
+	default:
+		style := styles.SuccessStyle
+		if d.Code != codes.OK {
+			style = styles.ErrorStyle
+		}
+		return fmt.Sprintf("%s · %s · %d messages", style.Render(d.Code.String()), d.Duration.Round(time.Millisecond), m.received)
+	}
+}
+
+// View renders the gRPC model.
+func (m Model) View() string {
+	var request strings.Builder
+	method := m.method
+	if method == "" {
+		method = styles.HelpStyle.Render("no method selected")
+	} else if selected, ok := m.selected(); ok {
+		method += styles.HelpStyle.Render(" · " + selected.Kind())
+	}
+	request.WriteString(styles.ListHeaderStyle.Render("Call") + " " + method + "\n")
+	request.WriteString(m.renderTitle("Address", addressInput) + "\n" + m.Address.View() + "\n")
+	request.WriteString(m.renderTitle("Metadata", metadataInput) + "\n" + m.Metadata.View() + "\n")
+	request.WriteString(m.renderTitle("Message (JSON)", messageInput) + "\n" + m.Message.View() + "\n")
+
+	var renderedTabs []string
+	for i, t := range responseViews {
+		style := styles.InactiveTabStyle
+		if i == m.ResponseView {
+			style = styles.ActiveTabStyle
+		}
+		renderedTabs = append(renderedTabs, style.Render(t))
+	}
+	response := lipgloss.JoinVertical(lipgloss.Left,
+		styles.ListHeaderStyle.Render("Response")+" "+m.renderStatus(),
+		lipgloss.JoinHorizontal(lipgloss.Top, renderedTabs...),
+		m.Response.View(),
+	)
+
+	listStyle, reqStyle, respStyle := styles.BlurredPaneStyle, styles.BlurredPaneStyle, styles.BlurredPaneStyle
+	switch m.FocusedPane {
+	case 0:
+		listStyle = styles.FocusedPaneStyle
+	case 1:
+		reqStyle = styles.FocusedPaneStyle
+	case 2:
+		respStyle = styles.FocusedPaneStyle
+	}
+
+	help := styles.HelpStyle.Render("Focus: Ctrl+L | Call: Ctrl+S | Reflect: Alt+R | Reload .proto: Alt+P | Reset message: Alt+T | Resp View: H/L")
+	if m.FocusedPane == 0 {
+		help = styles.HelpStyle.Render("Select: Enter | Filter: / | Reflect: Alt+R | Reload .proto: Alt+P | Focus: Ctrl+L | Call: Ctrl+S")
+	}
+	if m.Calling {
+		help = styles.HelpStyle.Render("Cancel: Esc/Ctrl+C | Focus: Ctrl+L | Resp View: H/L | Scroll: Up/Down")
+	}
+	if m.Notice != "" {
+		help = m.Notice + "  " + help
+	}
+
+	listPane := m.services.View()
+	if len(m.services.Items()) == 0 {
+		listPane = styles.ListHeaderStyle.Render("Services") + "\n" + styles.HelpStyle.Render("No services yet. Alt+R asks the server through reflection; .proto files in the project are loaded on start.")
+	}
+	return lipgloss.JoinVertical(lipgloss.Left,
+		lipgloss.JoinHorizontal(lipgloss.Top,
+			listStyle.Width(m.Width/4).Height(m.Height-2).Render(listPane),
+			reqStyle.Width(m.Width/2).Height(m.Height-2).Render(request.String()),
+			respStyle.Width(m.Width-m.Width/4-m.Width/2).Height(m.Height-2).Render(response),
+		),
+		help,
+	)
+}
+
+func (m Model) renderTitle(title string, index int) string {
+	if m.FocusedPane == 1 && m.FocusedInput == index {
+		return styles.FocusedInputStyle.Render(title)
+	}
+	return styles.BlurredInputStyle.Render(title)
+}
+
+// SetSize sets the size of the gRPC model.
+func (m *Model) SetSize(w, h int) {
+	m.Width, m.Height = w, h
+	listWidth, reqWidth := w/4, w/2
+	m.services.SetSize(listWidth, h-4)
+	m.Address.Width = reqWidth - 4
+	m.Metadata.SetWidth(reqWidth - 4)
+	m.Message.SetWidth(reqWidth - 4)
+	m.Message.SetHeight(max(h-16, 3))
+	m.Response.Width = w - listWidth - reqWidth - 6
+	m.Response.Height = h - 6
+}
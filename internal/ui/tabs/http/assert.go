@@ -0,0 +1,141 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AssertionKind is what an Assertion checks.
+type AssertionKind int
+
+const (
+	AssertStatus   AssertionKind = iota // status code matches Expected, e.g. "200" or "2xx"
+	AssertHeader                        // header Target is present, and contains Expected if set
+	AssertJSONPath                      // JSONPath Target in the body equals Expected
+	AssertMaxTime                       // total time is below Expected (a time.Duration)
+)
+
+// Assertion is a declarative check on a response.
+type Assertion struct {
+	Kind     AssertionKind
+	Target   string // header name or JSONPath expression
+	Expected any
+}
+
+// String describes the assertion, e.g. "status == 200".
+func (a Assertion) String() string {
+	switch a.Kind {
+	case AssertStatus:
+		return fmt.Sprintf("status == %v", a.Expected)
+	case AssertHeader:
+		if a.Expected == nil {
+			return fmt.Sprintf("header %s present", a.Target)
+		}
+		return fmt.Sprintf("header %s contains %q", a.Target, a.Expected)
+	case AssertJSONPath:
+		return fmt.Sprintf("%s == %s", a.Target, formatJSONValue(a.Expected))
+	case AssertMaxTime:
+		return fmt.Sprintf("time < %v", a.Expected)
+	}
+	return "unknown assertion"
+}
+
+// AssertionResult is the outcome of one assertion or test check.
+type AssertionResult struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"` // why it failed
+}
+
+// TestFunc is a scripted test, such as a Lua function from config.lua. It
+// returns one result per check, or an error when the test itself failed.
+type TestFunc func(resp *Response) ([]AssertionResult, error)
+
+// Check evaluates the assertion against resp. body is the decoded JSON
+// body, or nil when it is not JSON.
+func (a Assertion) Check(resp *Response, body any, bodyErr error) AssertionResult {
+	r := AssertionResult{Name: a.String(), Passed: true}
+	fail := func(format string, args ...any) AssertionResult {
+		r.Passed, r.Message = false, fmt.Sprintf(format, args...)
+		return r
+	}
+	switch a.Kind {
+	case AssertStatus:
+		if !statusMatches(fmt.Sprint(a.Expected), resp.StatusCode) {
+			return fail("got %d", resp.StatusCode)
+		}
+	case AssertHeader:
+		values, ok := resp.Header[http.CanonicalHeaderKey(a.Target)]
+		if !ok {
+			return fail("missing")
+		}
+		if want, ok := a.Expected.(string); ok && !strings.Contains(strings.Join(values, ", "), want) {
+			return fail("got %q", strings.Join(values, ", "))
+		}
+	case AssertJSONPath:
+		if bodyErr != nil {
+			return fail("body is not JSON: %v", bodyErr)
+		}
+		v, err := JSONPath(body, a.Target)
+		if err != nil {
+			return fail("%v", err)
+		}
+		if !jsonEqual(v, a.Expected) {
+			return fail("got %s", formatJSONValue(v))
+		}
+	case AssertMaxTime:
+		if max, ok := a.Expected.(time.Duration); ok && resp.Timing.Total >= max {
+			return fail("took %v", resp.Timing.Total.Round(time.Millisecond))
+		}
+	}
+	return r
+}
+
+// statusMatches reports whether code matches a pattern such as "200" or "4xx".
+func statusMatches(pattern string, code int) bool {
+	s := strconv.Itoa(code)
+	if len(pattern) != len(s) {
+		return false
+	}
+	for i := range pattern {
+		if pattern[i] != 'x' && pattern[i] != 'X' && pattern[i] != s[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Check runs the request's assertions and test function against resp.
+func (i RequestItem) Check(resp *Response) []AssertionResult {
+	var results []AssertionResult
+	var body any
+	var bodyErr error
+	if len(i.Assertions) > 0 {
+		body, bodyErr = DecodeJSON(resp.Body)
+	}
+	for _, a := range i.Assertions {
+		results = append(results, a.Check(resp, body, bodyErr))
+	}
+	if i.Test != nil {
+		checks, err := i.Test(resp)
+		if err != nil {
+			checks = append(checks, AssertionResult{Name: "test", Message: err.Error()})
+		}
+		results = append(results, checks...)
+	}
+	return results
+}
+
+// countPassed returns how many results passed.
+func countPassed(results []AssertionResult) int {
+	n := 0
+	for _, r := range results {
+		if r.Passed {
+			n++
+		}
+	}
+	return n
+}
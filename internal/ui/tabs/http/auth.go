@@ -0,0 +1,472 @@
+package http
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"phantom/internal/secrets"
+)
+
+// AuthKind is the authentication scheme of a request.
+type AuthKind int
+
+const (
+	AuthNone AuthKind = iota
+	AuthBasic
+	AuthBearer
+	AuthAPIKey
+	AuthDigest
+	AuthOAuth2
+)
+
+// Auth is the Auth section of a request. It is written as one line:
+//
+//	basic user:password
+//	bearer {{token}}
+//	apikey header X-API-Key {{key}}      (or: apikey query api_key {{key}})
+//	digest user:password
+//	oauth2 client_credentials token_url=... client_id=... client_secret=... [scope=...]
+//	oauth2 password token_url=... client_id=... username=... password=... [client_secret=...] [scope=...]
+//
+// Values may be quoted and may use {{variables}}.
+type Auth struct {
+	Kind               AuthKind
+	Username, Password string // Basic, Digest and the OAuth2 password grant
+	Token              string // Bearer
+	In, Name, Value    string // API key: "header" or "query", its name and value
+	// OAuth2
+	Grant                                   string // "client_credentials" or "password"
+	TokenURL, ClientID, ClientSecret, Scope string
+}
+
+// oauth2Params are the key=value options of an oauth2 auth line.
+var oauth2Params = []string{"token_url", "client_id", "client_secret", "username", "password", "scope"}
+
+// ParseAuth parses an auth line. An empty line is AuthNone.
+func ParseAuth(spec string) (Auth, error) {
+	words, err := splitShell(strings.TrimSpace(spec))
+	if err != nil {
+		return Auth{}, fmt.Errorf("auth: %w", err)
+	}
+	if len(words) == 0 || strings.EqualFold(words[0], "none") {
+		return Auth{}, nil
+	}
+	args := words[1:]
+	var a Auth
+	switch strings.ToLower(words[0]) {
+	case "basic", "digest":
+		a.Kind = AuthBasic
+		if strings.EqualFold(words[0], "digest") {
+			a.Kind = AuthDigest
+		}
+		switch len(args) {
+		case 1:
+			a.Username, a.Password, _ = strings.Cut(args[0], ":")
+		case 2:
+			a.Username, a.Password = args[0], args[1]
+		default:
+			return a, fmt.Errorf("auth: expected %s user:password", words[0])
+		}
+	case "bearer":
+		if len(args) != 1 {
+			return a, errors.New("auth: expected bearer <token>")
+		}
+		a.Kind, a.Token = AuthBearer, args[0]
+	case "apikey":
+		if len(args) != 3 || (args[0] != "header" && args[0] != "query") {
+			return a, errors.New("auth: expected apikey header|query <name> <value>")
+		}
+		a.Kind, a.In, a.Name, a.Value = AuthAPIKey, args[0], args[1], args[2]
+	case "oauth2":
+		if len(args) == 0 || (args[0] != "client_credentials" && args[0] != "password") {
+			return a, errors.New("auth: expected oauth2 client_credentials|password key=value...")
+		}
+		a.Kind, a.Grant = AuthOAuth2, args[0]
+		for _, arg := range args[1:] {
+			k, v, ok := strings.Cut(arg, "=")
+			if !ok {
+				return a, fmt.Errorf("auth: oauth2 option %q is not key=value", arg)
+			}
+			if err := a.setOAuth2Param(k, v); err != nil {
+				return a, err
+			}
+		}
+		if a.TokenURL == "" || a.ClientID == "" {
+			return a, errors.New("auth: oauth2 needs token_url and client_id")
+		}
+		if a.Grant == "password" && a.Username == "" {
+			return a, errors.New("auth: the oauth2 password grant needs a username")
+		}
+	default:
+		return a, fmt.Errorf("auth: unknown scheme %q", words[0])
+	}
+	return a, nil
+}
+
+func (a *Auth) setOAuth2Param(k, v string) error {
+	switch k {
+	case "token_url":
+		a.TokenURL = v
+	case "client_id":
+		a.ClientID = v
+	case "client_secret":
+		a.ClientSecret = v
+	case "username":
+		a.Username = v
+	case "password":
+		a.Password = v
+	case "scope":
+		a.Scope = v
+	default:
+		return fmt.Errorf("auth: unknown oauth2 option %q", k)
+	}
+	return nil
+}
+
+// String renders a as the line ParseAuth reads.
+func (a Auth) String() string {
+	q := authQuote
+	switch a.Kind {
+	case AuthBasic:
+		return "basic " + q(a.Username+":"+a.Password)
+	case AuthDigest:
+		return "digest " + q(a.Username+":"+a.Password)
+	case AuthBearer:
+		return "bearer " + q(a.Token)
+	case AuthAPIKey:
+		return fmt.Sprintf("apikey %s %s %s", a.In, q(a.Name), q(a.Value))
+	case AuthOAuth2:
+		parts := []string{"oauth2", a.Grant}
+		values := []string{a.TokenURL, a.ClientID, a.ClientSecret, a.Username, a.Password, a.Scope}
+		for i, k := range oauth2Params {
+			if values[i] != "" {
+				parts = append(parts, q(k+"="+values[i]))
+			}
+		}
+		return strings.Join(parts, " ")
+	}
+	return ""
+}
+
+// authQuote quotes s if splitShell would not read it back as one word.
+func authQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\$") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// substitute returns a with env substituted into every field.
+func (a Auth) Substitute(env map[string]string) Auth {
+	for _, f := range []*string{&a.Username, &a.Password, &a.Token, &a.Name, &a.Value,
+		&a.TokenURL, &a.ClientID, &a.ClientSecret, &a.Scope} {
+		*f = Substitute(*f, env)
+	}
+	return a
+}
+
+// apply adds the credentials that need no round trip to header and rawURL.
+// It reports whether it did, which is not the case for Digest and OAuth2.
+func (a Auth) apply(header http.Header, rawURL string) (string, bool) {
+	switch a.Kind {
+	case AuthBasic:
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(a.Username+":"+a.Password)))
+	case AuthBearer:
+		header.Set("Authorization", "Bearer "+a.Token)
+	case AuthAPIKey:
+		if a.In == "query" {
+			u, err := url.Parse(rawURL)
+			if err != nil {
+				return rawURL, true // the request fails on the URL anyway
+			}
+			q := u.Query()
+			q.Set(a.Name, a.Value)
+			u.RawQuery = q.Encode()
+			return u.String(), true
+		}
+		header.Set(a.Name, a.Value)
+	default:
+		return rawURL, a.Kind == AuthNone
+	}
+	return rawURL, true
+}
+
+// do sends req with a's credentials.
+func (a Auth) do(ctx context.Context, req Request) (*Response, error) {
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	switch a.Kind {
+	case AuthDigest:
+		resp, err := Do(ctx, req)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+		challenge, ok := parseDigestChallenge(resp.Header.Values("WWW-Authenticate"))
+		if !ok {
+			return resp, nil
+		}
+		authz, err := digestAuthorization(challenge, a.Username, a.Password, req.Method, req.URL)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", authz)
+		return Do(ctx, req)
+	case AuthOAuth2:
+		token, err := oauth2Token(ctx, a, false)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := Do(ctx, req)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+		// The token may have been revoked before it expired: try a fresh one.
+		if token, err = oauth2Token(ctx, a, true); err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return Do(ctx, req)
+	}
+	req.URL, _ = a.apply(req.Header, req.URL)
+	return Do(ctx, req)
+}
+
+// digestChallenge holds the parameters of a WWW-Authenticate: Digest header.
+type digestChallenge map[string]string
+
+// parseDigestChallenge finds the Digest challenge among WWW-Authenticate values.
+func parseDigestChallenge(values []string) (digestChallenge, bool) {
+	for _, v := range values {
+		rest, ok := cutPrefixFold(strings.TrimSpace(v), "Digest ")
+		if !ok {
+			continue
+		}
+		c := digestChallenge{}
+		for rest != "" {
+			rest = strings.TrimLeft(rest, " ,")
+			k, after, ok := strings.Cut(rest, "=")
+			if !ok {
+				break
+			}
+			k = strings.ToLower(strings.TrimSpace(k))
+			var val string
+			if strings.HasPrefix(after, `"`) {
+				end := strings.IndexByte(after[1:], '"')
+				if end < 0 {
+					break
+				}
+				val, rest = after[1:end+1], after[end+2:]
+			} else {
+				val, rest, _ = strings.Cut(after, ",")
+				val = strings.TrimSpace(val)
+			}
+			c[k] = val
+		}
+		return c, c["nonce"] != ""
+	}
+	return nil, false
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return s[len(prefix):], true
+	}
+	return s, false
+}
+
+// digestAuthorization answers a Digest challenge (RFC 7616) with qop=auth.
+func digestAuthorization(c digestChallenge, user, password, method, rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	uri := u.RequestURI()
+
+	algorithm := c["algorithm"]
+	base, sess := strings.CutSuffix(strings.ToUpper(algorithm), "-SESS")
+	var newHash func() hash.Hash
+	switch base {
+	case "", "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("digest: unsupported algorithm %s", algorithm)
+	}
+	h := func(s string) string {
+		d := newHash()
+		io.WriteString(d, s)
+		return hex.EncodeToString(d.Sum(nil))
+	}
+
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	cnonce, nc := hex.EncodeToString(buf), "00000001"
+
+	ha1 := h(user + ":" + c["realm"] + ":" + password)
+	if sess {
+		ha1 = h(ha1 + ":" + c["nonce"] + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+
+	qop := ""
+	for _, q := range strings.Split(c["qop"], ",") {
+		if strings.TrimSpace(q) == "auth" {
+			qop = "auth"
+		}
+	}
+	response := h(ha1 + ":" + c["nonce"] + ":" + ha2)
+	if qop != "" {
+		response = h(strings.Join([]string{ha1, c["nonce"], nc, cnonce, qop, ha2}, ":"))
+	}
+
+	parts := []string{
+		fmt.Sprintf(`username=%q`, user),
+		fmt.Sprintf(`realm=%q`, c["realm"]),
+		fmt.Sprintf(`nonce=%q`, c["nonce"]),
+		fmt.Sprintf(`uri=%q`, uri),
+		fmt.Sprintf(`response=%q`, response),
+	}
+	if algorithm != "" {
+		parts = append(parts, "algorithm="+algorithm)
+	}
+	if qop != "" {
+		parts = append(parts, "qop="+qop, "nc="+nc, fmt.Sprintf(`cnonce=%q`, cnonce))
+	}
+	if opaque, ok := c["opaque"]; ok {
+		parts = append(parts, fmt.Sprintf(`opaque=%q`, opaque))
+	}
+	return "Digest " + strings.Join(parts, ", "), nil
+}
+
+// oauth2Expiry is how long before its stated expiry a token is refreshed,
+// so it does not run out in flight.
+const oauth2Expiry = 30 * time.Second
+
+type cachedToken struct {
+	access, refresh string
+	expiry          time.Time // zero if the server did not say
+}
+
+// tokenCache holds OAuth2 tokens for the session, per token endpoint, client,
+// user and scope. Its lock only guards the map; each entry has its own, held
+// while its token is fetched, so one slow endpoint does not stall the others.
+var tokenCache = struct {
+	sync.Mutex
+	entries map[string]*tokenEntry
+}{entries: map[string]*tokenEntry{}}
+
+type tokenEntry struct {
+	sync.Mutex
+	token cachedToken
+	ok    bool
+}
+
+// oauth2Token returns a cached access token for a, refreshing or fetching a
+// new one when it has expired or force is set.
+func oauth2Token(ctx context.Context, a Auth, force bool) (string, error) {
+	key := strings.Join([]string{a.TokenURL, a.ClientID, a.Grant, a.Username, a.Scope}, "\x00")
+	tokenCache.Lock()
+	e := tokenCache.entries[key]
+	if e == nil {
+		e = &tokenEntry{}
+		tokenCache.entries[key] = e
+	}
+	tokenCache.Unlock()
+
+	e.Lock()
+	defer e.Unlock()
+	t := e.token
+	if e.ok && !force && (t.expiry.IsZero() || time.Now().Before(t.expiry)) {
+		return t.access, nil
+	}
+	if e.ok && t.refresh != "" {
+		refreshed, err := requestToken(ctx, a, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {t.refresh}})
+		if err == nil {
+			if refreshed.refresh == "" { // the old refresh token stays valid
+				refreshed.refresh = t.refresh
+			}
+			e.token = refreshed
+			return refreshed.access, nil
+		}
+	}
+	form := url.Values{"grant_type": {a.Grant}}
+	if a.Grant == "password" {
+		form.Set("username", a.Username)
+		form.Set("password", a.Password)
+	}
+	t, err := requestToken(ctx, a, form)
+	if err != nil {
+		e.token, e.ok = cachedToken{}, false
+		return "", err
+	}
+	e.token, e.ok = t, true
+	return t.access, nil
+}
+
+// requestToken posts form to the token endpoint of a.
+func requestToken(ctx context.Context, a Auth, form url.Values) (cachedToken, error) {
+	form.Set("client_id", a.ClientID)
+	if a.ClientSecret != "" {
+		form.Set("client_secret", a.ClientSecret)
+	}
+	if a.Scope != "" {
+		form.Set("scope", a.Scope)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return cachedToken{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return cachedToken{}, fmt.Errorf("oauth2: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		AccessToken  string      `json:"access_token"`
+		RefreshToken string      `json:"refresh_token"`
+		ExpiresIn    json.Number `json:"expires_in"`
+		Error        string      `json:"error"`
+		Description  string      `json:"error_description"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err := json.Unmarshal(data, &body); err != nil && resp.StatusCode < 300 {
+		return cachedToken{}, fmt.Errorf("oauth2: token response: %w", err)
+	}
+	if resp.StatusCode >= 300 || body.AccessToken == "" {
+		msg := strings.TrimSpace(body.Error + " " + body.Description)
+		if msg == "" {
+			msg = resp.Status
+		}
+		return cachedToken{}, fmt.Errorf("oauth2: token request failed: %s", msg)
+	}
+
+	secrets.Register(body.AccessToken)
+	secrets.Register(body.RefreshToken)
+	t := cachedToken{access: body.AccessToken, refresh: body.RefreshToken}
+	if secs, err := body.ExpiresIn.Int64(); err == nil && secs > 0 {
+		t.expiry = time.Now().Add(time.Duration(secs)*time.Second - oauth2Expiry)
+	}
+	return t, nil
+}
@@ -0,0 +1,62 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyQuery(t *testing.T) {
+	a := Auth{Kind: AuthAPIKey, In: "query", Name: "api key", Value: "a&b"}
+	tests := []struct {
+		url, want string
+	}{
+		{"https://example.com/items", "https://example.com/items?api+key=a%26b"},
+		{"https://example.com/items?page=2", "https://example.com/items?api+key=a%26b&page=2"},
+		{"https://example.com/items#top", "https://example.com/items?api+key=a%26b#top"},
+		{"https://example.com/items?page=2#top", "https://example.com/items?api+key=a%26b&page=2#top"},
+		{"https://example.com/items?api+key=old", "https://example.com/items?api+key=a%26b"},
+	}
+	for _, tt := range tests {
+		if got, _ := a.apply(http.Header{}, tt.url); got != tt.want {
+			t.Errorf("apply(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestOAuth2TokenSlowEndpoint(t *testing.T) {
+	arrived, release := make(chan struct{}), make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(arrived)
+		<-release
+		fmt.Fprint(w, `{"access_token": "slow"}`)
+	}))
+	defer slow.Close()
+	defer close(release)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"access_token": "fast", "expires_in": 3600}`)
+	}))
+	defer fast.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go oauth2Token(ctx, Auth{Kind: AuthOAuth2, Grant: "client_credentials", TokenURL: slow.URL, ClientID: "app"}, false)
+	<-arrived // the slow fetch holds its entry's lock from now on
+
+	done := make(chan string)
+	go func() {
+		token, _ := oauth2Token(context.Background(), Auth{Kind: AuthOAuth2, Grant: "client_credentials", TokenURL: fast.URL, ClientID: "app"}, false)
+		done <- token
+	}()
+	select {
+	case token := <-done:
+		if token != "fast" {
+			t.Errorf("token = %q, want %q", token, "fast")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a slow token endpoint blocked another one")
+	}
+}
@@ -0,0 +1,311 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BodyModes are the ways the Body editor can be sent, see RequestItem.BodyMode.
+// json, xml and text are raw bodies with a Content-Type preset.
+var BodyModes = []string{"raw", "json", "xml", "text", "form", "multipart", "file"}
+
+// Body modes that do not send the editor text as is.
+const (
+	BodyForm      = "form"      // name=value lines, sent URL-encoded
+	BodyMultipart = "multipart" // name=value and name=@path lines, sent as multipart/form-data
+	BodyFile      = "file"      // the path of a file whose contents are the body
+)
+
+// bodyContentTypes are the Content-Type presets of the body modes.
+var bodyContentTypes = map[string]string{
+	"json":   "application/json",
+	"xml":    "application/xml",
+	"text":   "text/plain; charset=utf-8",
+	BodyForm: "application/x-www-form-urlencoded",
+}
+
+// bodyPlaceholders hint at what the Body editor holds in each mode.
+var bodyPlaceholders = map[string]string{
+	"raw":         `{"key": "value"}`,
+	"json":        `{"key": "value"}`,
+	"xml":         `<key>value</key>`,
+	"text":        "plain text",
+	BodyForm:      "name=value\nother=value",
+	BodyMultipart: "name=value\nfile=@path/to/upload.png",
+	BodyFile:      "path/to/payload.bin",
+}
+
+// bodyModeIndex returns the index of mode in BodyModes; unknown modes are raw.
+func bodyModeIndex(mode string) int {
+	for i, m := range BodyModes {
+		if m == mode {
+			return i
+		}
+	}
+	return 0
+}
+
+// parseFormLines reads the name=value lines of the form and multipart modes.
+// Blank lines and # comments are skipped. In multipart mode, "name=@path"
+// uploads a file, with a ";type=..." suffix setting its content type, and
+// "name=< path" sends the contents of a file as a text field.
+func parseFormLines(text string, files bool) []formField {
+	var fields []formField
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, _ := strings.Cut(line, "=")
+		f := formField{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value)}
+		if path, ok := strings.CutPrefix(f.Value, "@"); ok && files {
+			f.Value, f.File = path, true
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// formLines is the inverse of parseFormLines.
+func formLines(fields []formField) string {
+	var lines []string
+	for _, f := range fields {
+		if f.File {
+			lines = append(lines, f.Name+"=@"+f.Value)
+		} else {
+			lines = append(lines, f.Name+"="+f.Value)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// buildBody turns the substituted editor text into the bytes to send and
+// their content type, which is empty if the mode has none. Relative paths
+// are resolved against dir.
+func buildBody(mode, text, dir string, header http.Header) ([]byte, string, error) {
+	switch mode {
+	case BodyForm:
+		var parts []string
+		for _, f := range parseFormLines(text, false) {
+			parts = append(parts, url.QueryEscape(f.Name)+"="+url.QueryEscape(f.Value))
+		}
+		return []byte(strings.Join(parts, "&")), bodyContentTypes[BodyForm], nil
+	case BodyMultipart:
+		return multipartFormBody(parseFormLines(text, true), dir)
+	case BodyFile:
+		path := resolvePath(strings.TrimSpace(text), dir)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("body: %w", err)
+		}
+		return data, fileContentType(path), nil
+	}
+	if mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type")); strings.HasPrefix(mediaType, "multipart/") {
+		body, err := expandIncludes(text, dir)
+		return body, "", err
+	}
+	return []byte(text), bodyContentTypes[mode], nil
+}
+
+// multipartFormBody encodes fields as multipart/form-data, reading file
+// parts from disk.
+func multipartFormBody(fields []formField, dir string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, f := range fields {
+		if !f.File {
+			value := f.Value
+			if path, ok := strings.CutPrefix(value, "< "); ok { // curl's name=<file: a text field read from a file
+				data, err := os.ReadFile(resolvePath(strings.TrimSpace(path), dir))
+				if err != nil {
+					return nil, "", fmt.Errorf("body: %w", err)
+				}
+				value = string(data)
+			}
+			if err := w.WriteField(f.Name, value); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+		path, contentType, _ := strings.Cut(f.Value, ";type=")
+		path = resolvePath(path, dir)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("body: %w", err)
+		}
+		if contentType == "" {
+			contentType = fileContentType(path)
+		}
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, f.Name, filepath.Base(path)))
+		h.Set("Content-Type", contentType)
+		part, err := w.CreatePart(h)
+		if err != nil {
+			return nil, "", err
+		}
+		part.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+// expandIncludes replaces REST Client "< path" lines of a literal multipart
+// body with the contents of the file. Line endings become CRLF, as the
+// multipart format requires.
+func expandIncludes(text, dir string) ([]byte, error) {
+	var buf bytes.Buffer
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		if path, ok := strings.CutPrefix(line, "< "); ok {
+			data, err := os.ReadFile(resolvePath(strings.TrimSpace(path), dir))
+			if err != nil {
+				return nil, fmt.Errorf("body: %w", err)
+			}
+			buf.Write(data)
+		} else {
+			buf.WriteString(line)
+		}
+		if i < len(lines)-1 {
+			buf.WriteString("\r\n")
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// resolvePath resolves a relative path against dir, the directory of the
+// .http file the request came from.
+func resolvePath(path, dir string) string {
+	if path, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path)
+		}
+	}
+	if filepath.IsAbs(path) || dir == "" {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+func fileContentType(path string) string {
+	if t := mime.TypeByExtension(filepath.Ext(path)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// bodyModeFromHeaders picks the editor mode for a body read from a .http
+// file or a curl command: form and multipart bodies become name=value lines,
+// and a lone "< path" line sends a file. The Content-Type header the mode
+// sets is dropped from headers.
+func bodyModeFromHeaders(headers, body string) (mode, newHeaders, newBody string) {
+	if path, ok := strings.CutPrefix(strings.TrimSpace(body), "< "); ok && !strings.Contains(path, "\n") {
+		return BodyFile, headers, strings.TrimSpace(path)
+	}
+	var contentType string
+	var kept []string
+	for _, line := range strings.Split(headers, "\n") {
+		if k, v, _ := strings.Cut(line, ":"); strings.EqualFold(strings.TrimSpace(k), "Content-Type") {
+			contentType = strings.TrimSpace(v)
+			continue
+		}
+		kept = append(kept, line)
+	}
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		if fields, ok := parseFormText(body); ok {
+			return BodyForm, strings.Join(kept, "\n"), formLines(fields)
+		}
+	case "multipart/form-data":
+		if fields, ok := parseMultipartText(body, params["boundary"]); ok {
+			return BodyMultipart, strings.Join(kept, "\n"), formLines(fields)
+		}
+	}
+	return "", headers, body
+}
+
+// parseFormText splits a URL-encoded body into fields. It gives up on
+// bodies that would not be sent the same way once re-encoded, such as JSON
+// posted with curl's default form content type.
+func parseFormText(body string) ([]formField, bool) {
+	var fields []formField
+	for _, pair := range strings.Split(strings.ReplaceAll(body, "\n", ""), "&") {
+		if pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.ContainsAny(pair, " \t{}[]\"") {
+			return nil, false
+		}
+		name, err1 := url.QueryUnescape(k)
+		value, err2 := url.QueryUnescape(v)
+		if err1 != nil || err2 != nil || strings.ContainsAny(name+value, "\n") {
+			return nil, false
+		}
+		fields = append(fields, formField{Name: name, Value: value})
+	}
+	return fields, len(fields) > 0
+}
+
+// parseMultipartText reads a literal multipart body, such as multipartBody
+// writes, back into fields. It gives up on anything but plain form fields
+// and "< path" file includes.
+func parseMultipartText(body, boundary string) ([]formField, bool) {
+	if boundary == "" {
+		return nil, false
+	}
+	var fields []formField
+	parts := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "--"+boundary)
+	if len(parts) < 2 || strings.TrimSpace(parts[0]) != "" || !strings.HasPrefix(parts[len(parts)-1], "--") {
+		return nil, false
+	}
+	for _, part := range parts[1 : len(parts)-1] {
+		head, value, ok := strings.Cut(strings.TrimPrefix(part, "\n"), "\n\n")
+		if !ok {
+			return nil, false
+		}
+		_, params, err := mime.ParseMediaType(strings.TrimSpace(strings.TrimPrefix(head, "Content-Disposition:")))
+		if err != nil || params["name"] == "" || strings.Contains(head, "\n") {
+			return nil, false
+		}
+		value = strings.TrimSuffix(value, "\n")
+		path, include := strings.CutPrefix(value, "< ")
+		switch {
+		case params["filename"] == "":
+			fields = append(fields, formField{Name: params["name"], Value: value})
+		case include:
+			fields = append(fields, formField{Name: params["name"], Value: path, File: true})
+		default: // inline file contents
+			return nil, false
+		}
+	}
+	return fields, true
+}
+
+// formatBody renders a body of any mode as a .http body, along with the
+// Content-Type header it needs.
+func formatBody(mode, body string) (contentType, text string) {
+	switch mode {
+	case BodyForm:
+		var parts []string
+		for _, f := range parseFormLines(body, false) {
+			parts = append(parts, escapeQuery(f.Name)+"="+escapeQuery(f.Value))
+		}
+		return bodyContentTypes[BodyForm], strings.Join(parts, "\n&")
+	case BodyMultipart:
+		return "multipart/form-data; boundary=" + formBoundary, multipartBody(parseFormLines(body, true))
+	case BodyFile:
+		return "", "< " + strings.TrimSpace(body)
+	}
+	return bodyContentTypes[mode], body
+}
@@ -0,0 +1,130 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// CaptureKind is where a Capture takes its value from.
+type CaptureKind int
+
+const (
+	CaptureJSONPath CaptureKind = iota // JSONPath into the body
+	CaptureHeader                      // response header
+	CaptureRegex                       // regular expression on the body, first group if any
+)
+
+// Capture stores part of a response in an environment variable, so later
+// requests can use it as {{Name}}.
+type Capture struct {
+	Name string
+	Kind CaptureKind
+	Expr string
+	re   *regexp.Regexp
+}
+
+// ParseCapture parses a capture expression: "$.token" (or "json:$.token"),
+// "header:ETag" or "regex:id=(\d+)".
+func ParseCapture(name, expr string) (Capture, error) {
+	c := Capture{Name: name}
+	expr = strings.TrimSpace(expr)
+	switch {
+	case strings.HasPrefix(expr, "$"):
+		c.Kind, c.Expr = CaptureJSONPath, expr
+	case strings.HasPrefix(expr, "json:"):
+		c.Kind, c.Expr = CaptureJSONPath, strings.TrimSpace(strings.TrimPrefix(expr, "json:"))
+	case strings.HasPrefix(expr, "header:"):
+		c.Kind, c.Expr = CaptureHeader, strings.TrimSpace(strings.TrimPrefix(expr, "header:"))
+	case strings.HasPrefix(expr, "regex:"):
+		c.Kind, c.Expr = CaptureRegex, strings.TrimPrefix(expr, "regex:")
+		re, err := regexp.Compile(c.Expr)
+		if err != nil {
+			return c, fmt.Errorf("capture %s: %w", name, err)
+		}
+		c.re = re
+	default:
+		return c, fmt.Errorf("capture %s: %q is not a JSONPath, header: or regex: expression", name, expr)
+	}
+	if name == "" || c.Expr == "" {
+		return c, fmt.Errorf("capture %q: empty name or expression", name)
+	}
+	return c, nil
+}
+
+// String renders the capture the way ParseCapture reads it.
+func (c Capture) String() string {
+	switch c.Kind {
+	case CaptureHeader:
+		return "header:" + c.Expr
+	case CaptureRegex:
+		return "regex:" + c.Expr
+	}
+	return c.Expr
+}
+
+// extract returns the captured value from resp. body is the decoded JSON body.
+func (c Capture) extract(resp *Response, body any, bodyErr error) (string, error) {
+	switch c.Kind {
+	case CaptureHeader:
+		v := resp.Header.Get(http.CanonicalHeaderKey(c.Expr))
+		if v == "" {
+			return "", fmt.Errorf("no %s header", c.Expr)
+		}
+		return v, nil
+	case CaptureRegex:
+		m := c.re.FindSubmatch(resp.Body)
+		switch {
+		case m == nil:
+			return "", fmt.Errorf("%s did not match", c.Expr)
+		case len(m) > 1:
+			return string(m[1]), nil
+		}
+		return string(m[0]), nil
+	}
+	if bodyErr != nil {
+		return "", fmt.Errorf("body is not JSON: %v", bodyErr)
+	}
+	v, err := JSONPath(body, c.Expr)
+	if err != nil {
+		return "", err
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// Capture extracts the request's captures from resp. Captures that fail are
+// reported as failed checks. Error responses (400 and up) capture nothing,
+// so that a failed login cannot replace a good token.
+func (i RequestItem) Capture(resp *Response) (map[string]string, []AssertionResult) {
+	if len(i.Captures) == 0 {
+		return nil, nil
+	}
+	if resp.StatusCode >= 400 {
+		var failed []AssertionResult
+		for _, c := range i.Captures {
+			failed = append(failed, AssertionResult{Name: "capture " + c.Name, Message: fmt.Sprintf("not captured from a %d response", resp.StatusCode)})
+		}
+		return nil, failed
+	}
+	values := make(map[string]string, len(i.Captures))
+	var failed []AssertionResult
+	body, bodyErr := DecodeJSON(resp.Body)
+	for _, c := range i.Captures {
+		v, err := c.extract(resp, body, bodyErr)
+		if err != nil {
+			failed = append(failed, AssertionResult{Name: "capture " + c.Name, Message: err.Error()})
+			continue
+		}
+		values[c.Name] = v
+	}
+	return values, failed
+}
@@ -0,0 +1,37 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCaptureSkipsErrorResponses(t *testing.T) {
+	token, err := ParseCapture("auth_token", "$.token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag, err := ParseCapture("etag", "header:ETag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := RequestItem{Captures: []Capture{token, etag}}
+	header := http.Header{"Etag": {`"v1"`}}
+	body := []byte(`{"token": "abc"}`)
+
+	tests := []struct {
+		status     int
+		wantValues int
+		wantFailed int
+	}{
+		{200, 2, 0},
+		{302, 2, 0},
+		{401, 0, 2},
+		{500, 0, 2},
+	}
+	for _, tt := range tests {
+		values, failed := item.Capture(&Response{StatusCode: tt.status, Header: header, Body: body})
+		if len(values) != tt.wantValues || len(failed) != tt.wantFailed {
+			t.Errorf("status %d: captured %v, failed %v; want %d values and %d failures", tt.status, values, failed, tt.wantValues, tt.wantFailed)
+		}
+	}
+}
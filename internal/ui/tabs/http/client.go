@@ -0,0 +1,326 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxRedirects    = 10
+	maxResponseBody = 32 << 20 // 32 MiB
+)
+
+// Errors of requests that did not complete. A timed out request returns
+// an error wrapping ErrTimeout that says which limit was hit.
+var (
+	ErrCancelled = errors.New("cancelled")
+	ErrTimeout   = errors.New("timed out")
+)
+
+// Request is a request with all environment variables already substituted.
+type Request struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+	// ConnectTimeout limits how long connecting, TLS included, may take.
+	ConnectTimeout time.Duration
+	// stream, if set, receives the body of streaming responses while it
+	// arrives, see streamKind.
+	stream *responseStream
+}
+
+// Response is the result of sending a Request.
+type Response struct {
+	Proto      string
+	Status     string // e.g. "200 OK"
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Redirects  []Redirect
+	Timing     Timing
+}
+
+// Redirect is one hop of a redirect chain.
+type Redirect struct {
+	StatusCode int
+	From, To   string
+}
+
+// Timing breaks down where the time of a request went. DNS, Connect, TLS and
+// TTFB describe the final hop; Total covers the whole chain including the body.
+type Timing struct {
+	DNS     time.Duration `json:"dns"`
+	Connect time.Duration `json:"connect"`
+	TLS     time.Duration `json:"tls"`
+	TTFB    time.Duration `json:"ttfb"`
+	Total   time.Duration `json:"total"`
+	Reused  bool          `json:"reused,omitempty"` // connection came from the pool
+}
+
+// ParseHeaders parses "Key: Value" lines. Quoted keys and values, as in the
+// textarea placeholder, are unquoted. Blank lines are ignored.
+func ParseHeaders(text string) (http.Header, error) {
+	fields, err := parseHeaderFields(text)
+	if err != nil {
+		return nil, err
+	}
+	header := make(http.Header)
+	for _, f := range fields {
+		header.Add(f.Name, f.Value)
+	}
+	return header, nil
+}
+
+// headerField is a header as written in the editor, before canonicalisation.
+type headerField struct {
+	Name, Value string
+}
+
+// parseHeaderFields is ParseHeaders keeping the order and spelling of the headers.
+func parseHeaderFields(text string) ([]headerField, error) {
+	var fields []headerField
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") { // "# Name: value" is a disabled header
+			continue
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("header line %d: missing ':' in %q", i+1, line)
+		}
+		k = strings.Trim(strings.TrimSpace(k), `"`)
+		v = strings.Trim(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(v), ",")), `"`)
+		if k == "" {
+			return nil, fmt.Errorf("header line %d: empty name", i+1)
+		}
+		if !validHeaderName(k) {
+			return nil, fmt.Errorf("header line %d: invalid name %q", i+1, k)
+		}
+		fields = append(fields, headerField{Name: k, Value: v})
+	}
+	return fields, nil
+}
+
+// encodeBasicAuth base64-encodes an Authorization header written in the
+// REST Client "Basic user:password" form.
+func encodeBasicAuth(header http.Header) {
+	if v := header.Get("Authorization"); v != "" {
+		header.Set("Authorization", basicAuthValue(v))
+	}
+}
+
+// basicAuthValue encodes the credentials of a "Basic user:password" value.
+// Encoded credentials never contain a colon, so those are left alone.
+func basicAuthValue(v string) string {
+	creds, ok := strings.CutPrefix(v, "Basic ")
+	if !ok || !strings.Contains(creds, ":") {
+		return v
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(creds))
+}
+
+// FormatHeaders renders a status line and headers the way they appear on the wire.
+func FormatHeaders(proto, status string, header http.Header) string {
+	var b strings.Builder
+	if proto != "" || status != "" {
+		fmt.Fprintf(&b, "%s %s\n", proto, status)
+	}
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range header[k] {
+			fmt.Fprintf(&b, "%s: %s\n", k, v)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Do sends req, following redirects and recording timings along the way.
+func Do(ctx context.Context, req Request) (*Response, error) {
+	resp := &Response{}
+	tr := &tracer{}
+	ctx = httptrace.WithClientTrace(ctx, tr.clientTrace())
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		return nil, err
+	}
+	if req.Header != nil {
+		httpReq.Header = req.Header.Clone()
+	}
+	if host := httpReq.Header.Get("Host"); host != "" {
+		httpReq.Host = host
+	}
+
+	client := &http.Client{
+		Transport: transportFor(req.ConnectTimeout),
+		CheckRedirect: func(next *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			from := via[len(via)-1]
+			status := 0
+			if next.Response != nil {
+				status = next.Response.StatusCode
+			}
+			resp.Redirects = append(resp.Redirects, Redirect{StatusCode: status, From: from.URL.String(), To: next.URL.String()})
+			return nil
+		},
+	}
+
+	start := time.Now()
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, requestError(ctx, err, req.ConnectTimeout)
+	}
+	defer httpResp.Body.Close()
+
+	resp.Proto = httpResp.Proto
+	resp.Status = httpResp.Status
+	resp.StatusCode = httpResp.StatusCode
+	resp.Header = httpResp.Header
+	if req.stream != nil {
+		if kind := streamKind(httpResp.Header, req.stream.Force); kind != "" {
+			resp.Timing = tr.timing(start)
+			resp.Body = req.stream.read(ctx, resp, kind, httpResp.Body)
+			resp.Timing.Total = time.Since(start)
+			return resp, nil
+		}
+	}
+
+	resp.Body, err = io.ReadAll(io.LimitReader(httpResp.Body, maxResponseBody+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading body: %w", requestError(ctx, err, req.ConnectTimeout))
+	}
+	if len(resp.Body) > maxResponseBody {
+		return nil, errors.New("response body exceeds 32 MiB")
+	}
+	resp.Timing = tr.timing(start)
+	return resp, nil
+}
+
+// requestError replaces the error of a request that was cancelled or timed
+// out with one saying so. The context's cause is ErrCancelled, or the total
+// timeout set by Execute.
+func requestError(ctx context.Context, err error, connectTimeout time.Duration) error {
+	if cause := context.Cause(ctx); cause != nil {
+		if errors.Is(cause, context.Canceled) {
+			return ErrCancelled
+		}
+		return cause
+	}
+	// Nothing else sets deadlines, so this is the dialer or the TLS handshake.
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() && connectTimeout > 0 {
+		return fmt.Errorf("%w: could not connect within %s", ErrTimeout, connectTimeout)
+	}
+	return err
+}
+
+// transports holds a transport per connect timeout, so that connections are
+// still pooled.
+var transports sync.Map
+
+func transportFor(connectTimeout time.Duration) http.RoundTripper {
+	if connectTimeout <= 0 {
+		return http.DefaultTransport
+	}
+	if t, ok := transports.Load(connectTimeout); ok {
+		return t.(http.RoundTripper)
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	t.TLSHandshakeTimeout = connectTimeout
+	actual, _ := transports.LoadOrStore(connectTimeout, t)
+	return actual.(http.RoundTripper)
+}
+
+// tracer collects httptrace events. Every hop of a redirect chain resets it,
+// so the recorded phases always belong to the final request. Dial events
+// arrive on the transport's own goroutines, in parallel for dual-stack
+// hosts, and may outlive the request, so all access goes through mu.
+type tracer struct {
+	mu sync.Mutex
+	phases
+}
+
+// phases are the times of the events of one hop.
+type phases struct {
+	hopStart                  time.Time
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	firstByte                 time.Time
+	reused                    bool
+}
+
+// stamp sets at, one of the phases of t, to now.
+func (t *tracer) stamp(at *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*at = time.Now()
+}
+
+func (t *tracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.phases = phases{hopStart: time.Now()}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.reused = info.Reused
+		},
+		DNSStart: func(httptrace.DNSStartInfo) { t.stamp(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.stamp(&t.dnsDone) },
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.connectStart.IsZero() { // only the first dial attempt counts
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone:          func(string, string, error) { t.stamp(&t.connectDone) },
+		TLSHandshakeStart:    func() { t.stamp(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.stamp(&t.tlsDone) },
+		GotFirstResponseByte: func() { t.stamp(&t.firstByte) },
+	}
+}
+
+func (t *tracer) timing(start time.Time) Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return Timing{
+		DNS:     span(t.dnsStart, t.dnsDone),
+		Connect: span(t.connectStart, t.connectDone),
+		TLS:     span(t.tlsStart, t.tlsDone),
+		TTFB:    span(t.hopStart, t.firstByte),
+		Total:   time.Since(start),
+		Reused:  t.reused,
+	}
+}
+
+func span(from, to time.Time) time.Duration {
+	if from.IsZero() || to.IsZero() {
+		return 0
+	}
+	return to.Sub(from)
+}
@@ -0,0 +1,326 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// curlIgnoredArgs are curl options that take a value phantom has no use for.
+var curlIgnoredArgs = map[string]bool{
+	"-o": true, "--output": true, "-m": true, "--max-time": true, "--connect-timeout": true,
+	"--retry": true, "-w": true, "--write-out": true, "-x": true, "--proxy": true,
+	"--cacert": true, "-E": true, "--cert": true, "--key": true, "-c": true, "--cookie-jar": true,
+	"-r": true, "--range": true, "--resolve": true, "--limit-rate": true, "-T": true, "--upload-file": true,
+}
+
+// ParseCurl turns a curl command line, as copied from browser devtools, into
+// a request. Shell quoting and backslash line continuations are understood.
+func ParseCurl(command string) (RequestItem, error) {
+	args, err := splitShell(command)
+	if err != nil {
+		return RequestItem{}, err
+	}
+	if len(args) == 0 || args[0] != "curl" {
+		return RequestItem{}, errors.New("not a curl command")
+	}
+
+	var (
+		item                RequestItem
+		headers, data       []string
+		form                []formField
+		get, head, jsonBody bool
+		dataFile            string // -d @path
+	)
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		name, value, attached := curlOption(arg)
+		next := func() (string, error) {
+			if attached {
+				return value, nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("%s needs a value", name)
+			}
+			i++
+			return args[i], nil
+		}
+
+		var v string
+		switch name {
+		case "-X", "--request", "-H", "--header", "-d", "--data", "--data-raw", "--data-binary",
+			"--data-ascii", "--data-urlencode", "--json", "-u", "--user", "-F", "--form",
+			"--form-string", "-A", "--user-agent", "-e", "--referer", "-b", "--cookie", "--url":
+			if v, err = next(); err != nil {
+				return RequestItem{}, err
+			}
+		}
+
+		switch name {
+		case "-X", "--request":
+			item.Method = strings.ToUpper(v)
+		case "-H", "--header":
+			// Go negotiates gzip on its own and only decodes responses when it
+			// did, so an explicit Accept-Encoding would leave bodies compressed.
+			if k, _, _ := strings.Cut(v, ":"); !strings.EqualFold(strings.TrimSpace(k), "Accept-Encoding") {
+				headers = append(headers, v)
+			}
+		case "-d", "--data", "--data-ascii", "--data-binary", "--data-raw":
+			if path, ok := strings.CutPrefix(v, "@"); ok && name != "--data-raw" {
+				dataFile = path
+			}
+			data = append(data, v)
+		case "--data-urlencode":
+			data = append(data, curlURLEncode(v))
+		case "--json":
+			data, jsonBody = append(data, v), true
+		case "-u", "--user":
+			user, password, _ := strings.Cut(v, ":")
+			headers = append(headers, "Authorization: "+basicAuth(user, password))
+		case "-F", "--form":
+			form = append(form, curlFormField(v))
+		case "--form-string":
+			k, val, _ := strings.Cut(v, "=")
+			form = append(form, formField{Name: k, Value: val})
+		case "-A", "--user-agent":
+			headers = append(headers, "User-Agent: "+v)
+		case "-e", "--referer":
+			headers = append(headers, "Referer: "+v)
+		case "-b", "--cookie":
+			headers = append(headers, "Cookie: "+v)
+		case "--url":
+			item.URL = v
+		case "-G", "--get":
+			get = true
+		case "-I", "--head":
+			head = true
+		case "--compressed":
+			// Nothing to do: responses are always decompressed.
+		default:
+			switch {
+			case curlIgnoredArgs[name]:
+				if !attached {
+					i++
+				}
+			case !strings.HasPrefix(arg, "-") && item.URL == "":
+				item.URL = arg
+			}
+		}
+	}
+	if item.URL == "" {
+		return RequestItem{}, errors.New("no URL in curl command")
+	}
+
+	body := strings.Join(data, "&")
+	switch {
+	case dataFile != "" && len(data) == 1 && !get:
+		item.BodyMode, body = BodyFile, dataFile
+	case get && body != "":
+		item.URL = appendRawQuery(item.URL, body)
+		body = ""
+	case len(form) > 0:
+		body = multipartBody(form)
+		headers = setDefaultHeader(headers, "Content-Type", "multipart/form-data; boundary="+formBoundary)
+	case jsonBody:
+		headers = setDefaultHeader(headers, "Content-Type", "application/json")
+		headers = setDefaultHeader(headers, "Accept", "application/json")
+	case body != "":
+		headers = setDefaultHeader(headers, "Content-Type", "application/x-www-form-urlencoded")
+	}
+	item.Body = body
+
+	if item.Method == "" {
+		switch {
+		case head:
+			item.Method = "HEAD"
+		case body != "":
+			item.Method = "POST"
+		default:
+			item.Method = "GET"
+		}
+	}
+	item.Name = item.Method + " " + item.URL
+	item.Headers = strings.Join(headers, "\n")
+	if item.BodyMode == "" {
+		item.BodyMode, item.Headers, item.Body = bodyModeFromHeaders(item.Headers, item.Body)
+	}
+	return item, nil
+}
+
+// curlOption splits "--header=value" and "-XPOST" into option and value.
+func curlOption(arg string) (name, value string, attached bool) {
+	if strings.HasPrefix(arg, "--") {
+		if k, v, ok := strings.Cut(arg, "="); ok {
+			return k, v, true
+		}
+		return arg, "", false
+	}
+	if len(arg) > 2 && arg[0] == '-' && strings.ContainsRune("XHduFAebomwxEcrT", rune(arg[1])) {
+		return arg[:2], arg[2:], true
+	}
+	return arg, "", false
+}
+
+// curlURLEncode implements --data-urlencode: "name=value" encodes the value,
+// anything else is encoded whole.
+func curlURLEncode(v string) string {
+	if k, val, ok := strings.Cut(v, "="); ok {
+		return k + "=" + url.QueryEscape(val)
+	}
+	return url.QueryEscape(v)
+}
+
+// curlFormField parses -F "name=value", "name=@file" and "name=<file".
+func curlFormField(v string) formField {
+	k, val, _ := strings.Cut(v, "=")
+	if path, ok := strings.CutPrefix(val, "@"); ok {
+		path, _, _ = strings.Cut(path, ";") // drop ;type=... and ;filename=...
+		return formField{Name: k, Value: path, File: true}
+	}
+	if path, ok := strings.CutPrefix(val, "<"); ok { // field value read from a file
+		path, _, _ = strings.Cut(path, ";")
+		return formField{Name: k, Value: "< " + path}
+	}
+	return formField{Name: k, Value: val}
+}
+
+func appendRawQuery(rawURL, query string) string {
+	if strings.Contains(rawURL, "?") {
+		return rawURL + "&" + query
+	}
+	return rawURL + "?" + query
+}
+
+// setDefaultHeader adds a header unless one with that name is already set.
+func setDefaultHeader(headers []string, name, value string) []string {
+	for _, h := range headers {
+		if k, _, _ := strings.Cut(h, ":"); strings.EqualFold(strings.TrimSpace(k), name) {
+			return headers
+		}
+	}
+	return append(headers, name+": "+value)
+}
+
+// splitShell splits a command line into words the way a POSIX shell would,
+// including $'...' strings. A backslash at the start of a word is treated
+// as a line continuation, since single-line inputs turn newlines into spaces.
+func splitShell(s string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		runes   = []rune(strings.ReplaceAll(s, "\r\n", "\n"))
+		flush   = func() { words = append(words, word.String()); word.Reset(); inWord = false }
+		isSpace = func(r rune) bool { return r == ' ' || r == '\t' || r == '\n' }
+	)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case isSpace(r):
+			if inWord {
+				flush()
+			}
+		case r == '\\':
+			if i+1 >= len(runes) {
+				continue
+			}
+			if runes[i+1] == '\n' || (!inWord && isSpace(runes[i+1])) {
+				i++ // line continuation
+				continue
+			}
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+		case r == '\'':
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated ' quote")
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			i, inWord = end, true
+		case r == '$' && i+1 < len(runes) && runes[i+1] == '\'':
+			n, err := ansiCString(runes[i+2:], &word)
+			if err != nil {
+				return nil, err
+			}
+			i, inWord = i+2+n, true
+		case r == '"':
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]) {
+					i++
+					if runes[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, errors.New(`unterminated " quote`)
+			}
+			inWord = true
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		flush()
+	}
+	return words, nil
+}
+
+func indexRune(runes []rune, from int, r rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// ansiCString decodes the body of a $'...' string into w, returning the
+// index in runes of its closing quote.
+func ansiCString(runes []rune, w *strings.Builder) (int, error) {
+	escapes := map[rune]rune{'n': '\n', 't': '\t', 'r': '\r', '\\': '\\', '\'': '\'', '"': '"', 'a': '\a', 'b': '\b', 'e': 0x1b, 'f': '\f', 'v': '\v'}
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == '\'' {
+			return i, nil
+		}
+		if r != '\\' || i+1 >= len(runes) {
+			w.WriteRune(r)
+			continue
+		}
+		i++
+		if e, ok := escapes[runes[i]]; ok {
+			w.WriteRune(e)
+			continue
+		}
+		if runes[i] == 'x' || runes[i] == 'u' || runes[i] == 'U' {
+			digits := map[rune]int{'x': 2, 'u': 4, 'U': 8}[runes[i]]
+			end := i + 1
+			for end < len(runes) && end-i-1 < digits && isHex(runes[end]) {
+				end++
+			}
+			if end == i+1 {
+				w.WriteRune('\\')
+				w.WriteRune(runes[i])
+				continue
+			}
+			var code rune
+			fmt.Sscanf(string(runes[i+1:end]), "%x", &code)
+			w.WriteRune(code)
+			i = end - 1
+			continue
+		}
+		w.WriteRune('\\')
+		w.WriteRune(runes[i])
+	}
+	return 0, errors.New("unterminated $' quote")
+}
+
+func isHex(r rune) bool {
+	return (r >= '0' && r <= '9') || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
+}
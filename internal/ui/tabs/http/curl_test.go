@@ -0,0 +1,115 @@
+package http
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitShell(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{"words", "curl  -X\tPOST\nhttps://a.b", []string{"curl", "-X", "POST", "https://a.b"}, false},
+		{"single quotes", `curl -H 'X-A: "b" \n'`, []string{"curl", "-H", `X-A: "b" \n`}, false},
+		{"double quotes", `curl -d "a \"b\" \$c \\ \x"`, []string{"curl", "-d", `a "b" $c \ \x`}, false},
+		{"adjacent quotes", `a'b'"c"d`, []string{"abcd"}, false},
+		{"empty quotes", `curl ''`, []string{"curl", ""}, false},
+		{"escaped space", `a\ b c`, []string{"a b", "c"}, false},
+		{"ansi-c", `$'a\nb\t\'c\' \x41é\e'`, []string{"a\nb\t'c' Aé\x1b"}, false},
+		{"ansi-c unknown escape", `$'\q\x'`, []string{`\q\x`}, false},
+		{"continuation", "curl \\\n  -X POST \\\r\n  https://a.b", []string{"curl", "-X", "POST", "https://a.b"}, false},
+		{"continuation flattened", `curl \  -X POST \ https://a.b`, []string{"curl", "-X", "POST", "https://a.b"}, false},
+		{"continuation in double quotes", "\"a\\\nb\"", []string{"ab"}, false},
+		{"trailing backslash", `curl \`, []string{"curl"}, false},
+		{"unterminated single", `curl 'abc`, nil, true},
+		{"unterminated double", `curl "abc`, nil, true},
+		{"unterminated ansi-c", `curl $'abc`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitShell(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitShell(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitShell(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCurl(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    RequestItem
+		wantErr bool
+	}{
+		{
+			name: "get",
+			in:   "curl https://api.example.com/users",
+			want: RequestItem{Method: "GET", URL: "https://api.example.com/users"},
+		},
+		{
+			name: "devtools copy",
+			in: `curl 'https://api.example.com/users' \
+  -H 'accept: application/json' \
+  -H 'accept-encoding: gzip, deflate, br' \
+  --data-raw $'{"name":"O\'Brien"}' \
+  --compressed`,
+			want: RequestItem{Method: "POST", URL: "https://api.example.com/users", Headers: "accept: application/json\nContent-Type: application/x-www-form-urlencoded", Body: `{"name":"O'Brien"}`},
+		},
+		{
+			name: "attached options",
+			in:   `curl -XPUT --header="X-Id: 7" --url=https://a.b/x -d'a=1'`,
+			want: RequestItem{Method: "PUT", URL: "https://a.b/x", Headers: "X-Id: 7", BodyMode: BodyForm, Body: "a=1"},
+		},
+		{
+			name: "json",
+			in:   `curl --json '{"a":1}' https://a.b`,
+			want: RequestItem{Method: "POST", URL: "https://a.b", Headers: "Content-Type: application/json\nAccept: application/json", Body: `{"a":1}`},
+		},
+		{
+			name: "get with data",
+			in:   `curl -G https://a.b/s?x=1 -d q=go --data-urlencode 'n=a b'`,
+			want: RequestItem{Method: "GET", URL: "https://a.b/s?x=1&q=go&n=a+b"},
+		},
+		{
+			name: "data file",
+			in:   `curl -X POST https://a.b --data-binary @body.json`,
+			want: RequestItem{Method: "POST", URL: "https://a.b", BodyMode: BodyFile, Body: "body.json"},
+		},
+		{
+			name: "ignored options",
+			in:   `curl -o out.txt -m 5 -sS https://a.b`,
+			want: RequestItem{Method: "GET", URL: "https://a.b"},
+		},
+		{
+			name: "head",
+			in:   `curl -I https://a.b`,
+			want: RequestItem{Method: "HEAD", URL: "https://a.b"},
+		},
+		{name: "not curl", in: `wget https://a.b`, wantErr: true},
+		{name: "no url", in: `curl -X POST`, wantErr: true},
+		{name: "missing value", in: `curl https://a.b -H`, wantErr: true},
+		{name: "bad quoting", in: `curl 'https://a.b`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCurl(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCurl error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			tt.want.Name = tt.want.Method + " " + tt.want.URL
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCurl =\n%#v\nwant\n%#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,414 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"phantom/internal/app"
+	"phantom/internal/secrets"
+	"phantom/internal/ui/components/styles"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Sources of environment values, from lowest to highest precedence.
+const (
+	sourceSpec    = "openapi"
+	sourceFile    = ".http"
+	sourceConfig  = "config"
+	sourceCapture = "captured"
+)
+
+// NamedEnvironment is one of the environments of config.lua, such as
+// "local" or "staging". While active, its variables override the shared
+// base environment.
+type NamedEnvironment struct {
+	Name string            `json:"name"`
+	Tags []string          `json:"tags,omitempty"`
+	Vars map[string]string `json:"vars"`
+}
+
+// Production reports whether the environment is tagged "production".
+func (e NamedEnvironment) Production() bool {
+	for _, t := range e.Tags {
+		if strings.EqualFold(t, "production") {
+			return true
+		}
+	}
+	return false
+}
+
+// FindEnvironment returns the index of the environment called name, or of
+// the first one if name is empty. It returns -1 if there is no such environment.
+func FindEnvironment(envs []NamedEnvironment, name string) int {
+	if name == "" && len(envs) > 0 {
+		return 0
+	}
+	for i, e := range envs {
+		if strings.EqualFold(e.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// MergeEnvironments returns the environments of config.lua followed by the
+// imported ones it does not define itself.
+func MergeEnvironments(config, imported []NamedEnvironment) []NamedEnvironment {
+	envs := append([]NamedEnvironment(nil), config...)
+	for _, e := range imported {
+		if e.Name != "" && FindEnvironment(config, e.Name) < 0 {
+			envs = append(envs, e)
+		}
+	}
+	return envs
+}
+
+// environmentsFile holds the environments imported from Postman. It lives
+// in the project data directory rather than the project, as their values
+// are often credentials.
+const environmentsFile = "environments.json"
+
+// EnvironmentStore persists imported environments.
+type EnvironmentStore struct {
+	Path string
+}
+
+// NewEnvironmentStore returns the environment store of the current project.
+func NewEnvironmentStore() (*EnvironmentStore, error) {
+	dir, err := app.ProjectDataDir()
+	if err != nil {
+		return nil, err
+	}
+	return &EnvironmentStore{Path: filepath.Join(dir, environmentsFile)}, nil
+}
+
+// Load returns the imported environments, in the order they were imported.
+func (s *EnvironmentStore) Load() ([]NamedEnvironment, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var envs []NamedEnvironment
+	if err := json.Unmarshal(data, &envs); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.Path, err)
+	}
+	return envs, nil
+}
+
+// Put adds env, replacing an imported environment of the same name.
+func (s *EnvironmentStore) Put(env NamedEnvironment) error {
+	envs, err := s.Load()
+	if err != nil {
+		return err
+	}
+	if i := FindEnvironment(envs, env.Name); env.Name != "" && i >= 0 {
+		envs[i] = env
+	} else {
+		envs = append(envs, env)
+	}
+	data, err := json.MarshalIndent(envs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.Path, append(data, '\n'), 0o600)
+}
+
+// EnvironmentsLoadedMsg carries the imported environments.
+type EnvironmentsLoadedMsg struct {
+	Environments []NamedEnvironment
+	Err          error
+}
+
+func loadEnvironments(store *EnvironmentStore) tea.Cmd {
+	return func() tea.Msg {
+		envs, err := store.Load()
+		return EnvironmentsLoadedMsg{Environments: envs, Err: err}
+	}
+}
+
+// SecretsUnlockedMsg is sent once the secrets file has been decrypted.
+type SecretsUnlockedMsg struct {
+	Vault *secrets.Vault
+	Err   error
+}
+
+// unlockSecrets decrypts the secrets file in the background, as deriving
+// the key takes a moment.
+func unlockSecrets(path, passphrase string) tea.Cmd {
+	return func() tea.Msg {
+		v, err := secrets.OpenVault(path, passphrase)
+		return SecretsUnlockedMsg{Vault: v, Err: err}
+	}
+}
+
+// SetSecretsFile sets the encrypted secrets file ${secret:name} references
+// are read from. It is unlocked right away if its passphrase is in the
+// environment; otherwise Alt+U asks for it.
+func (m *Model) SetSecretsFile(path string) tea.Cmd {
+	if path != "" {
+		m.vaultPath = path
+	}
+	if pass, ok := os.LookupEnv(secrets.PassphraseEnv); ok {
+		return unlockSecrets(m.vaultPath, pass)
+	}
+	return nil
+}
+
+// SetEnvironment sets the shared base environment from config.lua. Its
+// values take precedence over variables declared in .http files.
+func (m *Model) SetEnvironment(env map[string]string) {
+	m.configEnv = env
+	m.refreshEnvironment()
+}
+
+// SetEnvironments sets the named environments of config.lua and activates
+// the one called active, or the first one.
+func (m *Model) SetEnvironments(envs []NamedEnvironment, active string) {
+	m.configEnvs = envs
+	m.Environments = MergeEnvironments(m.configEnvs, m.importedEnvs)
+	m.ActiveEnv = FindEnvironment(m.Environments, active)
+	m.pendingEnv = ""
+	if m.ActiveEnv < 0 && active != "" {
+		if m.envStore != nil && m.importedEnvs == nil {
+			m.pendingEnv = active // maybe an imported one, which are still loading
+		} else {
+			log.Printf("http: no environment called %q", active)
+		}
+		m.ActiveEnv = FindEnvironment(m.Environments, "")
+	}
+	m.refreshEnvironment()
+	for k, err := range m.unresolved {
+		log.Printf("http: %s: %s", k, err)
+	}
+	if _, auto := os.LookupEnv(secrets.PassphraseEnv); m.vaultNeeded && m.vault == nil && !auto {
+		m.Notice = styles.ErrorStyle.Render("Secrets file is locked: Alt+U to unlock")
+	}
+}
+
+// setImportedEnvironments replaces the imported environments, keeping the
+// active environment by name.
+func (m *Model) setImportedEnvironments(envs []NamedEnvironment) {
+	name := m.pendingEnv
+	if active, ok := m.activeEnvironment(); ok && name == "" {
+		name = active.Name
+	}
+	m.importedEnvs, m.pendingEnv = envs, ""
+	if m.importedEnvs == nil {
+		m.importedEnvs = []NamedEnvironment{} // loaded, if empty
+	}
+	m.Environments = MergeEnvironments(m.configEnvs, m.importedEnvs)
+	if m.ActiveEnv = FindEnvironment(m.Environments, name); m.ActiveEnv < 0 && name != "" {
+		log.Printf("http: no environment called %q", name)
+		m.ActiveEnv = FindEnvironment(m.Environments, "")
+	}
+	m.refreshEnvironment()
+}
+
+// activeEnvironment returns the active named environment, if any.
+func (m Model) activeEnvironment() (NamedEnvironment, bool) {
+	if m.ActiveEnv < 0 || m.ActiveEnv >= len(m.Environments) {
+		return NamedEnvironment{}, false
+	}
+	return m.Environments[m.ActiveEnv], true
+}
+
+// switchEnvironment activates the i-th environment. Captured values are
+// dropped, as they belong to the environment they were captured in.
+func (m *Model) switchEnvironment(i int) {
+	if i == m.ActiveEnv {
+		return
+	}
+	m.ActiveEnv, m.captured = i, nil
+	m.refreshEnvironment()
+	env, _ := m.activeEnvironment()
+	style := styles.SuccessStyle
+	if env.Production() {
+		style = styles.ErrorStyle
+	}
+	m.Notice = style.Render("Environment: " + env.Name)
+}
+
+// updateEnvPicker handles keys while the environment picker is open.
+func (m *Model) updateEnvPicker(msg tea.KeyMsg) tea.Cmd {
+	n := len(m.Environments)
+	switch msg.String() {
+	case "esc", "alt+n":
+		m.pickingEnv = false
+	case "enter":
+		m.pickingEnv = false
+		m.switchEnvironment(m.envCursor)
+	case "h", "left", "shift+tab":
+		m.envCursor = (m.envCursor + n - 1) % n
+	case "l", "right", "tab":
+		m.envCursor = (m.envCursor + 1) % n
+	}
+	return nil
+}
+
+// renderEnvPicker draws the environment picker shown in the help line.
+func (m Model) renderEnvPicker() string {
+	var names []string
+	for i, e := range m.Environments {
+		style := styles.InactiveTabStyle
+		switch {
+		case i == m.envCursor && e.Production():
+			style = styles.ProductionStyle
+		case i == m.envCursor:
+			style = styles.ActiveTabStyle
+		}
+		names = append(names, style.Render(e.Name))
+	}
+	return styles.FocusedInputStyle.Render("Environment:") + " " + lipgloss.JoinHorizontal(lipgloss.Top, names...) +
+		styles.HelpStyle.Render("  h/l:choose  enter:switch  esc:cancel")
+}
+
+// environmentBadge labels the active environment in the response header,
+// in a warning style for production.
+func (m Model) environmentBadge() string {
+	env, ok := m.activeEnvironment()
+	if !ok {
+		return ""
+	}
+	if env.Production() {
+		return styles.ProductionStyle.Render("⚠ " + env.Name)
+	}
+	return styles.EnvironmentStyle.Render(env.Name)
+}
+
+// envLayer is a set of variables and where they come from.
+type envLayer struct {
+	source string
+	vars   map[string]string
+}
+
+// refreshEnvironment merges the variables of all sources. Captured values
+// win over the active named environment, which wins over the base
+// environment of config.lua, .http files and the OpenAPI spec.
+func (m *Model) refreshEnvironment() {
+	env := make(map[string]string)
+	sources := make(map[string]string)
+	layers := []envLayer{
+		{sourceSpec, m.specVars},
+		{sourceFile, m.fileVars},
+		{sourceConfig, m.configEnv},
+	}
+	if named, ok := m.activeEnvironment(); ok {
+		layers = append(layers, envLayer{sourceConfig + ":" + named.Name, named.Vars})
+	}
+	for _, layer := range append(layers, envLayer{sourceCapture, m.captured}) {
+		for k, v := range layer.vars {
+			env[k], sources[k] = v, layer.source
+		}
+	}
+
+	// Resolve ${env:...}, ${dotenv:...} and ${secret:...} references.
+	m.secretVars, m.unresolved, m.vaultNeeded = map[string]bool{}, map[string]string{}, false
+	for k, v := range env {
+		if !secrets.HasRefs(v) {
+			continue
+		}
+		m.secretVars[k] = true
+		m.vaultNeeded = m.vaultNeeded || secrets.NeedsVault(v)
+		resolved, err := secrets.Expand(v, m.vault)
+		if err != nil {
+			m.unresolved[k] = err.Error()
+		}
+		env[k] = resolved
+	}
+	m.Environment, m.envSources = env, sources
+	if m.inspecting {
+		m.renderInspector()
+	}
+}
+
+// capture stores values captured from a response in the environment.
+func (m *Model) capture(values map[string]string) {
+	if len(values) == 0 {
+		return
+	}
+	if m.captured == nil {
+		m.captured = make(map[string]string)
+	}
+	names := make([]string, 0, len(values))
+	for k, v := range values {
+		m.captured[k] = v
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	m.refreshEnvironment()
+	m.Notice = styles.SuccessStyle.Render("Captured " + strings.Join(names, ", "))
+}
+
+func (m *Model) updateInspector(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc":
+		m.inspecting = false
+	case "x": // Forget captured values
+		m.captured = nil
+		m.refreshEnvironment()
+	default:
+		var cmd tea.Cmd
+		m.Inspector, cmd = m.Inspector.Update(msg)
+		return cmd
+	}
+	return nil
+}
+
+// renderInspector lists the resolved environment with the source of each value.
+func (m *Model) renderInspector() {
+	names := make([]string, 0, len(m.Environment))
+	for k := range m.Environment {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		m.Inspector.SetContent(styles.HelpStyle.Render("No variables defined."))
+		return
+	}
+
+	var b strings.Builder
+	width := max(m.Inspector.Width-2, 10)
+	for _, k := range names {
+		name := styles.JSONKeyStyle.Render(k)
+		source := styles.HelpStyle.Render(m.envSources[k])
+		if m.envSources[k] == sourceCapture {
+			source = styles.SuccessStyle.Render(sourceCapture)
+		}
+		value := truncate(m.Environment[k], width)
+		if m.secretVars[k] {
+			source += styles.HelpStyle.Render(" secret")
+			value = truncate(secrets.Mask(m.Environment[k]), width)
+		}
+		if err, ok := m.unresolved[k]; ok {
+			value = styles.ErrorStyle.Render(truncate(err, width))
+		}
+		fmt.Fprintf(&b, "%s %s\n  %s\n", name, source, value)
+	}
+	m.Inspector.SetContent(b.String())
+}
+
+// truncate shortens s to width cells, marking the cut with an ellipsis.
+func truncate(s string, width int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if lipgloss.Width(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	if len(r) > width {
+		r = r[:width]
+	}
+	for len(r) > 0 && lipgloss.Width(string(r)) > width-1 {
+		r = r[:len(r)-1]
+	}
+	return string(r) + "…"
+}
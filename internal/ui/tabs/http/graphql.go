@@ -0,0 +1,489 @@
+package http
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"phantom/internal/app"
+	"phantom/internal/ui/components/styles"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// MethodGraphQL is the pseudo-method of GraphQL requests. They are sent as
+// POST requests whose JSON body holds the Body as the query, and the
+// Variables.
+const MethodGraphQL = "GRAPHQL"
+
+// graphQLBody builds the JSON envelope of a GraphQL request. variables must
+// be a JSON object, or empty.
+func graphQLBody(query, variables string) ([]byte, error) {
+	envelope := struct {
+		Query     string          `json:"query"`
+		Variables json.RawMessage `json:"variables,omitempty"`
+	}{Query: query}
+	if v := strings.TrimSpace(variables); v != "" {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(v), &obj); err != nil {
+			return nil, fmt.Errorf("GraphQL variables must be a JSON object: %w", err)
+		}
+		envelope.Variables = json.RawMessage(v)
+	}
+	return json.Marshal(envelope)
+}
+
+// splitGraphQLBody separates the query of a GRAPHQL request in a .http file
+// from the JSON object of variables that may follow it after a blank line.
+func splitGraphQLBody(body string) (query, variables string) {
+	i := strings.LastIndex(body, "\n\n")
+	for i >= 0 {
+		rest := strings.TrimSpace(body[i:])
+		var obj map[string]json.RawMessage
+		if strings.HasPrefix(rest, "{") && json.Unmarshal([]byte(rest), &obj) == nil {
+			return strings.TrimSpace(body[:i]), rest
+		}
+		i = strings.LastIndex(body[:i], "\n\n")
+	}
+	return body, ""
+}
+
+// graphQLErrors returns the messages of the errors array of a GraphQL
+// response, which servers send along with a 200 status.
+func graphQLErrors(body string) []string {
+	var resp struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal([]byte(body), &resp) != nil {
+		return nil
+	}
+	msgs := make([]string, len(resp.Errors))
+	for i, e := range resp.Errors {
+		msgs[i] = e.Message
+	}
+	return msgs
+}
+
+// introspectionQuery asks for the types of a schema with their fields,
+// arguments and enum values.
+const introspectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types {
+      kind name description
+      fields(includeDeprecated: true) { name description args { ...InputValue } type { ...TypeRef } }
+      inputFields { ...InputValue }
+      enumValues(includeDeprecated: true) { name description }
+    }
+  }
+}
+fragment InputValue on __InputValue { name description type { ...TypeRef } defaultValue }
+fragment TypeRef on __Type {
+  kind name
+  ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name } } } } } }
+}`
+
+// gqlSchema is the result of introspectionQuery.
+type gqlSchema struct {
+	QueryType        *gqlName  `json:"queryType"`
+	MutationType     *gqlName  `json:"mutationType"`
+	SubscriptionType *gqlName  `json:"subscriptionType"`
+	Types            []gqlType `json:"types"`
+
+	byName map[string]*gqlType
+}
+
+type gqlName struct {
+	Name string `json:"name"`
+}
+
+type gqlType struct {
+	Kind        string          `json:"kind"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Fields      []gqlField      `json:"fields,omitempty"`
+	InputFields []gqlInputValue `json:"inputFields,omitempty"`
+	EnumValues  []gqlEnumValue  `json:"enumValues,omitempty"`
+}
+
+type gqlField struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Args        []gqlInputValue `json:"args,omitempty"`
+	Type        gqlTypeRef      `json:"type"`
+}
+
+type gqlInputValue struct {
+	Name         string     `json:"name"`
+	Description  string     `json:"description,omitempty"`
+	Type         gqlTypeRef `json:"type"`
+	DefaultValue *string    `json:"defaultValue,omitempty"`
+}
+
+type gqlEnumValue struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// gqlTypeRef is a possibly wrapped type, such as [User!]!.
+type gqlTypeRef struct {
+	Kind   string      `json:"kind"`
+	Name   string      `json:"name,omitempty"`
+	OfType *gqlTypeRef `json:"ofType,omitempty"`
+}
+
+func (t gqlTypeRef) String() string {
+	switch {
+	case t.OfType == nil:
+		return t.Name
+	case t.Kind == "NON_NULL":
+		return t.OfType.String() + "!"
+	case t.Kind == "LIST":
+		return "[" + t.OfType.String() + "]"
+	}
+	return t.Name
+}
+
+// named returns the name of the type t wraps.
+func (t gqlTypeRef) named() string {
+	for t.OfType != nil {
+		t = *t.OfType
+	}
+	return t.Name
+}
+
+func (s *gqlSchema) index() {
+	s.byName = make(map[string]*gqlType, len(s.Types))
+	for i := range s.Types {
+		s.byName[s.Types[i].Name] = &s.Types[i]
+	}
+}
+
+func (s *gqlSchema) typ(name string) *gqlType {
+	return s.byName[name]
+}
+
+// rootType returns the name of the type of an operation: "query",
+// "mutation" or "subscription".
+func (s *gqlSchema) rootType(operation string) string {
+	var n *gqlName
+	switch operation {
+	case "query":
+		n = s.QueryType
+	case "mutation":
+		n = s.MutationType
+	case "subscription":
+		n = s.SubscriptionType
+	}
+	if n == nil {
+		return ""
+	}
+	return n.Name
+}
+
+// GraphQLSchemaMsg is sent once the schema of an endpoint has been read
+// from the cache or introspected.
+type GraphQLSchemaMsg struct {
+	URL    string
+	Schema *gqlSchema
+	Cached bool
+	Err    error
+}
+
+// loadGraphQLSchema returns the schema of the endpoint of item, from the
+// cache unless refresh is set, or else by running introspectionQuery.
+func loadGraphQLSchema(item RequestItem, env map[string]string, refresh bool) tea.Cmd {
+	url := Substitute(item.URL, env)
+	return func() tea.Msg {
+		path, err := schemaCachePath(url)
+		if err == nil && !refresh {
+			if schema, err := readSchemaCache(path); err == nil {
+				return GraphQLSchemaMsg{URL: url, Schema: schema, Cached: true}
+			}
+		}
+		schema, err := introspect(item, env)
+		if err != nil {
+			return GraphQLSchemaMsg{URL: url, Err: err}
+		}
+		if path != "" {
+			if data, err := json.Marshal(schema); err == nil {
+				if err := os.WriteFile(path, data, 0o600); err != nil {
+					return GraphQLSchemaMsg{URL: url, Schema: schema, Err: fmt.Errorf("caching schema: %w", err)}
+				}
+			}
+		}
+		return GraphQLSchemaMsg{URL: url, Schema: schema}
+	}
+}
+
+// introspect runs introspectionQuery with the headers and Auth section of item.
+func introspect(item RequestItem, env map[string]string) (*gqlSchema, error) {
+	item.Method, item.Body, item.Variables = MethodGraphQL, introspectionQuery, ""
+	item.Stream = false
+	resp, err := Execute(context.Background(), item, env)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Data struct {
+			Schema *gqlSchema `json:"__schema"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Body, &result); err != nil || result.Data.Schema == nil {
+		if msgs := graphQLErrors(string(resp.Body)); len(msgs) > 0 {
+			return nil, fmt.Errorf("introspection failed: %s", msgs[0])
+		}
+		return nil, fmt.Errorf("introspection failed: %s", resp.Status)
+	}
+	result.Data.Schema.index()
+	return result.Data.Schema, nil
+}
+
+// schemaCachePath is where the schema of the endpoint url is cached, in
+// the data directory of the project.
+func schemaCachePath(url string) (string, error) {
+	dir, err := app.ProjectDataDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "graphql")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	sum := sha1.Sum([]byte(url))
+	return filepath.Join(dir, fmt.Sprintf("%x.json", sum[:8])), nil
+}
+
+func readSchemaCache(path string) (*gqlSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var schema gqlSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, err
+	}
+	if schema.QueryType == nil {
+		return nil, errors.New("no query type")
+	}
+	schema.index()
+	return &schema, nil
+}
+
+// isGraphQL reports whether the editor holds a GraphQL request.
+func (m Model) isGraphQL() bool {
+	return m.Methods[m.SelectedMethod] == MethodGraphQL
+}
+
+// schemaURL is the endpoint of the request in the editor, which its
+// schema is cached under.
+func (m Model) schemaURL() string {
+	return m.substituteEnv(m.URL.Value())
+}
+
+// schema returns the schema of the endpoint in the editor, if it is loaded.
+func (m Model) schema() *gqlSchema {
+	return m.schemas[m.schemaURL()]
+}
+
+// fetchSchema loads the schema of the endpoint in the editor.
+func (m *Model) fetchSchema(refresh bool) tea.Cmd {
+	if strings.TrimSpace(m.URL.Value()) == "" {
+		m.Notice = styles.ErrorStyle.Render("GraphQL: no endpoint URL")
+		return nil
+	}
+	m.commitTables()
+	item := m.Timeouts.Apply(m.currentRequest())
+	m.Notice = styles.HelpStyle.Render("Fetching the GraphQL schema…")
+	return loadGraphQLSchema(item, m.Environment, refresh)
+}
+
+// completeField completes the field name before the cursor of the Query
+// editor from the schema: a single match is inserted, several are extended
+// to their common prefix and listed.
+func (m *Model) completeField() tea.Cmd {
+	schema := m.schema()
+	if schema == nil {
+		return m.fetchSchema(false)
+	}
+	typeName, prefix, ok := gqlCursorContext(schema, m.textBeforeCursor())
+	t := schema.typ(typeName)
+	if !ok || t == nil {
+		m.Notice = styles.HelpStyle.Render("No fields to complete here")
+		return nil
+	}
+	var names []string
+	for _, f := range t.Fields {
+		if strings.HasPrefix(f.Name, prefix) {
+			names = append(names, f.Name)
+		}
+	}
+	if t.Kind != "INPUT_OBJECT" && strings.HasPrefix("__typename", prefix) {
+		names = append(names, "__typename")
+	}
+	if len(names) == 0 {
+		m.Notice = styles.HelpStyle.Render(fmt.Sprintf("No fields of %s start with %q", typeName, prefix))
+		return nil
+	}
+	common := names[0]
+	for _, n := range names[1:] {
+		for !strings.HasPrefix(n, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	m.Body.InsertString(common[len(prefix):])
+	m.Notice = ""
+	if len(names) > 1 {
+		m.Notice = styles.HelpStyle.Render(typeName + ": " + strings.Join(names, " "))
+	}
+	return nil
+}
+
+// textBeforeCursor returns the Query editor's text up to the cursor.
+func (m Model) textBeforeCursor() string {
+	lines := strings.Split(m.Body.Value(), "\n")
+	row := min(m.Body.Line(), len(lines)-1)
+	info := m.Body.LineInfo()
+	line := []rune(lines[row])
+	col := min(info.StartColumn+info.ColumnOffset, len(line))
+	return strings.Join(append(lines[:row:row], string(line[:col])), "\n")
+}
+
+// gqlCursorContext finds the type whose fields can be typed at the end of
+// text, and the part of a field name already typed. ok is false inside
+// arguments, strings and comments.
+func gqlCursorContext(schema *gqlSchema, text string) (typeName, prefix string, ok bool) {
+	end := len(text)
+	for end > 0 && isNameByte(text[end-1]) {
+		end--
+	}
+	prefix, text = text[end:], text[:end]
+
+	var stack []string // types of the open selection sets
+	var pending string // type of the next selection set
+	var last, prev string
+	parens := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '#':
+			nl := strings.IndexByte(text[i:], '\n')
+			if nl < 0 {
+				return "", "", false
+			}
+			i += nl
+		case c == '"':
+			n := stringEnd(text[i:])
+			if n < 0 {
+				return "", "", false
+			}
+			i += n - 1
+		case c == '(':
+			parens++
+		case c == ')':
+			parens--
+		case parens > 0:
+		case c == '@': // directives are not fields
+			for i+1 < len(text) && isNameByte(text[i+1]) {
+				i++
+			}
+		case c == '{':
+			t := pending
+			if t == "" && len(stack) == 0 {
+				t = schema.rootType("query") // the shorthand { ... } query
+			} else if t == "" && last != "" {
+				if parent := schema.typ(stack[len(stack)-1]); parent != nil {
+					for _, f := range parent.Fields {
+						if f.Name == last {
+							t = f.Type.named()
+						}
+					}
+				}
+			}
+			stack, pending, last = append(stack, t), "", ""
+		case c == '}':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			last = ""
+		case isNameByte(c):
+			j := i
+			for j < len(text) && isNameByte(text[j]) {
+				j++
+			}
+			word := text[i:j]
+			i = j - 1
+			switch {
+			case len(stack) == 0 && (word == "query" || word == "mutation" || word == "subscription"):
+				pending = schema.rootType(word)
+			case prev == "on":
+				pending = word // fragment X on Type, ... on Type
+			case word != "on" && word != "fragment":
+				last = word
+			}
+			prev = word
+			continue
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' && c != ',' {
+			prev = ""
+		}
+	}
+	if parens > 0 || len(stack) == 0 {
+		return "", "", false
+	}
+	return stack[len(stack)-1], prefix, true
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// stringEnd returns the length of the GraphQL string, block strings
+// included, at the start of s, or -1 if it is not closed.
+func stringEnd(s string) int {
+	if strings.HasPrefix(s, `"""`) {
+		n := strings.Index(s[3:], `"""`)
+		if n < 0 {
+			return -1
+		}
+		return n + 6
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		case '\n':
+			return -1
+		}
+	}
+	return -1
+}
+
+// renderGraphQLErrors summarises the errors array of the last response for
+// the response header.
+func (m Model) renderGraphQLErrors() string {
+	n := len(m.GraphQLErrors)
+	if n == 0 {
+		return ""
+	}
+	first := []rune(m.GraphQLErrors[0])
+	if len(first) > 40 {
+		first = append(first[:40], '…')
+	}
+	label := "1 GraphQL error"
+	if n > 1 {
+		label = fmt.Sprintf("%d GraphQL errors", n)
+	}
+	return " · " + styles.ErrorStyle.Render(label+": "+string(first))
+}
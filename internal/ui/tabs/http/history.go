@@ -0,0 +1,356 @@
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"phantom/internal/app"
+	"phantom/internal/secrets"
+	"phantom/internal/utils"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	historyFile        = "history.jsonl"
+	maxHistoryEntries  = 1000
+	maxSnapshotBody    = 256 << 10 // 256 KiB
+	historyDateLayout  = "01-02 15:04:05"
+	historyStatusError = "err"
+	// Outcomes of requests that did not complete, see HistoryEntry.Outcome.
+	historyStatusCancelled = "cancelled"
+	historyStatusTimeout   = "timeout"
+)
+
+// MethodGRPC is the method of the calls the gRPC tab records in the
+// history. Their URL is grpc://host:port/package.Service/Method.
+const MethodGRPC = "GRPC"
+
+// HistoryEntry is one sent request and a snapshot of its outcome, as stored on disk.
+type HistoryEntry struct {
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	URL        string    `json:"url"`
+	Headers    string    `json:"headers,omitempty"`
+	Body       string    `json:"body,omitempty"`
+	Auth       string    `json:"auth,omitempty"`
+	BodyMode   string    `json:"body_mode,omitempty"`
+	Variables  string    `json:"variables,omitempty"`
+	Status     int       `json:"status"`
+	StatusText string    `json:"status_text,omitempty"`
+	Proto      string    `json:"proto,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Outcome    string    `json:"outcome,omitempty"` // "cancelled" or "timeout" when Error says so
+	DurationMS int64     `json:"duration_ms"`
+	Size       int       `json:"size"`
+	Response   *Snapshot `json:"response,omitempty"`
+	Timing     *Timing   `json:"timing,omitempty"`
+}
+
+// Snapshot is the stored copy of a response, its body truncated to
+// maxSnapshotBody bytes once encoded.
+type Snapshot struct {
+	Headers   string `json:"headers"`
+	Body      string `json:"body"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// Request returns the entry as a RequestItem, ready to be loaded into the editor.
+func (e HistoryEntry) Request() RequestItem {
+	return RequestItem{Name: e.URL, Method: e.Method, URL: e.URL, Auth: e.Auth, Headers: e.Headers, Body: e.Body, BodyMode: e.BodyMode, Variables: e.Variables}
+}
+
+// historyItem adapts a HistoryEntry to the History list.
+type historyItem struct{ HistoryEntry }
+
+func (i historyItem) Title() string {
+	status := strconv.Itoa(i.Status)
+	if i.Error != "" {
+		status = historyStatusError
+	}
+	if i.Method == MethodGRPC {
+		status = i.StatusText // the status code, e.g. OK or NotFound
+	}
+	if i.Outcome != "" {
+		status = i.Outcome
+	}
+	return fmt.Sprintf("%s %s %s", status, i.Method, i.URL)
+}
+
+func (i historyItem) Description() string {
+	return fmt.Sprintf("%s · %dms · %s", i.Time.Local().Format(historyDateLayout), i.DurationMS, utils.FormatBytes(uint64(i.Size)))
+}
+
+func (i historyItem) FilterValue() string { return i.Method + " " + i.URL }
+
+// HistoryStore persists history entries as JSON lines in a per-project file.
+type HistoryStore struct {
+	Path string
+}
+
+// NewHistoryStore returns the history store of the current project.
+func NewHistoryStore() (*HistoryStore, error) {
+	dir, err := app.ProjectDataDir()
+	if err != nil {
+		return nil, err
+	}
+	return &HistoryStore{Path: filepath.Join(dir, historyFile)}, nil
+}
+
+// Append adds an entry to the end of the history file.
+func (s *HistoryStore) Append(e HistoryEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Load returns the stored entries, newest first. When the file has grown past
+// maxHistoryEntries it is rewritten with only the newest entries.
+func (s *HistoryStore) Load() ([]HistoryEntry, error) {
+	f, err := os.Open(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []HistoryEntry
+	r := bufio.NewReader(f) // lines have no length limit, unlike with a Scanner
+	for {
+		line, err := r.ReadBytes('\n')
+		var e HistoryEntry
+		if len(line) > 0 && json.Unmarshal(line, &e) == nil {
+			entries = append(entries, e)
+		} // else skip corrupt lines rather than losing the whole history
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(entries) > maxHistoryEntries {
+		entries = entries[len(entries)-maxHistoryEntries:]
+		if err := s.rewrite(entries); err != nil {
+			return nil, err
+		}
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+func (s *HistoryStore) rewrite(entries []HistoryEntry) error {
+	tmp := s.Path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path)
+}
+
+// HistoryFilter narrows the history list. It is parsed from a query such as
+// "method:post status:4xx url:/users token", where bare words match the URL.
+type HistoryFilter struct {
+	Method string
+	Status string // exact code, class like "4xx", "err", "cancelled" or "timeout"
+	URL    []string
+}
+
+// ParseHistoryFilter parses a history search query.
+func ParseHistoryFilter(query string) HistoryFilter {
+	var f HistoryFilter
+	for _, field := range strings.Fields(query) {
+		k, v, ok := strings.Cut(field, ":")
+		switch {
+		case ok && strings.EqualFold(k, "method"):
+			f.Method = strings.ToUpper(v)
+		case ok && strings.EqualFold(k, "status"):
+			f.Status = strings.ToLower(v)
+		case ok && strings.EqualFold(k, "url"):
+			f.URL = append(f.URL, strings.ToLower(v))
+		default:
+			f.URL = append(f.URL, strings.ToLower(field))
+		}
+	}
+	return f
+}
+
+// Match reports whether e satisfies every part of the filter.
+func (f HistoryFilter) Match(e HistoryEntry) bool {
+	if f.Method != "" && e.Method != f.Method {
+		return false
+	}
+	if f.Status != "" && !matchStatus(f.Status, e) {
+		return false
+	}
+	url := strings.ToLower(e.URL)
+	for _, part := range f.URL {
+		if !strings.Contains(url, part) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchStatus(pattern string, e HistoryEntry) bool {
+	if e.Method == MethodGRPC && strings.EqualFold(pattern, e.StatusText) {
+		return true
+	}
+	if pattern == historyStatusError {
+		return e.Error != ""
+	}
+	if pattern == historyStatusCancelled || pattern == historyStatusTimeout {
+		return e.Outcome == pattern
+	}
+	if e.Error != "" {
+		return false
+	}
+	return statusMatches(pattern, e.Status)
+}
+
+// HistoryLoadedMsg carries the entries read from the history store.
+type HistoryLoadedMsg struct {
+	Entries []HistoryEntry
+	Err     error
+}
+
+func loadHistory(store *HistoryStore) tea.Cmd {
+	return func() tea.Msg {
+		entries, err := store.Load()
+		return HistoryLoadedMsg{Entries: entries, Err: err}
+	}
+}
+
+// OpenGRPCMsg asks for a call from the history to be opened in the gRPC tab.
+type OpenGRPCMsg struct {
+	Entry HistoryEntry
+}
+
+// AddHistory puts e at the top of the history and saves it. Other tabs
+// record their calls through it.
+func (m *Model) AddHistory(e HistoryEntry) tea.Cmd {
+	e.Headers, _ = clipEncoded(e.Headers, maxSnapshotBody)
+	e.Body, _ = clipEncoded(e.Body, maxSnapshotBody)
+	e.Variables, _ = clipEncoded(e.Variables, maxSnapshotBody)
+	if e.Response != nil {
+		snapshot := *e.Response
+		snapshot.Headers, _ = clipEncoded(snapshot.Headers, maxSnapshotBody)
+		var cut bool
+		snapshot.Body, cut = clipEncoded(snapshot.Body, maxSnapshotBody)
+		snapshot.Truncated = snapshot.Truncated || cut
+		e.Response = &snapshot
+	}
+	m.HistoryEntries = append([]HistoryEntry{e}, m.HistoryEntries...)
+	if len(m.HistoryEntries) > maxHistoryEntries {
+		m.HistoryEntries = m.HistoryEntries[:maxHistoryEntries]
+	}
+	m.filterHistory()
+	if m.historyStore == nil {
+		return nil
+	}
+	return saveHistory(m.historyStore, e)
+}
+
+// clipEncoded cuts s to the longest prefix that takes at most limit bytes
+// in JSON, where control characters and invalid UTF-8 grow to six bytes
+// each, and reports whether it had to.
+func clipEncoded(s string, limit int) (string, bool) {
+	n := 0
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1, r < 0x20 && r != '\n' && r != '\r' && r != '\t',
+			r == '<', r == '>', r == '&', r == '\u2028', r == '\u2029':
+			n += 6 // \ufffd, \u0001, \u003c, ...
+		case r < 0x20, r == '"', r == '\\':
+			n += 2
+		default:
+			n += size
+		}
+		if n > limit {
+			return s[:i], true
+		}
+		i += size
+	}
+	return s, false
+}
+
+func saveHistory(store *HistoryStore, e HistoryEntry) tea.Cmd {
+	return func() tea.Msg {
+		if err := store.Append(e); err != nil {
+			log.Printf("saving http history: %v", err)
+		}
+		return nil
+	}
+}
+
+// newHistoryEntry records the outcome of a request.
+func newHistoryEntry(req RequestItem, msg HTTPResponseMsg) HistoryEntry {
+	e := HistoryEntry{
+		Time:       time.Now(),
+		Method:     req.Method,
+		URL:        secrets.Mask(req.URL),
+		Headers:    secrets.Mask(req.Headers),
+		Body:       secrets.Mask(req.Body),
+		Auth:       secrets.Mask(req.Auth),
+		BodyMode:   req.BodyMode,
+		Variables:  secrets.Mask(req.Variables),
+		Status:     msg.Code,
+		StatusText: msg.Status,
+		Proto:      msg.Proto,
+		DurationMS: msg.Timing.Total.Milliseconds(),
+		Size:       len(msg.Body),
+	}
+	if msg.Err != nil {
+		e.Error = secrets.Mask(msg.Err.Error())
+		switch {
+		case errors.Is(msg.Err, ErrCancelled):
+			e.Outcome = historyStatusCancelled
+		case errors.Is(msg.Err, ErrTimeout):
+			e.Outcome = historyStatusTimeout
+		}
+		return e
+	}
+	timing := msg.Timing
+	e.Timing = &timing
+	e.Response = &Snapshot{Headers: secrets.Mask(msg.Headers), Body: secrets.Mask(msg.Body)} // clipped by AddHistory
+	return e
+}
@@ -0,0 +1,97 @@
+package http
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/list"
+)
+
+func TestHistoryStoreLoadLongLines(t *testing.T) {
+	store := &HistoryStore{Path: filepath.Join(t.TempDir(), historyFile)}
+	if err := store.Append(HistoryEntry{Method: "GET", URL: "https://example.com/first"}); err != nil {
+		t.Fatal(err)
+	}
+	// A line longer than any Scanner buffer, as older versions could write.
+	huge := HistoryEntry{Method: "GET", URL: "https://example.com/huge", Response: &Snapshot{Body: strings.Repeat("\x01", 1<<20)}}
+	if err := store.Append(huge); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(store.Path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("{corrupt\n")
+	f.Close()
+	if err := store.Append(HistoryEntry{Method: "POST", URL: "https://example.com/last"}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	var urls []string
+	for _, e := range entries {
+		urls = append(urls, e.URL)
+	}
+	want := "https://example.com/last https://example.com/huge https://example.com/first"
+	if got := strings.Join(urls, " "); got != want {
+		t.Errorf("Load URLs = %q, want %q", got, want)
+	}
+}
+
+func TestAddHistoryCapsEncodedSize(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"control characters", strings.Repeat("\x01", maxSnapshotBody)},
+		{"invalid UTF-8", strings.Repeat("\xff", maxSnapshotBody)},
+		{"markup", strings.Repeat("<a>&", maxSnapshotBody/4)},
+		{"multi-byte", strings.Repeat("é", maxSnapshotBody)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Model{History: list.New(nil, list.NewDefaultDelegate(), 0, 0)}
+			m.AddHistory(HistoryEntry{Method: "POST", Body: tt.body, Headers: tt.body, Response: &Snapshot{Headers: tt.body, Body: tt.body}})
+			e := m.HistoryEntries[0]
+			if !e.Response.Truncated {
+				t.Error("Truncated = false, want true")
+			}
+			for field, s := range map[string]string{"Body": e.Body, "Headers": e.Headers, "Response.Headers": e.Response.Headers, "Response.Body": e.Response.Body} {
+				b, _ := json.Marshal(s)
+				if len(b)-2 > maxSnapshotBody {
+					t.Errorf("%s takes %d bytes encoded, want at most %d", field, len(b)-2, maxSnapshotBody)
+				}
+				if len(s) == 0 {
+					t.Errorf("%s is empty", field)
+				}
+			}
+		})
+	}
+
+	m := Model{History: list.New(nil, list.NewDefaultDelegate(), 0, 0)}
+	m.AddHistory(HistoryEntry{Method: "GET", Response: &Snapshot{Body: "small"}})
+	if e := m.HistoryEntries[0]; e.Response.Body != "small" || e.Response.Truncated {
+		t.Errorf("small body = %q, truncated %v; want it kept whole", e.Response.Body, e.Response.Truncated)
+	}
+}
+
+func TestHistoryResponseKeepsRunes(t *testing.T) {
+	body := "a" + strings.Repeat("é", maxSnapshotBody) // a rune straddles every byte limit
+	e := newHistoryEntry(RequestItem{Method: "GET"}, HTTPResponseMsg{Code: 200, Body: body})
+	if e.Response.Body != body || e.Response.Truncated {
+		t.Fatalf("newHistoryEntry kept %d of %d bytes; want AddHistory to do all the clipping", len(e.Response.Body), len(body))
+	}
+	m := Model{History: list.New(nil, list.NewDefaultDelegate(), 0, 0)}
+	m.AddHistory(e)
+	snap := m.HistoryEntries[0].Response
+	if !snap.Truncated || !utf8.ValidString(snap.Body) || !strings.HasPrefix(body, snap.Body) {
+		t.Errorf("body of %d bytes, truncated %v, valid UTF-8 %v; want a valid prefix of the response", len(snap.Body), snap.Truncated, utf8.ValidString(snap.Body))
+	}
+}
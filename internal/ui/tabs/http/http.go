@@ -1,12 +1,14 @@
 package http
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"net/http"
-	"os/exec"
-	"regexp"
+	"log"
 	"strings"
+	"time"
 
+	"phantom/internal/secrets"
 	"phantom/internal/ui/components/styles" // Corrected import path
 	"phantom/internal/utils"                // Corrected import path
 
@@ -24,69 +26,203 @@ import (
 type Model struct {
 	Width, Height int
 	// Panes
-	Collections list.Model
-	History     list.Model
+	Collections    list.Model
+	History        list.Model
+	HistoryEntries []HistoryEntry
+	HistorySearch  textinput.Model
+	ListFocus      int // 0: Collections, 1: History
+	searching      bool
+	historyStore   *HistoryStore
 	// Inputs
 	Methods        []string
 	SelectedMethod int
 	URL            textinput.Model
-	Headers        textarea.Model
+	Params         kvTable // the query string of URL, plus disabled parameters
+	Auth           textinput.Model
+	Headers        kvTable
 	Body           textarea.Model
+	BodyMode       int            // index into BodyModes
+	Variables      textarea.Model // of GRAPHQL requests, whose Body is the query
 	// Response
-	Response        viewport.Model
-	ResponseHeaders string
-	ResponseBody    string
-	ResponseCode    int
-	ResponseViewTab int // 0: Pretty, 1: Raw, 2: Headers
+	Response          viewport.Model
+	tree              *jsonTree // the Pretty view of JSON bodies, see showsTree
+	ResponseHeaders   string
+	ResponseBody      string
+	ResponseCode      int
+	ResponseStatus    string
+	ResponseProto     string
+	ResponseTiming    Timing
+	ResponseRedirects []Redirect
+	ResponseViewTab   int // index into responseViews
+	TestResults       []AssertionResult
+	GraphQLErrors     []string       // messages of the errors array of a GraphQL response
+	run               *collectionRun // the last collection run, if it is what the Tests view shows
+	// Streaming response: the stream of the request being sent, its kind
+	// once the response started, and the events received so far
+	stream        *responseStream
+	streamKind    string
+	streamEvents  []StreamEvent
+	streamCount   int
+	streamStopped bool
+	streamErr     string
+	// WebSocket connection of the WS method and its message log
+	ws        *wsConn
+	wsLog     []wsFrame
+	wsMessage int // index of the saved message in the Body editor
+	// Export
+	Snippet     viewport.Model
+	SnippetLang int // index into SnippetLanguages
+	exporting   bool
+	snippetText string
 	// State
 	FocusedPane  int // 0: List, 1: Request, 2: Response
-	FocusedInput int // 0: Method, 1: URL, 2: Headers, 3: Body
+	FocusedInput int // 0: Method, 1: URL, 2: Params, 3: Auth, 4: Headers, 5: Body, 6: Variables
 	Sending      bool
+	cancel       context.CancelCauseFunc // cancels the request being sent
+	Timeouts     Timeouts                // for requests that set none
 	Spinner      spinner.Model
 	LastError    string
+	Notice       string
+	Loaded       RequestItem // the collection item last loaded into the editor
+	Prompt       textinput.Model
+	promptAction string // set while Prompt is open, see promptTitles
+	// Environment inspector, shown in place of the lists
+	Inspector  viewport.Model
+	inspecting bool
+	// GraphQL schemas by endpoint URL, and their browser, also shown in
+	// place of the lists
+	schemas        map[string]*gqlSchema
+	schemaTree     schemaBrowser
+	browsingSchema bool
+	// Named environments from config.lua and the active one, an index into
+	// Environments or -1
+	Environments []NamedEnvironment
+	ActiveEnv    int
+	configEnvs   []NamedEnvironment // of config.lua
+	importedEnvs []NamedEnvironment // from Postman, see EnvironmentStore; nil until loaded
+	pendingEnv   string             // active environment of config.lua, if not found before importedEnvs loaded
+	envStore     *EnvironmentStore
+	pickingEnv   bool
+	envCursor    int
 	// Config
-	Environment map[string]string
+	Environment  map[string]string
+	Templates    []list.Item
+	FileRequests []list.Item
+	SpecRequests []list.Item // generated from the OpenAPI spec
+	configEnv    map[string]string
+	fileVars     map[string]string
+	specVars     map[string]string
+	captured     map[string]string // values from response captures, which win over everything else
+	envSources   map[string]string // where each Environment value came from
+	// Secrets: the unlocked secrets file, the variables that reference
+	// secrets and why some of them could not be resolved
+	vault       *secrets.Vault
+	vaultPath   string
+	secretVars  map[string]bool
+	unresolved  map[string]string
+	vaultNeeded bool // a variable references the secrets file
+	// OpenAPI spec the SpecRequests are generated from, and its last seen modification time
+	openAPIPath    string
+	openAPIModTime time.Time
 }
 
 // RequestItem represents an item in the collections/history list.
 type RequestItem struct {
 	Name, Method, URL, Headers, Body string
+	Group                            string // folder path such as "Users/Admin"
+	Auth                             string // Auth section, see ParseAuth
+	BodyMode                         string // one of BodyModes; empty for raw
+	// Timeouts of the request; zero uses those of config.lua.
+	Timeout, ConnectTimeout time.Duration
+	// Source is the .http file the request was loaded from, if any, and
+	// SourceName its name there, used to find it again when saving.
+	Source, SourceName string
+	// Checks run against every response to this request.
+	Assertions []Assertion
+	Test       TestFunc
+	// Captures copy parts of the response into the environment.
+	Captures []Capture
+	// Messages are the saved messages of a WS request.
+	Messages []string
+	// Variables are the variables of a GRAPHQL request, a JSON object.
+	Variables string
+	// Stream shows the response line by line as it arrives, whatever its
+	// Content-Type. Server-sent events and NDJSON always are.
+	Stream bool
 }
 
-func (i RequestItem) Title() string       { return fmt.Sprintf("%s %s", i.Method, i.Name) }
-func (i RequestItem) Description() string { return i.URL }
+func (i RequestItem) Title() string { return fmt.Sprintf("%s %s", i.Method, i.Name) }
+func (i RequestItem) Description() string {
+	if i.Group != "" {
+		return i.Group + " · " + i.URL
+	}
+	return i.URL
+}
 func (i RequestItem) FilterValue() string { return i.Name }
 
 // HTTPResponseMsg is sent when an HTTP request completes.
 type HTTPResponseMsg struct {
 	Body, Headers string
 	Code          int
+	Status        string
+	Proto         string
+	Timing        Timing
+	Redirects     []Redirect
 	Err           error
+	Request       RequestItem // the request as typed, before substitution
+	Results       []AssertionResult
+	Captured      map[string]string // values of the request's captures
 }
 
+// responseViews are the tabs of the response pane.
+var responseViews = []string{"Pretty", "Raw", "Headers", "Timing", "Tests"}
+
+// testsView is the index of the Tests view in responseViews.
+const testsView = 4
+
+// Inputs of the request pane, see FocusedInput.
+const (
+	paramsInput    = 2
+	headersInput   = 4
+	bodyInput      = 5
+	variablesInput = 6 // only shown for GRAPHQL requests
+	numInputs      = 7
+)
+
 // New creates a new HTTP model.
 func New() Model {
 	m := Model{
 		FocusedPane:     1,
 		FocusedInput:    0,
 		ResponseViewTab: 0,
-		Methods:         []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
+		Methods:         []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS", MethodGraphQL, MethodWS},
 		SelectedMethod:  0,
+		ActiveEnv:       -1,
+		Timeouts:        DefaultTimeouts,
 	}
 
 	m.URL = textinput.New()
 	m.URL.Placeholder = "https://api.example.com"
 	m.URL.Prompt = ""
 
-	m.Headers = textarea.New()
-	m.Headers.Placeholder = `"Content-Type": "application/json"`
-	m.Headers.SetHeight(5)
+	m.Auth = textinput.New()
+	m.Auth.Placeholder = "bearer {{token}} · basic user:pass · apikey header X-API-Key {{key}} · digest · oauth2"
+	m.Auth.Prompt = ""
+
+	m.Params = newKVTable("Query", "=", "No query parameters · a to add", 3, checkParamRow)
+	m.Headers = newKVTable("Headers", ":", "No headers · a to add, or paste Name: value", 5, checkHeaderRow)
 
 	m.Body = textarea.New()
 	m.Body.Placeholder = `{"key": "value"}`
 	m.Body.SetHeight(10)
 
+	m.Variables = textarea.New()
+	m.Variables.Placeholder = `{"id": 1}`
+	m.Variables.SetHeight(4)
+
 	m.Response = viewport.New(0, 0)
+	m.Snippet = viewport.New(0, 0)
+	m.Inspector = viewport.New(0, 0)
 	m.Spinner = spinner.New()
 	m.Spinner.Spinner = spinner.Dot
 	m.Spinner.Style = styles.SpinnerStyle
@@ -95,6 +231,25 @@ func New() Model {
 	m.Collections.Title = "Collections"
 	m.History = list.New(nil, list.NewDefaultDelegate(), 0, 0)
 	m.History.Title = "History"
+	m.History.SetFilteringEnabled(false) // filtered by HistorySearch instead
+
+	m.Prompt = textinput.New()
+	m.Prompt.Prompt = "> "
+
+	m.HistorySearch = textinput.New()
+	m.HistorySearch.Placeholder = "method:post status:4xx url:/users"
+	m.HistorySearch.Prompt = "/"
+
+	store, err := NewHistoryStore()
+	if err != nil {
+		log.Printf("http history disabled: %v", err)
+	}
+	m.historyStore = store
+	if m.envStore, err = NewEnvironmentStore(); err != nil {
+		log.Printf("http imported environments disabled: %v", err)
+	}
+	m.openAPIPath = FindOpenAPISpec(".")
+	m.vaultPath = secrets.DefaultVaultFile
 
 	m.focus() // Set initial focus
 	return m
@@ -102,7 +257,17 @@ func New() Model {
 
 // Init initializes the HTTP model.
 func (m Model) Init() tea.Cmd {
-	return m.Spinner.Tick
+	cmds := []tea.Cmd{m.Spinner.Tick, loadHTTPFiles()}
+	if m.historyStore != nil {
+		cmds = append(cmds, loadHistory(m.historyStore))
+	}
+	if m.envStore != nil {
+		cmds = append(cmds, loadEnvironments(m.envStore))
+	}
+	if m.openAPIPath != "" {
+		cmds = append(cmds, watchOpenAPI(m.openAPIPath, time.Time{}))
+	}
+	return tea.Batch(cmds...)
 }
 
 // Update handles messages for the HTTP model.
@@ -113,6 +278,36 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		if m.Sending {
+			if s := msg.String(); (s == "esc" || s == "ctrl+c") && m.cancel != nil {
+				m.cancel(ErrCancelled)
+				return m, nil
+			}
+			if m.streamKind == "" { // the rest of the tab stays usable while a stream runs
+				return m, nil
+			}
+		}
+		if m.Running() && msg.String() == "ctrl+c" {
+			m.run.cancel(ErrCancelled)
+			return m, nil
+		}
+		if m.promptAction != "" {
+			return m, m.updatePrompt(msg)
+		}
+		if m.pickingEnv {
+			return m, m.updateEnvPicker(msg)
+		}
+		if m.exporting {
+			return m, m.updateExport(msg)
+		}
+		if m.inspecting && m.FocusedPane == 0 && msg.String() != "alt+v" && msg.String() != "ctrl+l" && msg.String() != "alt+u" {
+			return m, m.updateInspector(msg)
+		}
+		if m.browsingSchema && m.FocusedPane == 0 && msg.String() != "alt+g" && msg.String() != "ctrl+l" {
+			return m, m.updateSchemaBrowser(msg)
+		}
+		// Esc cancels a collection run, unless it ends a search or a cell edit.
+		if m.Running() && msg.String() == "esc" && !m.searching && !m.Params.editing && !m.Headers.editing {
+			m.run.cancel(ErrCancelled)
 			return m, nil
 		}
 
@@ -123,24 +318,69 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			m.focus()
 			return m, nil
 		case "ctrl+s": // Send request
-			m.Sending = true
-			m.LastError = ""
-			m.ResponseBody = ""
-			m.ResponseHeaders = ""
-			cmds = append(cmds, m.Spinner.Tick, m.sendRequest())
-			return m, tea.Batch(cmds...)
+			return m, m.send()
+		case "alt+s": // Save request to a .http file
+			m.commitTables()
+			return m, m.saveRequest()
+		case "alt+i": // Import a Postman collection or environment
+			return m, m.openPrompt("import-postman", "")
+		case "alt+c": // Populate the editor from a curl command
+			return m, m.openPrompt("import-curl", "")
+		case "alt+v": // Show the resolved environment instead of the lists
+			m.inspecting = !m.inspecting
+			if m.inspecting {
+				m.browsingSchema = false
+				m.renderInspector()
+				m.FocusedPane = 0
+				m.focus()
+			}
+			return m, nil
+		case "alt+n": // Pick the active environment
+			if len(m.Environments) == 0 {
+				m.Notice = styles.HelpStyle.Render("No environments in config.lua")
+				return m, nil
+			}
+			m.pickingEnv, m.envCursor = true, max(m.ActiveEnv, 0)
+			return m, nil
+		case "alt+u": // Unlock the secrets file
+			return m, m.openPrompt("unlock-secrets", "")
+		case "alt+x": // Close the WebSocket connection
+			if m.ws != nil {
+				return m, m.ws.close()
+			}
+			return m, nil
+		case "alt+m": // Load the next saved WebSocket message
+			if m.isWS() {
+				m.cycleMessage()
+			}
+			return m, nil
+		case "alt+g": // Browse the GraphQL schema instead of the lists
+			if m.browsingSchema {
+				m.browsingSchema = false
+				return m, nil
+			}
+			return m, m.openSchemaBrowser()
+		case "ctrl+@": // Complete a GraphQL field (Ctrl+Space)
+			if m.isGraphQL() && m.FocusedPane == 1 && m.FocusedInput == bodyInput {
+				return m, m.completeField()
+			}
+			return m, nil
+		case "alt+b": // Cycle the body mode
+			m.setBodyMode((m.BodyMode + 1) % len(BodyModes))
+			return m, nil
+		case "alt+r": // Run the visible collection with its assertions
+			return m, m.startRun()
+		case "alt+e": // Export the request as code
+			m.commitTables()
+			m.exporting = true
+			m.renderSnippet()
+			return m, nil
 		}
 
 		// Delegate to focused pane
 		switch m.FocusedPane {
 		case 0: // List Pane
-			m.Collections, cmd = m.Collections.Update(msg)
-			cmds = append(cmds, cmd)
-			if key.Matches(msg, key.NewBinding(key.WithKeys("enter"))) {
-				if item, ok := m.Collections.SelectedItem().(RequestItem); ok {
-					m.loadRequest(item)
-				}
-			}
+			cmds = append(cmds, m.updateListPane(msg))
 		case 1: // Request Pane
 			cmds = append(cmds, m.updateRequestInputs(msg))
 		case 2: // Response Pane
@@ -148,42 +388,227 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			case "h", "left":
 				m.ResponseViewTab--
 				if m.ResponseViewTab < 0 {
-					m.ResponseViewTab = 2
+					m.ResponseViewTab = len(responseViews) - 1
 				}
 				m.updateResponseView()
 			case "l", "right":
-				m.ResponseViewTab = (m.ResponseViewTab + 1) % 3
+				m.ResponseViewTab = (m.ResponseViewTab + 1) % len(responseViews)
 				m.updateResponseView()
 			default:
+				if m.showsTree() {
+					cmds = append(cmds, m.updateJSONTree(msg))
+					break
+				}
 				m.Response, cmd = m.Response.Update(msg)
 				cmds = append(cmds, cmd)
 			}
 		}
 
 	case HTTPResponseMsg:
-		m.Sending = false
+		m.Sending, m.cancel = false, nil
+		if s := m.stream; s != nil && m.streamKind != "" {
+			m.streamStopped = s.stopped
+			if s.err != nil {
+				m.streamErr = "stream broke off: " + s.err.Error()
+			}
+		}
+		cmds = append(cmds, m.AddHistory(newHistoryEntry(msg.Request, msg)))
 		if msg.Err != nil {
 			m.LastError = msg.Err.Error()
+			if errors.Is(msg.Err, ErrCancelled) || errors.Is(msg.Err, ErrTimeout) {
+				m.LastError = "Request " + m.LastError
+			}
 			m.ResponseCode = 0
 		} else {
 			m.ResponseBody = msg.Body
 			m.ResponseHeaders = msg.Headers
 			m.ResponseCode = msg.Code
+			m.ResponseStatus = msg.Status
+			m.ResponseProto = msg.Proto
+			m.ResponseTiming = msg.Timing
+			m.ResponseRedirects = msg.Redirects
+			m.TestResults = msg.Results
+			m.GraphQLErrors = nil
+			if msg.Request.Method == MethodGraphQL {
+				m.GraphQLErrors = graphQLErrors(msg.Body)
+			}
+			m.updateResponseView()
+			m.capture(msg.Captured)
+		}
+
+	case StreamMsg:
+		if msg.Stream != m.stream {
+			break
+		}
+		if msg.Head != nil {
+			m.streamKind = msg.Kind
+			m.ResponseHeaders = FormatHeaders(msg.Head.Proto, msg.Head.Status, msg.Head.Header)
+			m.ResponseCode, m.ResponseStatus, m.ResponseProto = msg.Head.StatusCode, msg.Head.Status, msg.Head.Proto
+			m.ResponseTiming, m.ResponseRedirects = msg.Head.Timing, msg.Head.Redirects
+		}
+		m.ResponseBody += msg.Raw
+		m.logStream(msg.Events...)
+		if msg.Head != nil {
+			m.updateResponseView()
+		} else {
+			m.refreshStream(false)
+		}
+		cmds = append(cmds, msg.Stream.wait())
+
+	case GraphQLSchemaMsg:
+		if msg.Schema != nil {
+			if m.schemas == nil {
+				m.schemas = make(map[string]*gqlSchema)
+			}
+			m.schemas[msg.URL] = msg.Schema
+		}
+		switch {
+		case msg.Err != nil:
+			m.Notice = styles.ErrorStyle.Render("GraphQL: " + msg.Err.Error())
+		case msg.Cached:
+			m.Notice = styles.SuccessStyle.Render(fmt.Sprintf("Loaded the cached schema of %s (%d types, R in the browser refetches it)", secrets.Mask(msg.URL), len(msg.Schema.Types)))
+		default:
+			m.Notice = styles.SuccessStyle.Render(fmt.Sprintf("Fetched the schema of %s (%d types)", secrets.Mask(msg.URL), len(msg.Schema.Types)))
+		}
+
+	case WSConnectedMsg:
+		m.Sending, m.cancel = false, nil
+		cmds = append(cmds, m.AddHistory(newHistoryEntry(msg.Request, HTTPResponseMsg{Code: msg.Code, Status: msg.Status, Headers: msg.Headers, Timing: msg.Timing, Err: msg.Err})))
+		if msg.Err != nil {
+			m.logWS(wsFrame{Time: time.Now(), Dir: "info", Text: msg.Err.Error(), Err: true})
+			break
+		}
+		m.ws, m.ResponseHeaders = msg.Conn, msg.Headers
+		m.logWS(wsFrame{Time: time.Now(), Dir: "info", Text: fmt.Sprintf("connected (%s, %s)", msg.Status, msg.Timing.Total.Round(time.Millisecond))})
+		cmds = append(cmds, msg.Conn.wait())
+
+	case WSFrameMsg:
+		if msg.Conn != m.ws {
+			break
+		}
+		m.logWS(msg.Frames...)
+		if msg.read {
+			cmds = append(cmds, msg.Conn.wait())
+		}
+
+	case WSClosedMsg:
+		if msg.Conn != m.ws {
+			break
+		}
+		m.ws = nil
+		if msg.Err != nil {
+			m.logWS(wsFrame{Time: time.Now(), Dir: "info", Text: "closed: " + msg.Err.Error(), Err: true})
+		} else {
+			m.logWS(wsFrame{Time: time.Now(), Dir: "info", Text: "closed"})
+		}
+
+	case RunStepMsg:
+		if m.run == nil || m.run.done() {
+			break
+		}
+		m.run.Cases = append(m.run.Cases, msg.Case)
+		for k, v := range msg.Case.Captured {
+			m.run.Env[k] = v
+		}
+		m.capture(msg.Case.Captured)
+		m.run.Stopped = m.run.ctx.Err() != nil
+		if m.run.done() {
+			m.run.End = time.Now()
+			m.run.cancel(nil)
+			passed, failed := SummarizeCases(m.run.Cases)
+			style, outcome := styles.SuccessStyle, "finished"
+			if failed > 0 {
+				style = styles.ErrorStyle
+			}
+			if m.run.Stopped {
+				style, outcome = styles.ErrorStyle, "cancelled"
+			}
+			m.Notice = style.Render(fmt.Sprintf("Run %s: %d passed, %d failed", outcome, passed, failed))
+		} else {
+			cmds = append(cmds, m.run.runStep())
+		}
+		if m.ResponseViewTab == testsView {
 			m.updateResponseView()
-			// Add to history
-			historyItem := RequestItem{
-				Name:    m.URL.Value(),
-				Method:  m.Methods[m.SelectedMethod],
-				URL:     m.URL.Value(),
-				Headers: m.Headers.Value(),
-				Body:    m.Body.Value(),
+		}
+
+	case SecretsUnlockedMsg:
+		if msg.Err != nil {
+			m.Notice = styles.ErrorStyle.Render("Secrets: " + msg.Err.Error())
+			break
+		}
+		m.vault = msg.Vault
+		m.refreshEnvironment()
+		m.Notice = styles.SuccessStyle.Render(fmt.Sprintf("Unlocked %d secrets", len(m.vault.Names())))
+
+	case HistoryLoadedMsg:
+		if msg.Err != nil {
+			log.Printf("http history: %v", msg.Err)
+			break
+		}
+		m.HistoryEntries = msg.Entries
+		m.filterHistory()
+
+	case EnvironmentsLoadedMsg:
+		if msg.Err != nil {
+			log.Printf("http environments: %v", msg.Err)
+			break
+		}
+		m.setImportedEnvironments(msg.Environments)
+
+	case HTTPFilesLoadedMsg:
+		if msg.Err != nil {
+			log.Printf("loading .http files: %v", msg.Err)
+			break
+		}
+		m.FileRequests = nil
+		m.fileVars = make(map[string]string)
+		for _, f := range msg.Files {
+			for _, r := range f.Requests {
+				m.FileRequests = append(m.FileRequests, r)
+			}
+			for _, v := range f.Variables {
+				m.fileVars[v.Name] = v.Value
 			}
-			newHistory := append([]list.Item{historyItem}, m.History.Items()...)
-			if len(newHistory) > 20 { // Limit history
-				newHistory = newHistory[:20]
+		}
+		m.refreshCollections()
+		m.refreshEnvironment()
+
+	case OpenAPILoadedMsg:
+		if msg.Path != m.openAPIPath {
+			break // the spec was replaced by the one set in config.lua
+		}
+		if msg.Err != nil {
+			log.Printf("openapi: %v", msg.Err)
+			m.Notice = styles.ErrorStyle.Render("OpenAPI: " + msg.Err.Error())
+			if !msg.ModTime.IsZero() { // keep watching for a fixed version
+				m.openAPIModTime = msg.ModTime
+				cmds = append(cmds, watchOpenAPI(m.openAPIPath, m.openAPIModTime))
 			}
-			m.History.SetItems(newHistory)
+			break
 		}
+		if !m.openAPIModTime.IsZero() {
+			m.Notice = styles.SuccessStyle.Render("Regenerated requests from " + msg.Path)
+		}
+		m.openAPIModTime = msg.ModTime
+		m.SpecRequests = nil
+		for _, r := range msg.File.Requests {
+			m.SpecRequests = append(m.SpecRequests, r)
+		}
+		m.specVars = make(map[string]string)
+		for _, v := range msg.File.Variables {
+			m.specVars[v.Name] = v.Value
+		}
+		m.refreshCollections()
+		m.refreshEnvironment()
+		cmds = append(cmds, watchOpenAPI(m.openAPIPath, m.openAPIModTime))
+
+	case HTTPFileSavedMsg:
+		if msg.Err != nil {
+			m.Notice = styles.ErrorStyle.Render("Save failed: " + msg.Err.Error())
+			break
+		}
+		m.Notice = styles.SuccessStyle.Render("Saved to " + msg.Path)
+		cmds = append(cmds, loadHTTPFiles())
 
 	case spinner.TickMsg:
 		if m.Sending {
@@ -194,28 +619,84 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// Editing reports whether keys are typed into a text input or prompt, so
+// they must not be taken as global shortcuts.
+func (m Model) Editing() bool {
+	switch {
+	case m.promptAction != "", m.searching, m.Collections.FilterState() == list.Filtering:
+		return true
+	case m.FocusedPane != 1 || m.pickingEnv || m.exporting:
+		return false
+	}
+	switch m.FocusedInput {
+	case paramsInput:
+		return m.Params.editing
+	case headersInput:
+		return m.Headers.editing
+	}
+	return m.FocusedInput != 0 // the Method selector
+}
+
 // View renders the HTTP model.
 func (m Model) View() string {
-	listPane := lipgloss.JoinVertical(lipgloss.Left, m.Collections.View(), m.History.View())
+	historyPane := m.History.View()
+	if m.searching || m.HistorySearch.Value() != "" {
+		historyPane = lipgloss.JoinVertical(lipgloss.Left, m.HistorySearch.View(), historyPane)
+	}
+	listPane := lipgloss.JoinVertical(lipgloss.Left, m.Collections.View(), historyPane)
+	if m.inspecting {
+		listPane = lipgloss.JoinVertical(lipgloss.Left, styles.ListHeaderStyle.Render("Environment"), m.Inspector.View())
+	} else if m.browsingSchema {
+		listPane = m.renderSchemaBrowser()
+	}
 
 	var requestBuilder strings.Builder
 	requestBuilder.WriteString(m.renderMethodSelector())
 	requestBuilder.WriteString(m.renderInput("URL", m.URL, 1))
-	requestBuilder.WriteString(m.renderTextarea("Headers", m.Headers, 2))
-	requestBuilder.WriteString(m.renderTextarea("Body", m.Body, 3))
+	requestBuilder.WriteString(m.Params.view(m.FocusedPane == 1 && m.FocusedInput == paramsInput, m.resolveValue))
+	requestBuilder.WriteString(m.renderInput("Auth", m.Auth, 3))
+	requestBuilder.WriteString(m.Headers.view(m.FocusedPane == 1 && m.FocusedInput == headersInput, m.resolveValue))
+	requestBuilder.WriteString(m.renderTextarea(m.renderBodyTitle(), m.Body, bodyInput))
+	if m.isGraphQL() {
+		requestBuilder.WriteString(m.renderTextarea("Variables", m.Variables, variablesInput))
+	}
 	requestPane := requestBuilder.String()
+	if m.exporting {
+		requestPane = m.renderExport()
+	}
 
 	var responseBuilder strings.Builder
 	statusStyle := styles.SuccessStyle
-	if m.ResponseCode >= 400 {
+	if m.ResponseCode >= 400 || m.LastError != "" {
 		statusStyle = styles.ErrorStyle
 	}
 	status := statusStyle.Render(fmt.Sprintf("%d", m.ResponseCode))
-	responseHeader := styles.ListHeaderStyle.Render(fmt.Sprintf("Response - Status: %s", status))
+	if m.ResponseStatus != "" {
+		status = statusStyle.Render(m.ResponseStatus)
+	}
+	summary := ""
+	if m.ResponseProto != "" {
+		summary = fmt.Sprintf(" · %s · %s · %s", m.ResponseProto, m.ResponseTiming.Total.Round(time.Millisecond), utils.FormatBytes(uint64(len(m.ResponseBody))))
+	}
+	if n := len(m.TestResults); n > 0 {
+		passed := countPassed(m.TestResults)
+		style := styles.SuccessStyle
+		if passed < n {
+			style = styles.ErrorStyle
+		}
+		summary += " · " + style.Render(fmt.Sprintf("tests %d/%d", passed, n))
+	}
+	summary += m.renderGraphQLErrors()
+	if m.streamKind != "" {
+		summary += m.streamSummary()
+	}
+	responseHeader := styles.ListHeaderStyle.Render(fmt.Sprintf("Response - Status: %s%s", status, summary))
+	if badge := m.environmentBadge(); badge != "" {
+		responseHeader = lipgloss.JoinHorizontal(lipgloss.Top, responseHeader, badge)
+	}
 
-	tabs := []string{"Pretty", "Raw", "Headers"}
 	var renderedTabs []string
-	for i, t := range tabs {
+	for i, t := range responseViews {
 		style := styles.InactiveTabStyle
 		if i == m.ResponseViewTab {
 			style = styles.ActiveTabStyle
@@ -225,14 +706,19 @@ func (m Model) View() string {
 	responseTabs := lipgloss.JoinHorizontal(lipgloss.Top, renderedTabs...)
 
 	responseBuilder.WriteString(responseHeader + "\n" + responseTabs + "\n")
-	if m.Sending {
-		responseBuilder.WriteString(fmt.Sprintf("\n%s Sending request...", m.Spinner.View()))
+	if m.Sending && m.streamKind == "" {
+		responseBuilder.WriteString(fmt.Sprintf("\n%s Sending request... %s", m.Spinner.View(), styles.HelpStyle.Render("(Esc to cancel)")))
 	} else if m.LastError != "" {
 		responseBuilder.WriteString(styles.ErrorStyle.Render(m.LastError))
+	} else if m.showsTree() {
+		responseBuilder.WriteString(m.renderJSONTree())
 	} else {
 		responseBuilder.WriteString(m.Response.View())
 	}
 	responsePane := responseBuilder.String()
+	if m.isWS() {
+		responsePane = m.renderWSPane()
+	}
 
 	listStyle, reqStyle, respStyle := styles.BlurredPaneStyle, styles.BlurredPaneStyle, styles.BlurredPaneStyle
 	switch m.FocusedPane {
@@ -244,7 +730,42 @@ func (m Model) View() string {
 		respStyle = styles.FocusedPaneStyle
 	}
 
-	help := styles.HelpStyle.Render("Focus: Ctrl+L | Send: Ctrl+S | Navigate: Tab/Arrows | Resp View: H/L")
+	help := styles.HelpStyle.Render("Focus: Ctrl+L | Send: Ctrl+S | Run: Alt+R | Env: Alt+N | Save: Alt+S | Navigate: Tab/Arrows | Resp View: H/L")
+	if m.isWS() {
+		help = styles.HelpStyle.Render("Focus: Ctrl+L | Connect/Send Body: Ctrl+S | Close: Alt+X | Next saved message: Alt+M | Save: Alt+S")
+	} else if m.isGraphQL() {
+		help = styles.HelpStyle.Render("Focus: Ctrl+L | Send: Ctrl+S | Complete field: Ctrl+Space | Schema: Alt+G | Save: Alt+S | Resp View: H/L")
+	}
+	if m.FocusedPane == 0 && m.ListFocus == 1 {
+		help = styles.HelpStyle.Render("Collections: Ctrl+R | Search: / | Replay: Enter | Open response: O")
+	} else if m.FocusedPane == 0 {
+		help = styles.HelpStyle.Render("History: Ctrl+R | Load: Enter | Focus: Ctrl+L | Send: Ctrl+S")
+	} else if m.FocusedPane == 2 && m.showsTree() {
+		help = styles.HelpStyle.Render("Expand/Collapse: Enter/Space | Collapse all: C | Expand all: E | Move: Up/Down | Resp View: H/L")
+	}
+
+	if m.exporting {
+		help = styles.HelpStyle.Render("Language: H/L | Copy: Y | Scroll: Up/Down | Close: Esc")
+	} else if m.inspecting && m.FocusedPane == 0 {
+		help = styles.HelpStyle.Render("Scroll: Up/Down | Clear captured: X | Unlock secrets: Alt+U | Close: Esc/Alt+V")
+	} else if m.browsingSchema && m.FocusedPane == 0 {
+		help = styles.HelpStyle.Render("Expand: Enter/Space | Parent: H | Insert field: I | Refetch: R | Close: Esc/Alt+G")
+	}
+
+	if m.Sending && m.streamKind != "" {
+		help = styles.HelpStyle.Render("Stop stream: Esc/Ctrl+C | Focus: Ctrl+L | Resp View: H/L | Scroll: Up/Down")
+	} else if m.Sending {
+		help = styles.HelpStyle.Render("Cancel: Esc/Ctrl+C")
+	}
+	if m.Notice != "" {
+		help = m.Notice + "  " + help
+	}
+	if m.pickingEnv {
+		help = m.renderEnvPicker()
+	}
+	if m.promptAction != "" {
+		help = styles.FocusedInputStyle.Render(promptTitles[m.promptAction]) + " " + m.Prompt.View() + styles.HelpStyle.Render("  enter:confirm  esc:cancel")
+	}
 
 	return lipgloss.JoinVertical(lipgloss.Left,
 		lipgloss.JoinHorizontal(lipgloss.Top,
@@ -265,30 +786,367 @@ func (m *Model) SetSize(w, h int) {
 
 	m.Collections.SetSize(listWidth, h/2-2)
 	m.History.SetSize(listWidth, h/2-2)
+	m.HistorySearch.Width = listWidth - 2
+	m.Prompt.Width = w / 2
 
 	m.URL.Width = reqWidth - 4
-	m.Headers.SetWidth(reqWidth - 4)
+	m.Auth.Width = reqWidth - 4
+	m.Params.setWidth(reqWidth - 4)
+	m.Headers.setWidth(reqWidth - 4)
 	m.Body.SetWidth(reqWidth - 4)
+	m.Variables.SetWidth(reqWidth - 4)
 
 	m.Response.Width = respWidth
 	m.Response.Height = h - 6
+	m.Snippet.Width = reqWidth - 4
+	m.Snippet.Height = h - 6
+	m.Inspector.Width = listWidth
+	m.Inspector.Height = h - 5
+	if m.inspecting {
+		m.renderInspector()
+	}
+}
+
+// SetTemplates sets the request templates from config.lua.
+func (m *Model) SetTemplates(items []list.Item) {
+	m.Templates = items
+	m.refreshCollections()
+}
+
+// SetOpenAPI sets the OpenAPI spec to generate requests from, replacing the
+// one found in the project. It returns the command watching the file.
+func (m *Model) SetOpenAPI(path string) tea.Cmd {
+	if path == "" || path == m.openAPIPath {
+		return nil
+	}
+	m.openAPIPath, m.openAPIModTime = path, time.Time{}
+	m.SpecRequests, m.specVars = nil, nil
+	m.refreshCollections()
+	m.refreshEnvironment()
+	return watchOpenAPI(path, time.Time{})
+}
+
+func (m *Model) refreshCollections() {
+	items := append([]list.Item{}, m.Templates...)
+	items = append(items, m.FileRequests...)
+	m.Collections.SetItems(append(items, m.SpecRequests...))
+}
+
+// HTTPFileSavedMsg is sent once a request has been written to a .http file.
+type HTTPFileSavedMsg struct {
+	Path string
+	Err  error
+}
+
+// saveRequest writes the editor's request back to the .http file it was
+// loaded from, or appends it to DefaultHTTPFile.
+func (m Model) saveRequest() tea.Cmd {
+	item := m.currentRequest()
+	item.Name = item.Method + " " + item.URL
+	item.Group, item.Captures, item.Messages = m.Loaded.Group, m.Loaded.Captures, m.Loaded.Messages
+	item.Timeout, item.ConnectTimeout, item.Stream = m.Loaded.Timeout, m.Loaded.ConnectTimeout, m.Loaded.Stream
+	if m.Loaded.Source != "" {
+		item.Name, item.Source, item.SourceName = m.Loaded.Name, m.Loaded.Source, m.Loaded.SourceName
+	} else if m.Loaded.Name != "" {
+		item.Name = m.Loaded.Name
+	}
+	return func() tea.Msg {
+		path, err := SaveHTTPRequest(item)
+		return HTTPFileSavedMsg{Path: path, Err: err}
+	}
+}
+
+// promptTitles labels the prompts opened by openPrompt.
+var promptTitles = map[string]string{
+	"import-postman": "Import Postman file:",
+	"import-curl":    "Paste curl command:",
+	"unlock-secrets": "Secrets passphrase:",
+}
+
+func (m *Model) openPrompt(action, value string) tea.Cmd {
+	m.promptAction = action
+	m.Prompt.EchoMode = textinput.EchoNormal
+	if action == "unlock-secrets" {
+		m.Prompt.EchoMode = textinput.EchoPassword
+	}
+	m.Prompt.SetValue(value)
+	m.Prompt.CursorEnd()
+	return m.Prompt.Focus()
+}
+
+func (m *Model) updatePrompt(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.promptAction = ""
+		m.Prompt.Blur()
+		return nil
+	case tea.KeyEnter:
+		action, value := m.promptAction, strings.TrimSpace(m.Prompt.Value())
+		m.promptAction = ""
+		m.Prompt.Blur()
+		if value == "" {
+			return nil
+		}
+		switch action {
+		case "import-postman":
+			// Run here rather than in a command, as it may add to m.vault.
+			written, err := ImportPostmanFile(value, "", false, func() (*secrets.Vault, error) {
+				if m.vault == nil {
+					return nil, errors.New("unlock the secrets file first (Alt+U)")
+				}
+				return m.vault, nil
+			})
+			if err != nil {
+				m.Notice = styles.ErrorStyle.Render("Import failed: " + err.Error())
+				return nil
+			}
+			m.Notice = styles.SuccessStyle.Render("Imported " + written)
+			if m.envStore == nil {
+				return loadHTTPFiles()
+			}
+			return tea.Batch(loadHTTPFiles(), loadEnvironments(m.envStore))
+		case "unlock-secrets":
+			m.Prompt.SetValue("")
+			return unlockSecrets(m.vaultPath, value)
+		case "import-curl":
+			item, err := ParseCurl(value)
+			if err != nil {
+				m.Notice = styles.ErrorStyle.Render("curl: " + err.Error())
+				return nil
+			}
+			m.loadRequest(item)
+			m.Notice = styles.SuccessStyle.Render("Loaded " + item.Name)
+			m.FocusedPane, m.FocusedInput = 1, 1
+			m.focus()
+		}
+		return nil
+	}
+	var cmd tea.Cmd
+	m.Prompt, cmd = m.Prompt.Update(msg)
+	return cmd
+}
+
+// updateExport handles keys while the export view replaces the editor.
+func (m *Model) updateExport(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc", "alt+e":
+		m.exporting = false
+	case "h", "left":
+		m.SnippetLang = (m.SnippetLang + len(SnippetLanguages) - 1) % len(SnippetLanguages)
+		m.renderSnippet()
+	case "l", "right", "tab":
+		m.SnippetLang = (m.SnippetLang + 1) % len(SnippetLanguages)
+		m.renderSnippet()
+	case "y", "c":
+		lang, text := SnippetLanguages[m.SnippetLang], m.snippetText
+		if text == "" {
+			return nil
+		}
+		if err := utils.CopyToClipboard(text); err != nil {
+			m.Notice = styles.ErrorStyle.Render("Copy failed: " + err.Error())
+			return nil
+		}
+		m.Notice = styles.SuccessStyle.Render("Copied " + lang + " snippet")
+	default:
+		var cmd tea.Cmd
+		m.Snippet, cmd = m.Snippet.Update(msg)
+		return cmd
+	}
+	return nil
+}
+
+// renderSnippet regenerates the snippet for the request in the editor,
+// with environment variables substituted.
+func (m *Model) renderSnippet() {
+	req := m.currentRequest()
+	req.URL = m.substituteEnv(req.URL)
+	req.Auth = m.substituteEnv(req.Auth)
+	req.Headers = m.substituteEnv(req.Headers)
+	req.Body = m.substituteEnv(req.Body)
+	req.Variables = m.substituteEnv(req.Variables)
+	text, err := Snippet(SnippetLanguages[m.SnippetLang], req)
+	text = secrets.Mask(text)
+	m.snippetText = text
+	if err != nil {
+		m.Snippet.SetContent(styles.ErrorStyle.Render(err.Error()))
+	} else {
+		m.Snippet.SetContent(text)
+	}
+	m.Snippet.GotoTop()
+}
+
+func (m Model) renderExport() string {
+	var tabs []string
+	for i, lang := range SnippetLanguages {
+		style := styles.InactiveTabStyle
+		if i == m.SnippetLang {
+			style = styles.ActiveTabStyle
+		}
+		tabs = append(tabs, style.Render(lang))
+	}
+	return fmt.Sprintf("%s\n%s\n%s", styles.FocusedInputStyle.Render("Export"), lipgloss.JoinHorizontal(lipgloss.Top, tabs...), m.Snippet.View())
+}
+
+// send starts sending the request currently in the editor.
+func (m *Model) send() tea.Cmd {
+	if m.Sending {
+		return nil
+	}
+	if m.isWS() {
+		return m.sendWS()
+	}
+	m.commitTables()
+	m.Sending = true
+	m.LastError = ""
+	m.ResponseBody = ""
+	m.ResponseHeaders = ""
+	m.ResponseCode, m.ResponseStatus, m.ResponseProto = 0, "", ""
+	m.ResponseTiming, m.ResponseRedirects = Timing{}, nil
+	m.TestResults = nil
+	m.GraphQLErrors = nil
+	if m.run != nil {
+		m.run.cancel(ErrCancelled)
+		m.run = nil
+	}
+	m.stream = newResponseStream(m.Loaded.Stream)
+	m.streamKind, m.streamEvents, m.streamCount = "", nil, 0
+	m.streamStopped, m.streamErr = false, ""
+	ctx, cancel := context.WithCancelCause(context.Background())
+	m.cancel = cancel
+	return tea.Batch(m.Spinner.Tick, m.sendRequest(ctx, m.stream))
+}
+
+func (m *Model) updateListPane(msg tea.KeyMsg) tea.Cmd {
+	var cmd tea.Cmd
+	if m.searching {
+		switch msg.String() {
+		case "enter":
+			m.searching = false
+			m.HistorySearch.Blur()
+		case "esc":
+			m.searching = false
+			m.HistorySearch.Blur()
+			m.HistorySearch.SetValue("")
+			m.filterHistory()
+		default:
+			m.HistorySearch, cmd = m.HistorySearch.Update(msg)
+			m.filterHistory()
+		}
+		return cmd
+	}
+
+	if msg.String() == "ctrl+r" { // Switch between collections and history
+		m.ListFocus = (m.ListFocus + 1) % 2
+		return nil
+	}
+
+	if m.ListFocus == 0 {
+		m.Collections, cmd = m.Collections.Update(msg)
+		if key.Matches(msg, key.NewBinding(key.WithKeys("enter"))) {
+			if item, ok := m.Collections.SelectedItem().(RequestItem); ok {
+				m.loadRequest(item)
+			}
+		}
+		return cmd
+	}
+
+	item, selected := m.History.SelectedItem().(historyItem)
+	if selected && item.Method == MethodGRPC && (msg.String() == "enter" || msg.String() == "o") {
+		return func() tea.Msg { return OpenGRPCMsg{Entry: item.HistoryEntry} }
+	}
+	switch msg.String() {
+	case "/":
+		m.searching = true
+		return m.HistorySearch.Focus()
+	case "enter": // Replay
+		if selected {
+			m.loadRequest(item.Request())
+			return m.send()
+		}
+	case "o": // Reopen the stored response
+		if selected && !m.Sending {
+			m.openHistoryEntry(item.HistoryEntry)
+		}
+	default:
+		m.History, cmd = m.History.Update(msg)
+	}
+	return cmd
+}
+
+// filterHistory refreshes the History list from HistoryEntries and the search query.
+func (m *Model) filterHistory() {
+	filter := ParseHistoryFilter(m.HistorySearch.Value())
+	var items []list.Item
+	for _, e := range m.HistoryEntries {
+		if filter.Match(e) {
+			items = append(items, historyItem{e})
+		}
+	}
+	m.History.SetItems(items)
+}
+
+// openHistoryEntry shows a stored response without sending the request again.
+func (m *Model) openHistoryEntry(e HistoryEntry) {
+	m.loadRequest(e.Request())
+	m.LastError = e.Error
+	m.ResponseCode = e.Status
+	m.ResponseStatus = e.StatusText
+	m.ResponseProto = e.Proto
+	m.ResponseRedirects = nil
+	m.ResponseTiming = Timing{Total: time.Duration(e.DurationMS) * time.Millisecond}
+	if e.Timing != nil {
+		m.ResponseTiming = *e.Timing
+	}
+	m.ResponseHeaders, m.ResponseBody = "", ""
+	m.streamKind = ""
+	if e.Response != nil {
+		m.ResponseHeaders, m.ResponseBody = e.Response.Headers, e.Response.Body
+	}
+	m.GraphQLErrors = nil
+	if e.Method == MethodGraphQL {
+		m.GraphQLErrors = graphQLErrors(m.ResponseBody)
+	}
+	m.updateResponseView()
+	m.FocusedPane = 2
+	m.focus()
 }
 
 func (m *Model) updateRequestInputs(msg tea.Msg) tea.Cmd {
 	var cmds []tea.Cmd
 	var cmd tea.Cmd
 
+	// The tables take the keys they use, up and down included, except at their edges.
+	if km, ok := msg.(tea.KeyMsg); ok && (m.FocusedInput == paramsInput || m.FocusedInput == headersInput) {
+		table := &m.Params
+		if m.FocusedInput == headersInput {
+			table = &m.Headers
+		}
+		changed, handled, cmd := table.update(km)
+		if changed && m.FocusedInput == paramsInput {
+			m.URL.SetValue(withQuery(m.URL.Value(), m.Params.Rows))
+		}
+		if handled {
+			return cmd
+		}
+	}
+
 	// Handle up/down focus change
 	if km, ok := msg.(tea.KeyMsg); ok && key.Matches(km, key.NewBinding(key.WithKeys("up", "shift+tab"))) {
 		m.FocusedInput--
 		if m.FocusedInput < 0 {
-			m.FocusedInput = 3
+			m.FocusedInput = numInputs - 1
+		}
+		if m.FocusedInput == variablesInput && !m.isGraphQL() {
+			m.FocusedInput--
 		}
 		m.focus()
 		return nil
 	}
 	if km, ok := msg.(tea.KeyMsg); ok && key.Matches(km, key.NewBinding(key.WithKeys("down", "tab"))) {
-		m.FocusedInput = (m.FocusedInput + 1) % 4
+		m.FocusedInput = (m.FocusedInput + 1) % numInputs
+		if m.FocusedInput == variablesInput && !m.isGraphQL() {
+			m.FocusedInput = 0
+		}
 		m.focus()
 		return nil
 	}
@@ -306,35 +1164,63 @@ func (m *Model) updateRequestInputs(msg tea.Msg) tea.Cmd {
 					m.SelectedMethod = len(m.Methods) - 1
 				}
 			}
+			m.updateResponseView() // WS shows its message log instead
 		}
 	case 1:
+		before := m.URL.Value()
 		m.URL, cmd = m.URL.Update(msg)
 		cmds = append(cmds, cmd)
-	case 2:
-		m.Headers, cmd = m.Headers.Update(msg)
-		cmds = append(cmds, cmd)
+		if m.URL.Value() != before {
+			m.Params.setRows(mergeParams(queryRows(m.URL.Value()), m.Params.Rows))
+		}
 	case 3:
+		m.Auth, cmd = m.Auth.Update(msg)
+		cmds = append(cmds, cmd)
+	case bodyInput:
 		m.Body, cmd = m.Body.Update(msg)
 		cmds = append(cmds, cmd)
+	case variablesInput:
+		m.Variables, cmd = m.Variables.Update(msg)
+		cmds = append(cmds, cmd)
 	}
 	return tea.Batch(cmds...)
 }
 
 func (m *Model) focus() {
 	m.URL.Blur()
-	m.Headers.Blur()
+	m.Auth.Blur()
 	m.Body.Blur()
+	m.Variables.Blur()
+	m.commitTables()
 
 	switch m.FocusedInput {
 	case 0:
 		// No text input to focus, the view will highlight it based on state.
 	case 1:
 		m.URL.Focus()
-	case 2:
-		m.Headers.Focus()
 	case 3:
+		m.Auth.Focus()
+	case bodyInput:
 		m.Body.Focus()
+	case variablesInput:
+		m.Variables.Focus()
+	}
+}
+
+// commitTables stores the cells being edited in the tables, so that the
+// request reflects them.
+func (m *Model) commitTables() {
+	if m.Params.stopEditing() {
+		m.URL.SetValue(withQuery(m.URL.Value(), m.Params.Rows))
 	}
+	m.Headers.stopEditing()
+}
+
+// resolveValue substitutes the environment into a table value, masking
+// secrets. unknown reports references left unresolved.
+func (m Model) resolveValue(s string) (resolved string, unknown bool) {
+	resolved = m.substituteEnv(s)
+	return secrets.Mask(resolved), envRefName.MatchString(resolved)
 }
 
 func (m *Model) renderInput(title string, input textinput.Model, index int) string {
@@ -350,9 +1236,45 @@ func (m *Model) renderTextarea(title string, ta textarea.Model, index int) strin
 	if m.FocusedPane == 1 && m.FocusedInput == index {
 		style = styles.FocusedInputStyle
 	}
+	if index == bodyInput { // the Body title is already styled
+		return fmt.Sprintf("%s\n%s\n", title, ta.View())
+	}
 	return fmt.Sprintf("%s\n%s\n", style.Render(title), ta.View())
 }
 
+// setBodyMode switches the body mode, updating the editor's placeholder.
+func (m *Model) setBodyMode(i int) {
+	m.BodyMode = i
+	m.Body.Placeholder = bodyPlaceholders[BodyModes[i]]
+}
+
+// renderBodyTitle labels the Body editor with the body modes, the active
+// one highlighted, or with the saved messages of a WS request.
+func (m *Model) renderBodyTitle() string {
+	style := styles.BlurredInputStyle
+	if m.FocusedPane == 1 && m.FocusedInput == bodyInput {
+		style = styles.FocusedInputStyle
+	}
+	if m.isWS() {
+		saved := ""
+		if n := len(m.Loaded.Messages); n > 0 {
+			saved = fmt.Sprintf(" %d/%d saved (Alt+M)", m.wsMessage+1, n)
+		}
+		return style.Render("Message") + styles.HelpStyle.Render(saved)
+	}
+	if m.isGraphQL() {
+		return style.Render("Query") + styles.HelpStyle.Render(" (Ctrl+Space completes fields, Alt+G browses the schema)")
+	}
+	modes := make([]string, len(BodyModes))
+	for i, mode := range BodyModes {
+		modes[i] = styles.HelpStyle.Render(mode)
+		if i == m.BodyMode {
+			modes[i] = style.Render("[" + mode + "]")
+		}
+	}
+	return style.Render("Body") + " " + strings.Join(modes, " ") + styles.HelpStyle.Render(" (Alt+B)")
+}
+
 func (m *Model) renderMethodSelector() string {
 	var renderedMethods []string
 	for i, method := range m.Methods {
@@ -373,9 +1295,19 @@ func (m *Model) renderMethodSelector() string {
 }
 
 func (m *Model) loadRequest(item RequestItem) {
+	m.Loaded = item
+	m.Notice = ""
 	m.URL.SetValue(item.URL)
-	m.Headers.SetValue(item.Headers)
+	m.Params.setRows(queryRows(item.URL))
+	m.Auth.SetValue(item.Auth)
+	m.Headers.setRows(headerRows(item.Headers))
 	m.Body.SetValue(item.Body)
+	m.Variables.SetValue(item.Variables)
+	m.setBodyMode(bodyModeIndex(item.BodyMode))
+	m.wsMessage = 0
+	if item.Body == "" && len(item.Messages) > 0 {
+		m.Body.SetValue(item.Messages[0])
+	}
 
 	// Find the index of the method and set it
 	m.SelectedMethod = 0 // default to GET
@@ -385,78 +1317,190 @@ func (m *Model) loadRequest(item RequestItem) {
 			break
 		}
 	}
+	m.updateResponseView()
 }
 
 func (m *Model) updateResponseView() {
+	if m.isWS() {
+		m.Response.SetContent(m.renderWSLog())
+		m.Response.GotoBottom()
+		return
+	}
+	if m.streamKind != "" && m.ResponseViewTab <= 1 {
+		m.refreshStream(true)
+		return
+	}
 	switch m.ResponseViewTab {
 	case 0: // Pretty
-		m.Response.SetContent(utils.PrettyPrintJSON(m.ResponseBody))
+		if m.tree = jsonTreeFor(m.tree, m.ResponseHeaders, m.ResponseBody); m.tree != nil {
+			return
+		}
+		m.Response.SetContent(prettyBody(m.ResponseHeaders, m.ResponseBody))
 	case 1: // Raw
 		m.Response.SetContent(m.ResponseBody)
 	case 2: // Headers
-		m.Response.SetContent(m.ResponseHeaders)
+		m.Response.SetContent(secrets.Mask(m.ResponseHeaders))
+	case 3: // Timing
+		m.Response.SetContent(m.renderTiming())
+	case testsView:
+		m.Response.SetContent(m.renderTests())
 	}
 	m.Response.GotoTop()
 }
 
-func (m Model) sendRequest() tea.Cmd {
+// currentRequest returns the request in the editor, before substitution.
+func (m Model) currentRequest() RequestItem {
+	item := RequestItem{
+		Name:    m.URL.Value(),
+		Method:  m.Methods[m.SelectedMethod],
+		URL:     m.URL.Value(),
+		Auth:    strings.TrimSpace(m.Auth.Value()),
+		Headers: headerText(m.Headers.Rows),
+		Body:    m.Body.Value(),
+		// Raw is the zero value, so it is not spelled out in saved requests.
+		BodyMode: strings.TrimPrefix(BodyModes[m.BodyMode], "raw"),
+	}
+	if item.Method == MethodGraphQL {
+		item.Variables, item.BodyMode = m.Variables.Value(), ""
+	}
+	return item
+}
+
+// sendRequest sends the request in the editor. Streaming responses arrive
+// as StreamMsgs through stream before the HTTPResponseMsg.
+func (m Model) sendRequest(ctx context.Context, stream *responseStream) tea.Cmd {
+	item := m.currentRequest()
+	item.Assertions, item.Test, item.Captures = m.Loaded.Assertions, m.Loaded.Test, m.Loaded.Captures
+	item.Source = m.Loaded.Source // relative file paths in the body are resolved against it
+	item.Timeout, item.ConnectTimeout = m.Loaded.Timeout, m.Loaded.ConnectTimeout
+	item.Stream = m.Loaded.Stream
+	item = m.Timeouts.Apply(item)
+	env := m.Environment
 	return func() tea.Msg {
-		url := m.substituteEnv(m.URL.Value())
-		headers := m.substituteEnv(m.Headers.Value())
-		body := m.substituteEnv(m.Body.Value())
-
-		args := []string{"-i", "-s", "-S", "-L"}
-		args = append(args, "-X", m.Methods[m.SelectedMethod])
-		for _, h := range strings.Split(headers, "\n") {
-			if h != "" {
-				args = append(args, "-H", h)
-			}
-		}
-		if body != "" {
-			args = append(args, "-d", body)
+		go func() {
+			stream.done <- executeRequest(ctx, item, env, stream)
+			close(stream.updates)
+		}()
+		return stream.wait()()
+	}
+}
+
+func executeRequest(ctx context.Context, item RequestItem, env map[string]string, stream *responseStream) HTTPResponseMsg {
+	start := time.Now()
+	resp, err := execute(ctx, item, env, stream)
+	if err != nil {
+		return HTTPResponseMsg{Err: err, Request: item, Timing: Timing{Total: time.Since(start)}}
+	}
+
+	captured, failed := item.Capture(resp)
+	return HTTPResponseMsg{
+		Request:   item,
+		Headers:   FormatHeaders(resp.Proto, resp.Status, resp.Header),
+		Body:      string(resp.Body),
+		Code:      resp.StatusCode,
+		Status:    resp.Status,
+		Proto:     resp.Proto,
+		Timing:    resp.Timing,
+		Redirects: resp.Redirects,
+		Results:   append(item.Check(resp), failed...),
+		Captured:  captured,
+	}
+}
+
+// Running reports whether a collection run is in progress.
+func (m Model) Running() bool {
+	return m.run != nil && !m.run.done()
+}
+
+// startRun runs every request visible in the collections list, in order,
+// and shows the results in the Tests view.
+func (m *Model) startRun() tea.Cmd {
+	if m.run != nil && !m.run.done() {
+		return nil
+	}
+	var items []RequestItem
+	for _, it := range m.Collections.VisibleItems() {
+		if r, ok := it.(RequestItem); ok && r.Method != MethodWS {
+			items = append(items, m.Timeouts.Apply(r))
 		}
-		args = append(args, url)
+	}
+	if len(items) == 0 {
+		m.Notice = styles.ErrorStyle.Render("Nothing to run")
+		return nil
+	}
+	env := make(map[string]string, len(m.Environment))
+	for k, v := range m.Environment {
+		env[k] = v
+	}
+	m.run = newCollectionRun(items, env)
+	m.Notice = ""
+	m.ResponseViewTab = testsView
+	m.updateResponseView()
+	return m.run.runStep()
+}
 
-		cmd := exec.Command("curl", args...)
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			return HTTPResponseMsg{Err: fmt.Errorf("curl failed: %w\nOutput: %s", err, string(output))}
+// renderTests shows the running or finished collection run, or else the
+// checks of the last response.
+func (m Model) renderTests() string {
+	if m.run == nil {
+		if len(m.TestResults) == 0 {
+			return styles.HelpStyle.Render("No assertions for this request. Alt+R runs the collection.")
+		}
+		var b strings.Builder
+		for _, r := range m.TestResults {
+			b.WriteString(renderResult(r) + "\n")
 		}
+		return b.String()
+	}
 
-		respStr := string(output)
-		parts := strings.SplitN(respStr, "\r\n\r\n", 2)
-		if len(parts) != 2 {
-			if strings.Contains(respStr, "HTTP/1.1 100 Continue") {
-				respStr = strings.SplitN(respStr, "\r\n\r\n", 2)[1]
-			}
-			lastHeaderIndex := strings.LastIndex(respStr, "HTTP/")
-			if lastHeaderIndex > 0 {
-				respStr = respStr[lastHeaderIndex:]
-			}
-			parts = strings.SplitN(respStr, "\r\n\r\n", 2)
-			if len(parts) != 2 {
-				return HTTPResponseMsg{Err: fmt.Errorf("failed to parse HTTP response: %s", respStr)}
-			}
+	passed, failed := SummarizeCases(m.run.Cases)
+	header := fmt.Sprintf("Running %d/%d…", len(m.run.Cases)+1, len(m.run.Items))
+	if m.run.done() {
+		header = fmt.Sprintf("%d passed, %d failed · %s", passed, failed, m.run.End.Sub(m.run.Start).Round(time.Millisecond))
+		if m.run.Stopped {
+			header = fmt.Sprintf("Cancelled after %d/%d · %s", len(m.run.Cases), len(m.run.Items), header)
 		}
+	}
+	return styles.ListHeaderStyle.Render(header) + "\n" + renderTestCases(m.run.Cases)
+}
 
-		var statusCode int
-		fmt.Sscanf(parts[0], "HTTP/1.1 %d", &statusCode)
-		fmt.Sscanf(parts[0], "HTTP/2 %d", &statusCode)
-		if statusCode == 0 {
-			statusCode = http.StatusOK
+// renderTiming draws the redirect chain and a bar per request phase.
+func (m Model) renderTiming() string {
+	var b strings.Builder
+	if len(m.ResponseRedirects) > 0 {
+		b.WriteString(styles.BarHeaderStyle.Render("Redirects") + "\n")
+		for _, r := range m.ResponseRedirects {
+			fmt.Fprintf(&b, "  %d %s\n    → %s\n", r.StatusCode, r.From, r.To)
 		}
+		b.WriteString("\n")
+	}
 
-		return HTTPResponseMsg{Headers: parts[0], Body: parts[1], Code: statusCode}
+	t := m.ResponseTiming
+	if t.Total == 0 {
+		return b.String() + styles.HelpStyle.Render("No timing information.")
+	}
+	barWidth := m.Response.Width - 22
+	if barWidth < 10 {
+		barWidth = 10
+	}
+	phases := []struct {
+		name string
+		d    time.Duration
+	}{{"DNS", t.DNS}, {"Connect", t.Connect}, {"TLS", t.TLS}, {"TTFB", t.TTFB}, {"Total", t.Total}}
+	for _, p := range phases {
+		fill := int(float64(barWidth) * float64(p.d) / float64(t.Total))
+		if fill > barWidth {
+			fill = barWidth
+		}
+		bar := styles.BarStyle.Render(strings.Repeat(" ", fill)) + strings.Repeat(" ", barWidth-fill)
+		fmt.Fprintf(&b, "%-8s %s %10s\n", p.name, bar, p.d.Round(time.Microsecond*100))
+	}
+	if t.Reused {
+		b.WriteString(styles.HelpStyle.Render("\nConnection reused, no DNS/connect/TLS phases."))
 	}
+	return b.String()
 }
 
 func (m Model) substituteEnv(input string) string {
-	re := regexp.MustCompile(`\{\{([a-zA-Z0-9_]+)\}\}`)
-	return re.ReplaceAllStringFunc(input, func(s string) string {
-		key := re.FindStringSubmatch(s)[1]
-		if val, ok := m.Environment[key]; ok {
-			return val
-		}
-		return s // Return original if not found
-	})
+	return Substitute(input, m.Environment)
 }
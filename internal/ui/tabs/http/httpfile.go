@@ -0,0 +1,501 @@
+package http
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// DefaultHTTPFile is where requests that did not come from a .http file are saved.
+const DefaultHTTPFile = "phantom.http"
+
+// httpFileSkipDirs are directories never searched for .http files.
+var httpFileSkipDirs = map[string]bool{".git": true, "node_modules": true, "vendor": true}
+
+// knownMethods are the request-line methods recognised in .http files.
+var knownMethods = map[string]bool{
+	"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true,
+	"HEAD": true, "OPTIONS": true, "TRACE": true, "CONNECT": true,
+	"WEBSOCKET": true, MethodWS: true, MethodGraphQL: true,
+}
+
+// Variable is an `@name = value` declaration in a .http file.
+type Variable struct {
+	Name, Value string
+}
+
+// HTTPFile is a parsed .http / .rest file in the VS Code REST Client / JetBrains HTTP Client format.
+type HTTPFile struct {
+	Path      string
+	Name      string // collection name, for files created by an importer
+	Variables []Variable
+	Requests  []RequestItem
+	spans     []httpSpan
+}
+
+// httpSpan is where a request is in its file: lines [start, end), with its
+// request line at line.
+type httpSpan struct {
+	start, line, end int
+}
+
+// HTTPFilesLoadedMsg carries the requests and variables of the project's .http files.
+type HTTPFilesLoadedMsg struct {
+	Files []*HTTPFile
+	Err   error
+}
+
+// ParseHTTPFile reads and parses the .http file at path.
+func ParseHTTPFile(path string) (*HTTPFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := ParseHTTP(string(data))
+	f.Path = path
+	for i := range f.Requests {
+		f.Requests[i].Source = path
+		f.Requests[i].SourceName = f.Requests[i].Name
+	}
+	return f, nil
+}
+
+// ParseHTTP parses the contents of a .http file. Requests are separated by
+// lines starting with ###; the text after ### names the request.
+func ParseHTTP(src string) *HTTPFile {
+	f := &HTTPFile{}
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+
+	i := 0
+	for i < len(lines) {
+		start, name := i, ""
+		hasSeparator := strings.HasPrefix(strings.TrimSpace(lines[i]), "###")
+		if hasSeparator {
+			name = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lines[i]), "###"))
+			i++
+		}
+
+		end := i
+		for end < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[end]), "###") {
+			end++
+		}
+		if item, first, line, ok := parseHTTPBlock(lines[i:end], &f.Variables); ok {
+			if item.Name == "" {
+				item.Name = name
+			}
+			if item.Name == "" {
+				item.Name = item.Method + " " + item.URL
+			}
+			if !hasSeparator {
+				start = i + first
+			}
+			f.Requests = append(f.Requests, item)
+			f.spans = append(f.spans, httpSpan{start: start, line: i + line, end: end})
+		}
+		i = end
+	}
+	return f
+}
+
+// parseHTTPBlock parses the lines between two separators. Variable
+// declarations found before the request line are appended to vars. first is
+// the line the request starts on, including the comments leading up to it,
+// and line its request line.
+func parseHTTPBlock(lines []string, vars *[]Variable) (item RequestItem, first, line int, ok bool) {
+	i := 0
+	first = -1
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#"), strings.HasPrefix(line, "//"):
+			if first < 0 {
+				first = i
+			}
+			comment := strings.TrimSpace(strings.TrimLeft(line, "#/"))
+			if rest, found := strings.CutPrefix(comment, "@name"); found {
+				item.Name = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), "="))
+			}
+			if rest, found := strings.CutPrefix(comment, "@group"); found {
+				item.Group = strings.TrimSpace(rest)
+			}
+			if rest, found := strings.CutPrefix(comment, "@auth"); found {
+				item.Auth = strings.TrimSpace(rest)
+			}
+			if rest, found := strings.CutPrefix(comment, "@timeout"); found {
+				item.Timeout = parseHTTPTimeout(rest)
+			}
+			if rest, found := strings.CutPrefix(comment, "@connection-timeout"); found {
+				item.ConnectTimeout = parseHTTPTimeout(rest)
+			}
+			if comment == "@stream" {
+				item.Stream = true
+			}
+			if rest, found := strings.CutPrefix(comment, "@capture"); found {
+				name, expr, _ := strings.Cut(rest, "=")
+				if c, err := ParseCapture(strings.TrimSpace(name), expr); err == nil {
+					item.Captures = append(item.Captures, c)
+				} else {
+					log.Printf(".http: %v", err)
+				}
+			}
+			continue
+		case strings.HasPrefix(line, "@"):
+			if k, v, found := strings.Cut(line[1:], "="); found {
+				*vars = append(*vars, Variable{Name: strings.TrimSpace(k), Value: strings.TrimSpace(v)})
+			}
+			first = -1 // comments above a variable belong to it, not to the request
+			continue
+		}
+		break
+	}
+	if i == len(lines) {
+		return item, 0, 0, false
+	}
+
+	if first < 0 {
+		first = i
+	}
+	line = i
+	item.Method, item.URL = parseRequestLine(strings.TrimSpace(lines[i]))
+	i++
+	// Query parameters may continue on the following lines.
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(line, "?") && !strings.HasPrefix(line, "&") {
+			break
+		}
+		item.URL += line
+	}
+
+	var headers []string
+	for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+		line := strings.TrimSpace(lines[i])
+		if _, disabled := disabledHeader(line); !disabled && (strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//")) {
+			continue
+		}
+		headers = append(headers, line)
+	}
+	item.Headers = strings.Join(headers, "\n")
+
+	body := lines[min(i+1, len(lines)):]
+	for len(body) > 0 && strings.TrimSpace(body[len(body)-1]) == "" {
+		body = body[:len(body)-1]
+	}
+	item.Body = strings.Join(body, "\n")
+	switch item.Method {
+	case MethodWS:
+		item.Messages, item.Body = splitWSMessages(item.Body), ""
+		return item, first, line, true
+	case MethodGraphQL:
+		item.Body, item.Variables = splitGraphQLBody(item.Body)
+		return item, first, line, true
+	}
+	item.BodyMode, item.Headers, item.Body = bodyModeFromHeaders(item.Headers, item.Body)
+	return item, first, line, true
+}
+
+// splitWSMessages splits the body of a WEBSOCKET request into its messages,
+// which the JetBrains HTTP Client separates with === lines.
+func splitWSMessages(body string) []string {
+	var msgs []string
+	var cur []string
+	flush := func() {
+		if msg := strings.TrimSpace(strings.Join(cur, "\n")); msg != "" {
+			msgs = append(msgs, msg)
+		}
+		cur = nil
+	}
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "===") { // "=== wait-for-server" too
+			flush()
+			continue
+		}
+		cur = append(cur, line)
+	}
+	flush()
+	return msgs
+}
+
+// wsMessages returns the saved messages of a WS request, including the one
+// in its Body if it is new.
+func wsMessages(item RequestItem) []string {
+	body := strings.TrimSpace(item.Body)
+	for _, msg := range item.Messages {
+		if msg == body {
+			return item.Messages
+		}
+	}
+	if body == "" {
+		return item.Messages
+	}
+	return append(append([]string(nil), item.Messages...), body)
+}
+
+// parseHTTPTimeout reads the value of a @timeout or @connection-timeout
+// comment. As in the JetBrains HTTP Client, a bare number is in seconds and
+// units such as "500 ms" or "2 m" may follow it.
+func parseHTTPTimeout(s string) time.Duration {
+	s = strings.ReplaceAll(strings.TrimSpace(s), " ", "")
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(n * float64(time.Second))
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		log.Printf(".http: invalid timeout %q", s)
+	}
+	return d
+}
+
+func formatHTTPTimeout(d time.Duration) string {
+	if d%time.Second == 0 {
+		return strconv.FormatInt(int64(d/time.Second), 10)
+	}
+	return fmt.Sprintf("%d ms", d.Milliseconds())
+}
+
+// parseRequestLine splits "METHOD URL HTTP/1.1" into method and URL. A bare URL means GET.
+func parseRequestLine(line string) (method, url string) {
+	fields := strings.Fields(line)
+	if len(fields) > 1 && knownMethods[strings.ToUpper(fields[0])] {
+		method, fields = strings.ToUpper(fields[0]), fields[1:]
+		if method == "WEBSOCKET" {
+			method = MethodWS
+		}
+	} else {
+		method = "GET"
+	}
+	if n := len(fields); n > 1 && strings.HasPrefix(fields[n-1], "HTTP/") {
+		fields = fields[:n-1]
+	}
+	return method, strings.Join(fields, " ")
+}
+
+// FormatHTTPRequest renders a request as a .http block, including its ### separator.
+func FormatHTTPRequest(item RequestItem) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s\n", item.Name)
+	if item.Group != "" {
+		fmt.Fprintf(&b, "# @group %s\n", item.Group)
+	}
+	if item.Auth != "" {
+		fmt.Fprintf(&b, "# @auth %s\n", item.Auth)
+	}
+	if item.Timeout > 0 {
+		fmt.Fprintf(&b, "# @timeout %s\n", formatHTTPTimeout(item.Timeout))
+	}
+	if item.ConnectTimeout > 0 {
+		fmt.Fprintf(&b, "# @connection-timeout %s\n", formatHTTPTimeout(item.ConnectTimeout))
+	}
+	if item.Stream {
+		b.WriteString("# @stream\n")
+	}
+	for _, c := range item.Captures {
+		fmt.Fprintf(&b, "# @capture %s = %s\n", c.Name, c)
+	}
+	if item.Method == MethodWS {
+		fmt.Fprintf(&b, "WEBSOCKET %s\n", item.URL)
+		for _, h := range strings.Split(item.Headers, "\n") {
+			if h = strings.TrimSpace(h); h != "" {
+				b.WriteString(h + "\n")
+			}
+		}
+		if msgs := wsMessages(item); len(msgs) > 0 {
+			b.WriteString("\n===\n" + strings.Join(msgs, "\n===\n") + "\n")
+		}
+		return b.String()
+	}
+	if item.Method == MethodGraphQL {
+		fmt.Fprintf(&b, "GRAPHQL %s\n", item.URL)
+		for _, h := range strings.Split(item.Headers, "\n") {
+			if h = strings.TrimSpace(h); h != "" {
+				b.WriteString(h + "\n")
+			}
+		}
+		if query := strings.TrimSpace(item.Body); query != "" {
+			b.WriteString("\n" + query + "\n")
+		}
+		if vars := strings.TrimSpace(item.Variables); vars != "" {
+			b.WriteString("\n" + vars + "\n")
+		}
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%s %s\n", item.Method, item.URL)
+	headers := strings.Split(item.Headers, "\n")
+	contentType, body := formatBody(item.BodyMode, strings.TrimRight(item.Body, "\n\t "))
+	if contentType != "" && strings.TrimSpace(item.Body) != "" {
+		headers = setDefaultHeader(headers, "Content-Type", contentType)
+	}
+	for _, h := range headers {
+		if h = strings.TrimSpace(h); h != "" {
+			b.WriteString(h + "\n")
+		}
+	}
+	if strings.TrimSpace(item.Body) != "" {
+		b.WriteString("\n" + body + "\n")
+	}
+	return b.String()
+}
+
+// FormatHTTP renders variables and requests as a complete .http file.
+func FormatHTTP(vars []Variable, items []RequestItem) string {
+	var b strings.Builder
+	for _, v := range vars {
+		fmt.Fprintf(&b, "@%s = %s\n", v.Name, v.Value)
+	}
+	for i, item := range items {
+		if i > 0 || len(vars) > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(FormatHTTPRequest(item))
+	}
+	return b.String()
+}
+
+// WriteHTTPFile writes f as a complete .http file to path.
+func WriteHTTPFile(path string, f *HTTPFile) error {
+	return os.WriteFile(path, []byte(FormatHTTP(f.Variables, f.Requests)), 0o644)
+}
+
+// HTTPFileName turns a collection name into a file name such as "my-api.http".
+func HTTPFileName(name string) string {
+	slug := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if slug == "" {
+		slug = "imported"
+	}
+	return slug + ".http"
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// SaveHTTPRequest writes item back to the .http file it came from, replacing
+// its block in place, or appends it to DefaultHTTPFile when it has no source.
+// It returns the path that was written.
+func SaveHTTPRequest(item RequestItem) (string, error) {
+	path := item.Source
+	if path == "" {
+		path = DefaultHTTPFile
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	content := string(data)
+	f := ParseHTTP(content)
+	block := strings.TrimSuffix(FormatHTTPRequest(item), "\n")
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+
+	idx := -1
+	if item.Source != "" {
+		idx = findHTTPRequest(f, item)
+	}
+	if idx >= 0 {
+		span := f.spans[idx]
+		formatted := strings.Split(block, "\n")
+		// Keep the variables and comments above the request line. Directives
+		// are left out, as block writes them again.
+		replacement := []string{formatted[0]}
+		for _, l := range lines[span.start:span.line] {
+			if !strings.HasPrefix(strings.TrimSpace(l), "###") && !isRequestDirective(l) {
+				replacement = append(replacement, l)
+			}
+		}
+		replacement = append(replacement, formatted[1:]...)
+		if span.end < len(lines) || strings.HasSuffix(content, "\n") { // a blank line before the next request, or the final newline
+			replacement = append(replacement, "")
+		}
+		lines = append(lines[:span.start], append(replacement, lines[span.end:]...)...)
+		content = strings.Join(lines, "\n")
+	} else {
+		content = strings.TrimRight(content, "\n")
+		if content != "" {
+			content += "\n\n"
+		}
+		content += block + "\n"
+	}
+	return path, os.WriteFile(path, []byte(content), 0o644)
+}
+
+// requestDirectives are the comments FormatHTTPRequest writes from the
+// fields of a request.
+var requestDirectives = []string{"@name", "@group", "@auth", "@timeout", "@connection-timeout", "@stream", "@capture"}
+
+// isRequestDirective reports whether line is one of requestDirectives.
+func isRequestDirective(line string) bool {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "//") {
+		return false
+	}
+	comment := strings.TrimSpace(strings.TrimLeft(line, "#/"))
+	for _, d := range requestDirectives {
+		if strings.HasPrefix(comment, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// findHTTPRequest locates item within f by the name it was loaded with.
+func findHTTPRequest(f *HTTPFile, item RequestItem) int {
+	name := item.SourceName
+	if name == "" {
+		name = item.Name
+	}
+	for i, r := range f.Requests {
+		if r.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// FindHTTPFiles returns the .http and .rest files below root, sorted by path.
+func FindHTTPFiles(root string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // unreadable directories are skipped
+		}
+		if d.IsDir() {
+			if path != root && (httpFileSkipDirs[d.Name()] || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(path); ext == ".http" || ext == ".rest" {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	sort.Strings(paths)
+	return paths, err
+}
+
+func loadHTTPFiles() tea.Cmd {
+	return func() tea.Msg {
+		paths, err := FindHTTPFiles(".")
+		if err != nil {
+			return HTTPFilesLoadedMsg{Err: err}
+		}
+		var files []*HTTPFile
+		for _, p := range paths {
+			f, err := ParseHTTPFile(p)
+			if err != nil {
+				return HTTPFilesLoadedMsg{Err: err}
+			}
+			files = append(files, f)
+		}
+		return HTTPFilesLoadedMsg{Files: files}
+	}
+}
@@ -0,0 +1,254 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSaveHTTPRequestKeepsVariablesAndComments(t *testing.T) {
+	src := `# Shared requests of the auth service
+@base = https://auth.example.com
+
+### login
+# Logs in as the test user.
+# See docs/auth.md for the accounts.
+@host = example.com
+# @group Auth
+POST https://{{host}}/login
+Content-Type: application/json
+
+{"user": "test"}
+
+### me
+# Needs {{token}} from login.
+GET {{base}}/me
+`
+	want := `# Shared requests of the auth service
+@base = https://auth.example.com
+
+### login
+# Logs in as the test user.
+# See docs/auth.md for the accounts.
+@host = example.com
+# @group Users
+POST https://{{host}}/v2/login
+Content-Type: application/json
+
+{"user": "admin"}
+
+### me
+# Needs {{token}} from login.
+GET {{base}}/me
+`
+	path := filepath.Join(t.TempDir(), "auth.http")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := ParseHTTPFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := f.Requests[0]
+	item.Group, item.URL, item.Body = "Users", "https://{{host}}/v2/login", `{"user": "admin"}`
+	if _, err := SaveHTTPRequest(item); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("saved file:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Saving again without changes leaves the file as it is.
+	f, err = ParseHTTPFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range f.Requests {
+		if _, err := SaveHTTPRequest(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	again, _ := os.ReadFile(path)
+	if string(again) != want {
+		t.Errorf("saving unchanged requests rewrote the file:\n%s", again)
+	}
+}
+
+func TestParseHTTPSpans(t *testing.T) {
+	src := `@base = https://api.example.com
+
+# Lists the users.
+GET {{base}}/users
+Accept: application/json
+
+### create user
+// @name Create
+@role = admin
+POST {{base}}/users
+    ?notify=true
+    &role={{role}}
+Content-Type: application/json
+
+{"name": "ada"}
+
+
+###
+### health
+GET {{base}}/health HTTP/1.1
+###   
+# only a comment
+`
+	f := ParseHTTP(src)
+	want := []struct {
+		name, method, url string
+		span              httpSpan
+	}{
+		{"GET {{base}}/users", "GET", "{{base}}/users", httpSpan{start: 2, line: 3, end: 6}},
+		{"Create", "POST", "{{base}}/users?notify=true&role={{role}}", httpSpan{start: 6, line: 9, end: 17}},
+		{"health", "GET", "{{base}}/health", httpSpan{start: 18, line: 19, end: 20}},
+	}
+	if len(f.Requests) != len(want) || len(f.spans) != len(want) {
+		t.Fatalf("parsed %d requests and %d spans, want %d", len(f.Requests), len(f.spans), len(want))
+	}
+	for i, w := range want {
+		got := f.Requests[i]
+		if got.Name != w.name || got.Method != w.method || got.URL != w.url || f.spans[i] != w.span {
+			t.Errorf("request %d = %q %s %s %+v, want %q %s %s %+v", i, got.Name, got.Method, got.URL, f.spans[i], w.name, w.method, w.url, w.span)
+		}
+	}
+	if body := f.Requests[1].Body; body != `{"name": "ada"}` {
+		t.Errorf("body = %q, want it without the trailing blank lines", body)
+	}
+	vars := []Variable{{"base", "https://api.example.com"}, {"role", "admin"}}
+	if !reflect.DeepEqual(f.Variables, vars) {
+		t.Errorf("variables = %v, want %v", f.Variables, vars)
+	}
+}
+
+func TestFormatHTTPRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"raw body", `### create
+# @group Users/Admin
+# @auth bearer {{token}}
+# @timeout 500 ms
+# @connection-timeout 2
+# @capture id = $.id
+# @capture etag = header:ETag
+POST https://api.example.com/users
+Content-Type: application/json
+# X-Disabled: 1
+
+{
+  "name": "ada"
+}
+`},
+		{"form body", `### login
+POST https://api.example.com/login
+Content-Type: application/x-www-form-urlencoded
+
+user=ada%20l
+&password={{password}}
+`},
+		{"multipart body", `### upload
+POST https://api.example.com/files
+Content-Type: multipart/form-data; boundary=` + formBoundary + `
+
+--` + formBoundary + `
+Content-Disposition: form-data; name="title"
+
+Report
+--` + formBoundary + `
+Content-Disposition: form-data; name="file"; filename="report.pdf"
+
+< ./report.pdf
+--` + formBoundary + `--
+`},
+		{"file body", `### put
+PUT https://api.example.com/blob
+
+< ./data.bin
+`},
+		{"stream", `### events
+# @stream
+GET https://api.example.com/events
+Accept: text/event-stream
+`},
+		{"graphql", `### viewer
+GRAPHQL https://api.example.com/graphql
+Authorization: Bearer {{token}}
+
+query Viewer($id: ID!) {
+  user(id: $id) { name }
+}
+
+{"id": "1"}
+`},
+		{"websocket", `### chat
+WEBSOCKET wss://api.example.com/chat
+Origin: https://example.com
+
+===
+{"type": "hello"}
+===
+{"type": "bye"}
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := ParseHTTP(tt.src)
+			if len(f.Requests) != 1 {
+				t.Fatalf("parsed %d requests, want 1", len(f.Requests))
+			}
+			out := FormatHTTP(f.Variables, f.Requests)
+			if out != tt.src {
+				t.Errorf("FormatHTTP =\n%s\nwant\n%s", out, tt.src)
+			}
+			again := ParseHTTP(out)
+			if !reflect.DeepEqual(again.Requests, f.Requests) {
+				t.Errorf("parsing the formatted file gave\n%#v\nwant\n%#v", again.Requests, f.Requests)
+			}
+		})
+	}
+}
+
+func TestHTTPFileChainedVariables(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.RequestURI() + " " + r.Header.Get("X-Loop")
+	}))
+	defer srv.Close()
+
+	f := ParseHTTP(`@host = ` + strings.TrimPrefix(srv.URL, "http://") + `
+@baseUrl = http://{{host}}
+@users = {{baseUrl}}/users
+@a = {{b}}
+@b = {{a}}
+
+### list
+GET {{users}}?host={{host}}
+X-Loop: {{a}}
+`)
+	env := map[string]string{}
+	for _, v := range f.Variables {
+		env[v.Name] = v.Value
+	}
+	if _, err := Execute(context.Background(), f.Requests[0], env); err != nil {
+		t.Fatal(err)
+	}
+	want := "/users?host=" + strings.TrimPrefix(srv.URL, "http://") + " {{a}}"
+	if got != want {
+		t.Errorf("server got %q, want %q", got, want)
+	}
+}
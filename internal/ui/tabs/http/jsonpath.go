@@ -0,0 +1,133 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DecodeJSON decodes a response body, keeping numbers as json.Number so
+// large integers keep their precision.
+func DecodeJSON(body []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// JSONPath evaluates a simple JSONPath expression such as
+// `$.items[0].name`, `$['a key'][-1]` or `$.count` against decoded JSON.
+// Wildcards and filters are not supported.
+func JSONPath(doc any, path string) (any, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(path), "$")
+	if !ok {
+		return nil, fmt.Errorf("jsonpath %q must start with $", path)
+	}
+	v := doc
+	for rest != "" {
+		var key string
+		index, isIndex := 0, false
+		switch {
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key, rest = rest[1:end+1], rest[end+1:]
+		case strings.HasPrefix(rest, "['") || strings.HasPrefix(rest, `["`):
+			quote := rest[1]
+			end := strings.IndexByte(rest[2:], quote)
+			if end < 0 || len(rest) < end+4 || rest[end+3] != ']' {
+				return nil, fmt.Errorf("jsonpath %q: unterminated [", path)
+			}
+			key, rest = rest[2:end+2], rest[end+4:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("jsonpath %q: unterminated [", path)
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(rest[1:end]))
+			if err != nil {
+				return nil, fmt.Errorf("jsonpath %q: bad index %q", path, rest[1:end])
+			}
+			index, isIndex, rest = n, true, rest[end+1:]
+		default:
+			return nil, fmt.Errorf("jsonpath %q: unexpected %q", path, rest)
+		}
+
+		if isIndex {
+			arr, ok := v.([]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: not an array", path)
+			}
+			if index < 0 {
+				index += len(arr)
+			}
+			if index < 0 || index >= len(arr) {
+				return nil, fmt.Errorf("%s: index %d out of range", path, index)
+			}
+			v = arr[index]
+			continue
+		}
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: not an object", path)
+		}
+		if v, ok = obj[key]; !ok {
+			return nil, fmt.Errorf("%s: no such key %q", path, key)
+		}
+	}
+	return v, nil
+}
+
+// jsonEqual compares a decoded JSON value with an expected value from the
+// configuration. Numbers compare by value.
+func jsonEqual(actual, expected any) bool {
+	if n, ok := actual.(json.Number); ok {
+		switch e := expected.(type) {
+		case float64:
+			f, err := n.Float64()
+			return err == nil && f == e
+		case int:
+			i, err := n.Int64()
+			return err == nil && i == int64(e)
+		case json.Number:
+			return n == e
+		case string:
+			return n.String() == e
+		}
+		return false
+	}
+	switch e := expected.(type) {
+	case string:
+		s, ok := actual.(string)
+		return ok && s == e
+	case bool:
+		b, ok := actual.(bool)
+		return ok && b == e
+	case nil:
+		return actual == nil
+	}
+	// Arrays and objects compare by their canonical encoding.
+	a, errA := json.Marshal(actual)
+	e, errE := json.Marshal(expected)
+	return errA == nil && errE == nil && bytes.Equal(a, e)
+}
+
+// formatJSONValue renders a decoded JSON value compactly, for messages.
+func formatJSONValue(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	const max = 60
+	if len(data) > max {
+		return string(data[:max]) + "…"
+	}
+	return string(data)
+}
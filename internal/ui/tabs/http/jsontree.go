@@ -0,0 +1,273 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"phantom/internal/ui/components/styles"
+	"phantom/internal/utils"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// jsonNode is a value of a JSON document. Keys and scalars are kept as
+// written, so key order and number precision survive.
+type jsonNode struct {
+	key       string // with its quotes; empty for array elements and the root
+	value     string // scalars as written; "{" or "[" for containers
+	kind      utils.JSONKind
+	children  []*jsonNode
+	parent    *jsonNode
+	collapsed bool
+}
+
+func (n *jsonNode) container() bool { return n.kind == utils.JSONPunct }
+
+// closing is the bracket that ends a container.
+func (n *jsonNode) closing() string {
+	if n.value == "{" {
+		return "}"
+	}
+	return "]"
+}
+
+// jsonRow is one visible line of the tree: a value, or the closing bracket
+// of an expanded container.
+type jsonRow struct {
+	node  *jsonNode
+	depth int
+	close bool
+	last  bool // of its container, so without a comma
+}
+
+// jsonTree is the collapsible Pretty view of JSON responses. Only the rows
+// on screen are formatted, so large bodies scroll as fast as small ones.
+type jsonTree struct {
+	source string // the body it was parsed from
+	root   *jsonNode
+	rows   []jsonRow // rebuilt when a node is expanded or collapsed
+	cursor int
+	offset int
+}
+
+// jsonTreeFor returns the tree of a JSON body, reusing t if it already
+// shows body, or nil if the Pretty view should format body another way.
+func jsonTreeFor(t *jsonTree, headers, body string) *jsonTree {
+	if t != nil && t.source == body {
+		return t
+	}
+	mediaType := responseMediaType(headers, body)
+	if !isJSONContentType(mediaType) && mediaType != "text/plain" {
+		return nil
+	}
+	root := parseJSONTree(body)
+	if root == nil {
+		return nil
+	}
+	t = &jsonTree{source: body, root: root}
+	t.layout()
+	return t
+}
+
+// parseJSONTree builds the nodes of body, or returns nil if it is not JSON.
+func parseJSONTree(body string) *jsonNode {
+	if !json.Valid([]byte(body)) {
+		return nil
+	}
+	var root, parent *jsonNode
+	key := ""
+	add := func(n *jsonNode) {
+		n.key, n.parent, key = key, parent, ""
+		if parent == nil {
+			root = n
+		} else {
+			parent.children = append(parent.children, n)
+		}
+	}
+	utils.ScanJSON(body, func(kind utils.JSONKind, text string) {
+		switch {
+		case kind == utils.JSONKey:
+			key = text
+		case text == "{" || text == "[":
+			n := &jsonNode{value: text, kind: utils.JSONPunct}
+			add(n)
+			parent = n
+		case text == "}" || text == "]":
+			parent = parent.parent
+		case kind != utils.JSONPunct:
+			add(&jsonNode{value: text, kind: kind})
+		}
+	})
+	return root
+}
+
+// layout lists the rows of the expanded parts of the tree.
+func (t *jsonTree) layout() {
+	t.rows = t.rows[:0]
+	var add func(n *jsonNode, depth int, last bool)
+	add = func(n *jsonNode, depth int, last bool) {
+		t.rows = append(t.rows, jsonRow{node: n, depth: depth, last: last})
+		if n.collapsed || len(n.children) == 0 {
+			return
+		}
+		for i, c := range n.children {
+			add(c, depth+1, i == len(n.children)-1)
+		}
+		t.rows = append(t.rows, jsonRow{node: n, depth: depth, close: true, last: last})
+	}
+	add(t.root, 0, true)
+}
+
+// moveTo puts the cursor on the opening row of n, or of its closest
+// visible ancestor.
+func (t *jsonTree) moveTo(n *jsonNode) {
+	for ; n != nil; n = n.parent {
+		for i, r := range t.rows {
+			if r.node == n && !r.close {
+				t.cursor = i
+				return
+			}
+		}
+	}
+}
+
+// setCollapsed collapses or expands every container under the root.
+func (t *jsonTree) setCollapsed(collapsed bool) {
+	var walk func(n *jsonNode)
+	walk = func(n *jsonNode) {
+		for _, c := range n.children {
+			if c.container() {
+				c.collapsed = collapsed
+				walk(c)
+			}
+		}
+	}
+	walk(t.root)
+	t.root.collapsed = false
+}
+
+// showsTree reports whether the response pane shows the JSON tree rather
+// than the viewport.
+func (m Model) showsTree() bool {
+	return m.tree != nil && m.ResponseViewTab == 0 && m.streamKind == "" && !m.isWS()
+}
+
+// updateJSONTree handles keys while the response pane shows the JSON tree.
+func (m *Model) updateJSONTree(msg tea.KeyMsg) tea.Cmd {
+	t := m.tree
+	page := max(m.Response.Height, 1)
+	row := t.rows[t.cursor]
+	switch msg.String() {
+	case "up", "k":
+		t.cursor--
+	case "down", "j":
+		t.cursor++
+	case "pgup", "ctrl+u":
+		t.cursor -= page
+	case "pgdown", "ctrl+d":
+		t.cursor += page
+	case "home", "g":
+		t.cursor = 0
+	case "end", "G":
+		t.cursor = len(t.rows) - 1
+	case "enter", " ": // Expand or collapse the value under the cursor
+		if row.node.container() && len(row.node.children) > 0 {
+			row.node.collapsed = !row.node.collapsed
+			t.layout()
+			t.moveTo(row.node)
+		}
+	case "c": // Collapse everything
+		t.setCollapsed(true)
+		t.layout()
+		t.moveTo(row.node)
+	case "e": // Expand everything
+		t.setCollapsed(false)
+		t.layout()
+		t.moveTo(row.node)
+	}
+	t.cursor = max(min(t.cursor, len(t.rows)-1), 0)
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if t.cursor >= t.offset+page {
+		t.offset = t.cursor - page + 1
+	}
+	return nil
+}
+
+// renderJSONTree draws the rows that fit in the response pane.
+func (m Model) renderJSONTree() string {
+	t := m.tree
+	width := max(m.Response.Width, 10)
+	var out strings.Builder
+	for i := t.offset; i < len(t.rows) && i < t.offset+m.Response.Height; i++ {
+		if i == t.cursor && m.FocusedPane == 2 {
+			out.WriteString(styles.SelectedCellStyle.Render(truncate(t.rows[i].render(width, false), width)) + "\n")
+			continue
+		}
+		out.WriteString(lipgloss.NewStyle().MaxWidth(width).Render(t.rows[i].render(width, true)) + "\n")
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// render lays out the row, colored if styled. Keys and values are clipped
+// well past width, so a huge string costs no more than a short one.
+func (r jsonRow) render(width int, styled bool) string {
+	n := r.node
+	color := func(kind utils.JSONKind, text string) string {
+		if limit := 4 * width; len(text) > limit {
+			text = strings.ToValidUTF8(text[:limit], "") + "…"
+		}
+		if styled {
+			return utils.HighlightJSON(kind, text)
+		}
+		return text
+	}
+	var b strings.Builder
+	b.WriteString(strings.Repeat("  ", r.depth))
+	switch {
+	case r.close || !n.container() || len(n.children) == 0:
+		b.WriteString("  ")
+	case n.collapsed:
+		b.WriteString("▸ ")
+	default:
+		b.WriteString("▾ ")
+	}
+	if r.close {
+		b.WriteString(n.closing())
+	} else {
+		if n.key != "" {
+			b.WriteString(color(utils.JSONKey, n.key) + ": ")
+		}
+		switch {
+		case !n.container():
+			b.WriteString(color(n.kind, n.value))
+		case len(n.children) == 0:
+			b.WriteString(n.value + n.closing())
+		case n.collapsed:
+			b.WriteString(n.value + "…" + n.closing())
+		default:
+			b.WriteString(n.value)
+		}
+	}
+	if !r.last && (r.close || !n.container() || len(n.children) == 0 || n.collapsed) {
+		b.WriteString(",")
+	}
+	if n.collapsed && !r.close && len(n.children) > 0 {
+		noun := "items"
+		if n.value == "{" {
+			noun = "keys"
+		}
+		if len(n.children) == 1 {
+			noun = strings.TrimSuffix(noun, "s")
+		}
+		count := fmt.Sprintf(" %d %s", len(n.children), noun)
+		if styled {
+			count = styles.HelpStyle.Render(count)
+		}
+		b.WriteString(count)
+	}
+	return b.String()
+}
@@ -0,0 +1,576 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"gopkg.in/yaml.v3"
+)
+
+// openAPIPollInterval is how often the spec file is checked for changes.
+const openAPIPollInterval = 2 * time.Second
+
+// openAPISpecNames are the spec files picked up automatically, relative to the project root.
+var openAPISpecNames = []string{
+	"openapi.yaml", "openapi.yml", "openapi.json",
+	"api/openapi.yaml", "api/openapi.yml", "api/openapi.json",
+	"docs/openapi.yaml", "docs/openapi.yml", "docs/openapi.json",
+}
+
+// openAPIMethods are the operation keys of a path item, in display order.
+var openAPIMethods = []string{"get", "post", "put", "patch", "delete", "head", "options", "trace"}
+
+// OpenAPILoadedMsg carries the collection generated from an OpenAPI spec.
+// It is sent on the first load and again every time the file changes.
+type OpenAPILoadedMsg struct {
+	Path    string
+	ModTime time.Time
+	File    *HTTPFile
+	Err     error
+}
+
+// FindOpenAPISpec returns the first well-known spec file below root, or "".
+func FindOpenAPISpec(root string) string {
+	for _, name := range openAPISpecNames {
+		path := filepath.Join(root, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// watchOpenAPI waits until the spec at path has a modification time other
+// than since, then regenerates the collection from it.
+func watchOpenAPI(path string, since time.Time) tea.Cmd {
+	return func() tea.Msg {
+		for {
+			info, err := os.Stat(path)
+			switch {
+			case err != nil && since.IsZero():
+				return OpenAPILoadedMsg{Path: path, Err: err}
+			case err == nil && !info.ModTime().Equal(since):
+				f, err := ParseOpenAPI(path)
+				return OpenAPILoadedMsg{Path: path, ModTime: info.ModTime(), File: f, Err: err}
+			}
+			time.Sleep(openAPIPollInterval)
+		}
+	}
+}
+
+// openAPISpec is a parsed spec. Nodes are kept as YAML so that property
+// order and number literals survive into the generated examples.
+type openAPISpec struct {
+	root *yaml.Node
+	vars map[string]bool // variables already declared
+	file *HTTPFile
+}
+
+// ParseOpenAPI generates a collection from an OpenAPI 3 spec in YAML or JSON.
+// Every operation becomes a request grouped by its first tag, path
+// parameters become {{variables}}, request bodies are filled from examples
+// or schemas, and server URLs become baseUrl variables.
+func ParseOpenAPI(path string) (*HTTPFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: not an OpenAPI document", path)
+	}
+	root := doc.Content[0]
+	version := scalar(child(root, "openapi"))
+	if !strings.HasPrefix(version, "3.") {
+		if scalar(child(root, "swagger")) != "" {
+			return nil, fmt.Errorf("%s: Swagger 2.0 is not supported, convert it to OpenAPI 3", path)
+		}
+		return nil, fmt.Errorf("%s: missing openapi version", path)
+	}
+
+	s := &openAPISpec{root: root, vars: map[string]bool{}, file: &HTTPFile{Path: path}}
+	s.file.Name = scalar(child(child(root, "info"), "title"))
+	s.servers()
+
+	pathItems := child(root, "paths")
+	eachPair(pathItems, func(p string, item *yaml.Node) {
+		item = s.resolve(item)
+		for _, method := range openAPIMethods {
+			if op := child(item, method); op != nil {
+				s.operation(method, p, op, child(item, "parameters"))
+			}
+		}
+	})
+	return s.file, nil
+}
+
+// servers declares baseUrl for the first server and baseUrl2, baseUrl3, ...
+// for the others, with server variables replaced by their defaults.
+func (s *openAPISpec) servers() {
+	servers := child(s.root, "servers")
+	if servers == nil || len(servers.Content) == 0 {
+		s.declare("baseUrl", "http://localhost")
+		return
+	}
+	for i, server := range servers.Content {
+		u := scalar(child(server, "url"))
+		eachPair(child(server, "variables"), func(name string, v *yaml.Node) {
+			u = strings.ReplaceAll(u, "{"+name+"}", scalar(child(v, "default")))
+		})
+		name := "baseUrl"
+		if i > 0 {
+			name += strconv.Itoa(i + 1)
+		}
+		s.declare(name, strings.TrimSuffix(u, "/"))
+	}
+}
+
+func (s *openAPISpec) declare(name, value string) {
+	if s.vars[name] {
+		return
+	}
+	s.vars[name] = true
+	s.file.Variables = append(s.file.Variables, Variable{Name: name, Value: value})
+}
+
+var openAPIPathParam = regexp.MustCompile(`\{([^}/]+)\}`)
+
+func (s *openAPISpec) operation(method, path string, op, shared *yaml.Node) {
+	item := RequestItem{
+		Method: strings.ToUpper(method),
+		URL:    "{{baseUrl}}" + openAPIPathParam.ReplaceAllString(path, "{{$1}}"),
+	}
+	item.Name = scalar(child(op, "summary"))
+	if item.Name == "" {
+		item.Name = scalar(child(op, "operationId"))
+	}
+	if item.Name == "" {
+		item.Name = item.Method + " " + path
+	}
+	if tags := child(op, "tags"); tags != nil && len(tags.Content) > 0 {
+		item.Group = scalar(tags.Content[0])
+	}
+
+	var headers, query []string
+	params := append(s.list(shared), s.list(child(op, "parameters"))...)
+	for _, p := range dedupeParams(params) {
+		name, in := scalar(child(p, "name")), scalar(child(p, "in"))
+		example, hasExample := s.paramExample(p)
+		switch in {
+		case "path":
+			if hasExample {
+				s.declare(name, example)
+			}
+		case "query":
+			if scalar(child(p, "required")) == "true" {
+				if !hasExample {
+					example = "{{" + name + "}}"
+				}
+				query = append(query, escapeQuery(name)+"="+escapeQuery(example))
+			}
+		case "header": // optional headers are added disabled
+			if !hasExample {
+				example = "{{" + name + "}}"
+			}
+			if scalar(child(p, "required")) == "true" {
+				headers = append(headers, name+": "+example)
+			} else {
+				headers = append(headers, "# "+name+": "+example)
+			}
+		}
+	}
+
+	auth, authQuery := s.security(op)
+	headers = append(headers, auth...)
+	query = append(query, authQuery...)
+	if len(query) > 0 {
+		item.URL += "?" + strings.Join(query, "&")
+	}
+
+	if body := s.resolve(child(op, "requestBody")); body != nil {
+		contentType, text := s.requestBody(body)
+		if contentType != "" {
+			headers = append(headers, "Content-Type: "+contentType)
+		}
+		item.Body = text
+	}
+	item.Headers = strings.Join(headers, "\n")
+	s.file.Requests = append(s.file.Requests, item)
+}
+
+// dedupeParams drops path-level parameters overridden by the operation.
+func dedupeParams(params []*yaml.Node) []*yaml.Node {
+	var out []*yaml.Node
+	index := map[string]int{}
+	for _, p := range params {
+		key := scalar(child(p, "in")) + ":" + scalar(child(p, "name"))
+		if i, ok := index[key]; ok {
+			out[i] = p
+			continue
+		}
+		index[key] = len(out)
+		out = append(out, p)
+	}
+	return out
+}
+
+// paramExample returns a literal value for a parameter, if the spec has one.
+func (s *openAPISpec) paramExample(p *yaml.Node) (string, bool) {
+	ex := child(p, "example")
+	if ex == nil {
+		if examples := child(p, "examples"); examples != nil && len(examples.Content) > 1 {
+			ex = child(s.resolve(examples.Content[1]), "value")
+		}
+	}
+	if ex == nil {
+		schema := s.resolve(child(p, "schema"))
+		ex = child(schema, "example")
+		if ex == nil {
+			ex = child(schema, "default")
+		}
+		if enum := child(schema, "enum"); ex == nil && enum != nil && len(enum.Content) > 0 {
+			ex = enum.Content[0]
+		}
+	}
+	if ex == nil || ex.Kind != yaml.ScalarNode {
+		return "", false
+	}
+	return ex.Value, true
+}
+
+// security turns the first security requirement into headers or query
+// parameters that reference variables named after the scheme.
+func (s *openAPISpec) security(op *yaml.Node) (headers, query []string) {
+	reqs := child(op, "security")
+	if reqs == nil {
+		reqs = child(s.root, "security")
+	}
+	if reqs == nil || len(reqs.Content) == 0 {
+		return nil, nil
+	}
+	schemes := child(child(s.root, "components"), "securitySchemes")
+	eachPair(reqs.Content[0], func(name string, _ *yaml.Node) {
+		scheme := s.resolve(child(schemes, name))
+		switch scalar(child(scheme, "type")) {
+		case "http":
+			switch strings.ToLower(scalar(child(scheme, "scheme"))) {
+			case "bearer":
+				headers = append(headers, "Authorization: Bearer {{"+name+"}}")
+			case "basic":
+				headers = append(headers, "Authorization: Basic {{username}}:{{password}}")
+			}
+		case "apiKey":
+			key := scalar(child(scheme, "name"))
+			switch scalar(child(scheme, "in")) {
+			case "header":
+				headers = append(headers, key+": {{"+name+"}}")
+			case "query":
+				query = append(query, escapeQuery(key)+"={{"+name+"}}")
+			}
+		case "oauth2", "openIdConnect":
+			headers = append(headers, "Authorization: Bearer {{"+name+"}}")
+		}
+	})
+	return headers, query
+}
+
+// requestBody picks a media type, preferring JSON, and renders an example for it.
+func (s *openAPISpec) requestBody(body *yaml.Node) (contentType, text string) {
+	content := child(body, "content")
+	if content == nil || len(content.Content) < 2 {
+		return "", ""
+	}
+	media := content.Content[1]
+	contentType = content.Content[0].Value
+	eachPair(content, func(ct string, m *yaml.Node) {
+		if isJSONContentType(ct) && !isJSONContentType(contentType) {
+			contentType, media = ct, m
+		}
+	})
+
+	var value any
+	switch ex := child(media, "example"); {
+	case ex != nil:
+		value = nodeValue(ex)
+	case child(media, "examples") != nil && len(child(media, "examples").Content) > 1:
+		value = nodeValue(child(s.resolve(child(media, "examples").Content[1]), "value"))
+	default:
+		value = s.schemaExample(child(media, "schema"), map[string]bool{})
+	}
+
+	switch {
+	case isJSONContentType(contentType):
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return contentType, ""
+		}
+		return contentType, string(data)
+	case contentType == "application/x-www-form-urlencoded":
+		var pairs []string
+		if obj, ok := value.(jsonObject); ok {
+			for _, f := range obj {
+				pairs = append(pairs, escapeQuery(f.Key)+"="+escapeQuery(fmt.Sprint(plainValue(f.Value))))
+			}
+		}
+		return contentType, strings.Join(pairs, "&")
+	case value != nil:
+		if str, ok := value.(string); ok {
+			return contentType, str
+		}
+	}
+	return contentType, ""
+}
+
+func isJSONContentType(ct string) bool {
+	ct = strings.ToLower(ct)
+	return ct == "application/json" || strings.HasSuffix(ct, "+json")
+}
+
+// schemaExample builds an example value for a schema. seen guards against
+// recursive references.
+func (s *openAPISpec) schemaExample(schema *yaml.Node, seen map[string]bool) any {
+	if ref := scalar(child(schema, "$ref")); ref != "" {
+		if seen[ref] {
+			return nil
+		}
+		seen[ref] = true
+		defer delete(seen, ref)
+	}
+	schema = s.resolve(schema)
+	if schema == nil {
+		return nil
+	}
+	for _, k := range []string{"example", "default", "const"} {
+		if ex := child(schema, k); ex != nil {
+			return nodeValue(ex)
+		}
+	}
+	if examples := child(schema, "examples"); examples != nil && examples.Kind == yaml.SequenceNode && len(examples.Content) > 0 {
+		return nodeValue(examples.Content[0])
+	}
+	if enum := child(schema, "enum"); enum != nil && len(enum.Content) > 0 {
+		return nodeValue(enum.Content[0])
+	}
+	if all := child(schema, "allOf"); all != nil {
+		var merged jsonObject
+		for _, sub := range all.Content {
+			if obj, ok := s.schemaExample(sub, seen).(jsonObject); ok {
+				merged = append(merged, obj...)
+			}
+		}
+		return merged
+	}
+	for _, k := range []string{"oneOf", "anyOf"} {
+		if alts := child(schema, k); alts != nil && len(alts.Content) > 0 {
+			return s.schemaExample(alts.Content[0], seen)
+		}
+	}
+
+	typ := scalar(child(schema, "type"))
+	if t := child(schema, "type"); t != nil && t.Kind == yaml.SequenceNode { // 3.1 type lists
+		for _, c := range t.Content {
+			if typ == "" || typ == "null" {
+				typ = c.Value
+			}
+		}
+	}
+	if typ == "" && child(schema, "properties") != nil {
+		typ = "object"
+	}
+	switch typ {
+	case "object":
+		obj := jsonObject{}
+		eachPair(child(schema, "properties"), func(name string, prop *yaml.Node) {
+			obj = append(obj, jsonField{Key: name, Value: s.schemaExample(prop, seen)})
+		})
+		return obj
+	case "array":
+		if items := child(schema, "items"); items != nil {
+			return []any{s.schemaExample(items, seen)}
+		}
+		return []any{}
+	case "string":
+		return stringExample(scalar(child(schema, "format")))
+	case "integer", "number":
+		return json.Number("0")
+	case "boolean":
+		return true
+	}
+	return nil
+}
+
+func stringExample(format string) string {
+	switch format {
+	case "date-time":
+		return "2024-01-01T00:00:00Z"
+	case "date":
+		return "2024-01-01"
+	case "time":
+		return "00:00:00"
+	case "email":
+		return "user@example.com"
+	case "uuid":
+		return "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+	case "uri", "url":
+		return "https://example.com"
+	case "ipv4":
+		return "127.0.0.1"
+	case "byte":
+		return "c3RyaW5n"
+	}
+	return "string"
+}
+
+// resolve follows local $ref pointers such as "#/components/schemas/User".
+func (s *openAPISpec) resolve(n *yaml.Node) *yaml.Node {
+	for depth := 0; n != nil && depth < 32; depth++ {
+		ref := scalar(child(n, "$ref"))
+		if ref == "" {
+			return n
+		}
+		if !strings.HasPrefix(ref, "#/") {
+			return nil // external references are not followed
+		}
+		target := s.root
+		for _, part := range strings.Split(ref[2:], "/") {
+			part, _ = url.PathUnescape(part)
+			part = strings.NewReplacer("~1", "/", "~0", "~").Replace(part)
+			target = child(target, part)
+		}
+		n = target
+	}
+	return n
+}
+
+func (s *openAPISpec) list(n *yaml.Node) []*yaml.Node {
+	if n == nil {
+		return nil
+	}
+	var out []*yaml.Node
+	for _, c := range n.Content {
+		if r := s.resolve(c); r != nil {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// child returns the value of key in a mapping node, or nil.
+func child(n *yaml.Node, key string) *yaml.Node {
+	if n != nil && n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// eachPair calls fn for every key/value pair of a mapping node, in order.
+func eachPair(n *yaml.Node, fn func(key string, value *yaml.Node)) {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		fn(n.Content[i].Value, n.Content[i+1])
+	}
+}
+
+func scalar(n *yaml.Node) string {
+	if n == nil || n.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return n.Value
+}
+
+// jsonObject is a JSON object that keeps its keys in declaration order.
+type jsonObject []jsonField
+
+type jsonField struct {
+	Key   string
+	Value any
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, err := json.Marshal(f.Key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// nodeValue converts an example node to a value for encoding/json. Numbers
+// are kept as their literal text so no precision is lost.
+func nodeValue(n *yaml.Node) any {
+	if n == nil {
+		return nil
+	}
+	switch n.Kind {
+	case yaml.AliasNode:
+		return nodeValue(n.Alias)
+	case yaml.MappingNode:
+		obj := jsonObject{}
+		eachPair(n, func(k string, v *yaml.Node) {
+			obj = append(obj, jsonField{Key: k, Value: nodeValue(v)})
+		})
+		return obj
+	case yaml.SequenceNode:
+		arr := []any{}
+		for _, c := range n.Content {
+			arr = append(arr, nodeValue(c))
+		}
+		return arr
+	}
+	switch n.ShortTag() {
+	case "!!null":
+		return nil
+	case "!!bool":
+		b, _ := strconv.ParseBool(strings.ToLower(n.Value))
+		return b
+	case "!!int", "!!float":
+		if json.Valid([]byte(n.Value)) {
+			return json.Number(n.Value)
+		}
+	}
+	return n.Value
+}
+
+// plainValue renders scalars for form bodies.
+func plainValue(v any) any {
+	if v == nil {
+		return ""
+	}
+	return v
+}
@@ -0,0 +1,180 @@
+package http
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseOpenAPI(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    string // the collection as a .http file
+		wantErr string
+	}{
+		{
+			name: "operations",
+			spec: `openapi: 3.0.3
+info: {title: Users}
+servers:
+  - url: https://{region}.example.com/v1/
+    variables: {region: {default: eu}}
+  - url: http://localhost:8080
+security: [{token: []}]
+components:
+  securitySchemes:
+    token: {type: http, scheme: bearer}
+    key: {type: apiKey, in: query, name: api_key}
+paths:
+  /users/{id}:
+    parameters:
+      - {name: id, in: path, example: 42}
+      - {name: verbose, in: query, required: true}
+    get:
+      summary: Get user
+      tags: [Users, Admin]
+      parameters:
+        - {name: verbose, in: query, required: true, schema: {type: boolean, default: false}}
+        - {name: X-Trace, in: header}
+    delete:
+      operationId: deleteUser
+      security: [{key: []}]
+`,
+			want: `@baseUrl = https://eu.example.com/v1
+@baseUrl2 = http://localhost:8080
+@id = 42
+
+### Get user
+# @group Users
+GET {{baseUrl}}/users/{{id}}?verbose=false
+# X-Trace: {{X-Trace}}
+Authorization: Bearer {{token}}
+
+### deleteUser
+DELETE {{baseUrl}}/users/{{id}}?verbose={{verbose}}&api_key={{key}}
+`,
+		},
+		{
+			name: "recursive schema",
+			spec: `openapi: 3.1.0
+paths:
+  /nodes:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/Node'}
+components:
+  schemas:
+    Node:
+      type: object
+      properties:
+        name: {type: string}
+        children: {type: array, items: {$ref: '#/components/schemas/Node'}}
+        parent: {$ref: '#/components/schemas/Node'}
+`,
+			want: `@baseUrl = http://localhost
+
+### POST /nodes
+POST {{baseUrl}}/nodes
+Content-Type: application/json
+
+{
+  "name": "string",
+  "children": [
+    null
+  ],
+  "parent": null
+}
+`,
+		},
+		{
+			name: "mutually recursive schemas",
+			spec: `openapi: 3.0.0
+paths:
+  /orders:
+    put:
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/Order'}
+components:
+  schemas:
+    Order:
+      properties:
+        id: {type: integer}
+        customer: {$ref: '#/components/schemas/Customer'}
+    Customer:
+      allOf:
+        - properties:
+            email: {type: string, format: email}
+        - properties:
+            orders: {type: array, items: {$ref: '#/components/schemas/Order'}}
+`,
+			want: `@baseUrl = http://localhost
+
+### PUT /orders
+PUT {{baseUrl}}/orders
+Content-Type: application/json
+
+{
+  "id": 0,
+  "customer": {
+    "email": "user@example.com",
+    "orders": [
+      null
+    ]
+  }
+}
+`,
+		},
+		{
+			name: "reference loop",
+			spec: `openapi: 3.0.0
+paths:
+  /a:
+    $ref: '#/components/pathItems/A'
+  /b:
+    post:
+      requestBody: {$ref: '#/components/requestBodies/B'}
+components:
+  pathItems:
+    A: {$ref: '#/components/pathItems/A'}
+  requestBodies:
+    B: {$ref: '#/components/requestBodies/C'}
+    C: {$ref: '#/components/requestBodies/B'}
+`,
+			want: `@baseUrl = http://localhost
+
+### POST /b
+POST {{baseUrl}}/b
+`,
+		},
+		{name: "swagger", spec: `swagger: "2.0"`, wantErr: "Swagger 2.0 is not supported"},
+		{name: "no version", spec: `info: {title: x}`, wantErr: "missing openapi version"},
+		{name: "not a mapping", spec: `- a`, wantErr: "not an OpenAPI document"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "openapi.yaml")
+			if err := os.WriteFile(path, []byte(tt.spec), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			f, err := ParseOpenAPI(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseOpenAPI error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := FormatHTTP(f.Variables, f.Requests); got != tt.want {
+				t.Errorf("ParseOpenAPI =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,414 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"phantom/internal/secrets"
+)
+
+// postmanCollection is the subset of the Postman collection v2.1 schema phantom understands.
+type postmanCollection struct {
+	Info struct {
+		Name   string `json:"name"`
+		Schema string `json:"schema"`
+	} `json:"info"`
+	Item     []postmanItem     `json:"item"`
+	Auth     *postmanAuth      `json:"auth"`
+	Variable []postmanKeyValue `json:"variable"`
+}
+
+// postmanItem is either a folder (Item set) or a request (Request set).
+type postmanItem struct {
+	Name    string          `json:"name"`
+	Item    []postmanItem   `json:"item"`
+	Request *postmanRequest `json:"request"`
+	Auth    *postmanAuth    `json:"auth"`
+}
+
+type postmanRequest struct {
+	Method string            `json:"method"`
+	Header []postmanKeyValue `json:"header"`
+	URL    postmanURL        `json:"url"`
+	Body   *postmanBody      `json:"body"`
+	Auth   *postmanAuth      `json:"auth"`
+}
+
+// postmanURL is either a plain string or an object with a raw field.
+type postmanURL struct {
+	Raw string `json:"raw"`
+}
+
+func (u *postmanURL) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		u.Raw = s
+		return nil
+	}
+	type plain postmanURL
+	return json.Unmarshal(data, (*plain)(u))
+}
+
+type postmanBody struct {
+	Mode       string            `json:"mode"`
+	Raw        string            `json:"raw"`
+	URLEncoded []postmanKeyValue `json:"urlencoded"`
+	FormData   []postmanKeyValue `json:"formdata"`
+	GraphQL    struct {
+		Query     string `json:"query"`
+		Variables string `json:"variables"`
+	} `json:"graphql"`
+	Options struct {
+		Raw struct {
+			Language string `json:"language"`
+		} `json:"raw"`
+	} `json:"options"`
+}
+
+type postmanAuth struct {
+	Type   string            `json:"type"`
+	Bearer []postmanKeyValue `json:"bearer"`
+	Basic  []postmanKeyValue `json:"basic"`
+	APIKey []postmanKeyValue `json:"apikey"`
+}
+
+type postmanKeyValue struct {
+	Key      string `json:"key"`
+	Value    any    `json:"value"`
+	Type     string `json:"type"`
+	Src      any    `json:"src"`
+	Disabled bool   `json:"disabled"`
+	Enabled  *bool  `json:"enabled"` // environments use enabled instead of disabled
+}
+
+func (kv postmanKeyValue) value() string {
+	if kv.Value == nil {
+		return ""
+	}
+	if s, ok := kv.Value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(kv.Value)
+}
+
+func (kv postmanKeyValue) active() bool {
+	return !kv.Disabled && (kv.Enabled == nil || *kv.Enabled)
+}
+
+// postmanEnvironment is a Postman environment export.
+type postmanEnvironment struct {
+	Name   string            `json:"name"`
+	Values []postmanKeyValue `json:"values"`
+}
+
+var postmanLanguageTypes = map[string]string{
+	"json": "application/json",
+	"xml":  "application/xml",
+	"html": "text/html",
+	"text": "text/plain",
+}
+
+// PostmanExport is a Postman export: either a collection or an environment.
+type PostmanExport struct {
+	Collection  *HTTPFile
+	Environment *NamedEnvironment
+	// Secrets are the values of the secret variables of Environment, by the
+	// name they go by in the secrets file. Environment references them.
+	Secrets map[string]string
+}
+
+// ImportPostman reads a Postman collection v2.1 or environment export.
+// Folders become request groups and collection variables become variables.
+// An environment becomes a named environment whose secret variables are
+// ${secret:name} references.
+func ImportPostman(path string) (PostmanExport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return PostmanExport{}, err
+	}
+
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return PostmanExport{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if _, ok := probe["values"]; ok {
+		var env postmanEnvironment
+		if err := json.Unmarshal(data, &env); err != nil {
+			return PostmanExport{}, fmt.Errorf("%s: %w", path, err)
+		}
+		if env.Name == "" {
+			env.Name = strings.TrimSuffix(strings.TrimSuffix(fileBase(path), ".json"), ".postman_environment")
+		}
+		named := &NamedEnvironment{Name: env.Name, Vars: map[string]string{}}
+		export := PostmanExport{Environment: named, Secrets: map[string]string{}}
+		for _, kv := range env.Values {
+			if !kv.active() || kv.Key == "" {
+				continue
+			}
+			if kv.Type != "secret" {
+				named.Vars[kv.Key] = kv.value()
+				continue
+			}
+			name := secretName("postman." + env.Name + "." + kv.Key)
+			named.Vars[kv.Key] = "${secret:" + name + "}"
+			export.Secrets[name] = kv.value()
+		}
+		return export, nil
+	}
+
+	var c postmanCollection
+	if err := json.Unmarshal(data, &c); err != nil {
+		return PostmanExport{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if c.Info.Schema != "" && !strings.Contains(c.Info.Schema, "v2.") {
+		return PostmanExport{}, fmt.Errorf("%s: unsupported Postman schema %s, export as v2.1", path, c.Info.Schema)
+	}
+	f := &HTTPFile{Name: c.Info.Name, Variables: postmanVariables(c.Variable)}
+	f.Requests = postmanItems(c.Item, "", c.Auth)
+	return PostmanExport{Collection: f}, nil
+}
+
+// secretName turns s into a name ${secret:name} references accept.
+func secretName(s string) string {
+	return nonSecretNameChars.ReplaceAllString(s, "_")
+}
+
+var nonSecretNameChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+func postmanVariables(kvs []postmanKeyValue) []Variable {
+	var vars []Variable
+	for _, kv := range kvs {
+		if kv.active() && kv.Key != "" {
+			vars = append(vars, Variable{Name: kv.Key, Value: kv.value()})
+		}
+	}
+	return vars
+}
+
+// postmanItems flattens a folder tree, tracking the folder path as the group
+// and the auth inherited from enclosing folders.
+func postmanItems(items []postmanItem, group string, auth *postmanAuth) []RequestItem {
+	var out []RequestItem
+	for _, it := range items {
+		itemAuth := auth
+		if it.Auth != nil && it.Auth.Type != "inherit" {
+			itemAuth = it.Auth
+		}
+		if it.Request == nil {
+			sub := it.Name
+			if group != "" {
+				sub = group + "/" + it.Name
+			}
+			out = append(out, postmanItems(it.Item, sub, itemAuth)...)
+			continue
+		}
+		if it.Request.Auth != nil && it.Request.Auth.Type != "inherit" {
+			itemAuth = it.Request.Auth
+		}
+		out = append(out, postmanToRequest(it.Name, group, it.Request, itemAuth))
+	}
+	return out
+}
+
+func postmanToRequest(name, group string, r *postmanRequest, auth *postmanAuth) RequestItem {
+	item := RequestItem{Name: name, Group: group, Method: strings.ToUpper(r.Method), URL: r.URL.Raw}
+	if item.Method == "" {
+		item.Method = "GET"
+	}
+
+	var headers []string
+	hasContentType := false
+	for _, h := range r.Header {
+		if h.active() {
+			headers = append(headers, fmt.Sprintf("%s: %s", h.Key, h.value()))
+			hasContentType = hasContentType || strings.EqualFold(h.Key, "Content-Type")
+		} else {
+			headers = append(headers, fmt.Sprintf("# %s: %s", h.Key, h.value()))
+		}
+	}
+
+	if auth != nil {
+		switch auth.Type {
+		case "bearer":
+			headers = append(headers, "Authorization: Bearer "+postmanParam(auth.Bearer, "token"))
+		case "basic":
+			headers = append(headers, "Authorization: "+basicAuth(postmanParam(auth.Basic, "username"), postmanParam(auth.Basic, "password")))
+		case "apikey":
+			k, v := postmanParam(auth.APIKey, "key"), postmanParam(auth.APIKey, "value")
+			if postmanParam(auth.APIKey, "in") == "query" {
+				item.URL = appendQuery(item.URL, k, v)
+			} else {
+				headers = append(headers, fmt.Sprintf("%s: %s", k, v))
+			}
+		}
+	}
+
+	if b := r.Body; b != nil {
+		contentType := ""
+		switch b.Mode {
+		case "raw":
+			item.Body = b.Raw
+			contentType = postmanLanguageTypes[b.Options.Raw.Language]
+		case "urlencoded":
+			var pairs []string
+			for _, kv := range b.URLEncoded {
+				if kv.active() {
+					pairs = append(pairs, escapeQuery(kv.Key)+"="+escapeQuery(kv.value()))
+				}
+			}
+			item.Body = strings.Join(pairs, "&")
+			contentType = "application/x-www-form-urlencoded"
+		case "formdata":
+			item.Body = multipartBody(postmanFormFields(b.FormData))
+			contentType = "multipart/form-data; boundary=" + formBoundary
+		case "graphql":
+			item.Method, item.Body, item.Variables = MethodGraphQL, b.GraphQL.Query, b.GraphQL.Variables
+		}
+		if contentType != "" && !hasContentType {
+			headers = append(headers, "Content-Type: "+contentType)
+		}
+	}
+
+	item.Headers = strings.Join(headers, "\n")
+	return item
+}
+
+// formField is one part of a multipart/form-data body.
+type formField struct {
+	Name, Value string
+	File        bool // Value is a path to upload
+}
+
+// formBoundary separates the parts of the multipart bodies phantom writes.
+const formBoundary = "PhantomFormBoundary"
+
+// multipartBody writes form fields as a literal multipart body. File fields
+// use the REST Client "< path" include syntax.
+func multipartBody(fields []formField) string {
+	var b strings.Builder
+	for _, f := range fields {
+		fmt.Fprintf(&b, "--%s\n", formBoundary)
+		if f.File {
+			fmt.Fprintf(&b, "Content-Disposition: form-data; name=%q; filename=%q\n\n< %s\n", f.Name, fileBase(f.Value), f.Value)
+			continue
+		}
+		fmt.Fprintf(&b, "Content-Disposition: form-data; name=%q\n\n%s\n", f.Name, f.Value)
+	}
+	fmt.Fprintf(&b, "--%s--", formBoundary)
+	return b.String()
+}
+
+func postmanFormFields(kvs []postmanKeyValue) []formField {
+	var fields []formField
+	for _, kv := range kvs {
+		if !kv.active() {
+			continue
+		}
+		if kv.Type == "file" {
+			src := fmt.Sprint(kv.Src)
+			if list, ok := kv.Src.([]any); ok && len(list) > 0 {
+				src = fmt.Sprint(list[0])
+			}
+			fields = append(fields, formField{Name: kv.Key, Value: src, File: true})
+			continue
+		}
+		fields = append(fields, formField{Name: kv.Key, Value: kv.value()})
+	}
+	return fields
+}
+
+func postmanParam(kvs []postmanKeyValue, key string) string {
+	for _, kv := range kvs {
+		if kv.Key == key {
+			return kv.value()
+		}
+	}
+	return ""
+}
+
+// basicAuth builds a Basic Authorization value. Credentials that reference
+// variables can't be encoded yet, so they are kept in the REST Client
+// "Basic user:password" form and encoded when the request is sent.
+func basicAuth(user, password string) string {
+	creds := user + ":" + password
+	if envRefName.MatchString(creds) {
+		return "Basic " + creds
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(creds))
+}
+
+func appendQuery(rawURL, key, value string) string {
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
+	}
+	return rawURL + sep + escapeQuery(key) + "=" + escapeQuery(value)
+}
+
+// escapeQuery query-escapes s but leaves {{variable}} references intact.
+func escapeQuery(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range envRefName.FindAllStringIndex(s, -1) {
+		b.WriteString(url.QueryEscape(s[last:loc[0]]))
+		b.WriteString(s[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(url.QueryEscape(s[last:]))
+	return b.String()
+}
+
+func fileBase(path string) string {
+	if i := strings.LastIndexAny(path, `/\`); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
+
+// ImportPostmanFile imports a Postman export. A collection is written as a
+// .http file, named after it when dst is empty; existing files are only
+// replaced when overwrite is set. An environment is added to the imported
+// environments of the project, outside of it, and its secret values to the
+// secrets file vault returns, which is only asked for when there are any.
+// It returns what was written, for messages.
+func ImportPostmanFile(src, dst string, overwrite bool, vault func() (*secrets.Vault, error)) (string, error) {
+	export, err := ImportPostman(src)
+	if err != nil {
+		return "", err
+	}
+	if env := export.Environment; env != nil {
+		if len(export.Secrets) > 0 {
+			v, err := vault()
+			if err != nil {
+				return "", fmt.Errorf("%s has secret values: %w", env.Name, err)
+			}
+			for name, value := range export.Secrets {
+				v.Set(name, value)
+			}
+			if err := v.Save(); err != nil {
+				return "", err
+			}
+		}
+		store, err := NewEnvironmentStore()
+		if err != nil {
+			return "", err
+		}
+		if err := store.Put(*env); err != nil {
+			return "", err
+		}
+		return "environment " + env.Name, nil
+	}
+	f := export.Collection
+	if dst == "" {
+		dst = HTTPFileName(f.Name)
+	}
+	if _, err := os.Stat(dst); err == nil && !overwrite {
+		return "", fmt.Errorf("%s already exists", dst)
+	}
+	return dst, WriteHTTPFile(dst, f)
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPostmanVariableNames(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	}))
+	defer srv.Close()
+
+	src := filepath.Join(t.TempDir(), "api.postman_collection.json")
+	collection := `{
+  "info": {"name": "API", "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+  "item": [{
+    "name": "items",
+    "request": {
+      "method": "GET",
+      "url": {"raw": "{{base-url}}/items?key={{api.key}}"},
+      "header": [{"key": "X-Trace", "value": "{{X-Trace}}"}]
+    }
+  }]
+}`
+	if err := os.WriteFile(src, []byte(collection), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	export, err := ImportPostman(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{"base-url": srv.URL, "api.key": "k1", "X-Trace": "t-1"}
+	if _, err := Execute(context.Background(), export.Collection.Requests[0], env); err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.RequestURI() != "/items?key=k1" || got.Header.Get("X-Trace") != "t-1" {
+		t.Errorf("server got %s with X-Trace %q, want /items?key=k1 with t-1", got.URL.RequestURI(), got.Header.Get("X-Trace"))
+	}
+}
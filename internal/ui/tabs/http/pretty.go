@@ -0,0 +1,401 @@
+package http
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // registered for imageSummary
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"phantom/internal/ui/components/styles"
+	"phantom/internal/utils"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+	"golang.org/x/net/html"
+	"gopkg.in/yaml.v3"
+)
+
+// maxHexDump caps how much of a binary body the Pretty view dumps.
+const maxHexDump = 64 << 10 // 64 KiB
+
+// prettyBody formats body for the Pretty view by its Content-Type, taken
+// from the response headers or, when there is none, sniffed from the body.
+// Bodies that fail to parse as their type are shown as they are.
+func prettyBody(headers, body string) string {
+	mediaType := responseMediaType(headers, body)
+	switch {
+	case strings.HasPrefix(mediaType, "image/") && mediaType != "image/svg+xml":
+		return imageSummary(mediaType, body)
+	case isJSONContentType(mediaType):
+		return utils.PrettyPrintJSON(body)
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		return prettyHTML(body)
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return prettyXML(body)
+	case isYAMLContentType(mediaType):
+		return prettyYAML(body)
+	case mediaType == "application/x-www-form-urlencoded":
+		return formTable(body)
+	case isBinary(body):
+		return hexDump(mediaType, body)
+	}
+	return utils.PrettyPrintJSON(body) // JSON served as text/plain is common enough
+}
+
+// responseMediaType is the media type of the Content-Type header among
+// headers, the "Key: Value" lines of FormatHeaders.
+func responseMediaType(headers, body string) string {
+	for _, line := range strings.Split(headers, "\n") {
+		if k, v, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(k), "Content-Type") {
+			if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(v)); err == nil {
+				return mediaType
+			}
+		}
+	}
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType([]byte(body)))
+	return mediaType
+}
+
+func isYAMLContentType(ct string) bool {
+	switch ct {
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return true
+	}
+	return strings.HasSuffix(ct, "+yaml")
+}
+
+// isBinary reports whether body is not text: invalid UTF-8 or control
+// characters other than whitespace near its start.
+func isBinary(body string) bool {
+	sample := body
+	if len(sample) > 8<<10 {
+		sample = sample[:8<<10]
+		// Forgive a character cut in half at the end of the sample.
+		for i := 0; i < utf8.UTFMax-1 && !utf8.ValidString(sample); i++ {
+			sample = sample[:len(sample)-1]
+		}
+	}
+	if !utf8.ValidString(sample) {
+		return true
+	}
+	for _, r := range sample {
+		if r < 0x20 && r != '\n' && r != '\r' && r != '\t' && r != '\f' {
+			return true
+		}
+	}
+	return false
+}
+
+// hexDump renders up to maxHexDump bytes of body as offset, hex and ASCII
+// columns, like hexdump -C.
+func hexDump(mediaType, body string) string {
+	header := fmt.Sprintf("Binary, %s", utils.FormatBytes(uint64(len(body))))
+	if mediaType != "" {
+		header += " of " + mediaType
+	}
+	if len(body) > maxHexDump {
+		header += fmt.Sprintf(", first %s shown", utils.FormatBytes(maxHexDump))
+		body = body[:maxHexDump]
+	}
+	return styles.HelpStyle.Render(header) + "\n" + hex.Dump([]byte(body))
+}
+
+// imageSummary describes an image instead of dumping its bytes.
+func imageSummary(mediaType, body string) string {
+	rows := [][2]string{{"Type", mediaType}, {"Size", utils.FormatBytes(uint64(len(body)))}}
+	cfg, format, err := image.DecodeConfig(strings.NewReader(body))
+	if err != nil {
+		rows = append(rows, [2]string{"Dimensions", "unknown (" + err.Error() + ")"})
+	} else {
+		rows = append(rows,
+			[2]string{"Format", format},
+			[2]string{"Dimensions", fmt.Sprintf("%d × %d px", cfg.Width, cfg.Height)},
+			[2]string{"Color model", colorModelName(cfg.ColorModel)},
+		)
+	}
+	var b strings.Builder
+	b.WriteString(styles.ListHeaderStyle.Render("Image") + "\n")
+	for _, r := range rows {
+		fmt.Fprintf(&b, "%s %s\n", styles.JSONKeyStyle.Render(fmt.Sprintf("%-12s", r[0])), r[1])
+	}
+	return b.String()
+}
+
+func colorModelName(m color.Model) string {
+	if p, ok := m.(color.Palette); ok {
+		return fmt.Sprintf("paletted, %d colors", len(p))
+	}
+	switch m {
+	case color.RGBAModel:
+		return "RGBA"
+	case color.RGBA64Model:
+		return "RGBA, 16-bit"
+	case color.NRGBAModel:
+		return "NRGBA"
+	case color.NRGBA64Model:
+		return "NRGBA, 16-bit"
+	case color.GrayModel:
+		return "grayscale"
+	case color.Gray16Model:
+		return "grayscale, 16-bit"
+	case color.YCbCrModel:
+		return "YCbCr"
+	case color.CMYKModel:
+		return "CMYK"
+	case color.AlphaModel, color.Alpha16Model:
+		return "alpha"
+	}
+	return "other"
+}
+
+// formTable lists the fields of a form-encoded body in order, one per line
+// with the names aligned.
+func formTable(body string) string {
+	type field struct{ name, value string }
+	var fields []field
+	width := 0
+	for _, pair := range strings.Split(strings.TrimSpace(body), "&") {
+		if pair == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(k)
+		if err != nil {
+			return body
+		}
+		value, err := url.QueryUnescape(v)
+		if err != nil {
+			return body
+		}
+		fields = append(fields, field{name, value})
+		width = max(width, len(name))
+	}
+	var b strings.Builder
+	b.WriteString(styles.HelpStyle.Render(fmt.Sprintf("%d fields", len(fields))) + "\n")
+	for _, f := range fields {
+		fmt.Fprintf(&b, "%s │ %s\n", styles.JSONKeyStyle.Render(f.name+strings.Repeat(" ", width-len(f.name))), f.value)
+	}
+	return b.String()
+}
+
+// prettyYAML re-indents a YAML body, keeping its key order and comments.
+func prettyYAML(body string) string {
+	dec := yaml.NewDecoder(strings.NewReader(body))
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return body
+		}
+		if err := enc.Encode(&doc); err != nil {
+			return body
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return body
+	}
+	return out.String()
+}
+
+// markupToken is a piece of an XML or HTML document, as the markup printer
+// lays it out.
+type markupToken struct {
+	kind markupKind
+	text string // rendered
+	name string // of elements
+}
+
+type markupKind int
+
+const (
+	markupStart markupKind = iota
+	markupEnd
+	markupEmpty // self-closing and void elements
+	markupText
+	markupOther // comments, directives and processing instructions
+)
+
+// prettyXML indents an XML body, one element per line, with elements that
+// only hold text kept on one line.
+func prettyXML(body string) string {
+	dec := xml.NewDecoder(strings.NewReader(body))
+	dec.Strict = false
+	var tokens []markupToken
+	for {
+		tok, err := dec.RawToken() // keeps namespace prefixes as written
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return body
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			var b strings.Builder
+			b.WriteString("<" + styles.JSONKeyStyle.Render(xmlName(t.Name)))
+			for _, a := range t.Attr {
+				var v bytes.Buffer
+				xml.EscapeText(&v, []byte(a.Value))
+				fmt.Fprintf(&b, " %s=%s", xmlName(a.Name), styles.JSONStringStyle.Render(`"`+v.String()+`"`))
+			}
+			tokens = append(tokens, markupToken{kind: markupStart, text: b.String() + ">", name: xmlName(t.Name)})
+		case xml.EndElement:
+			tokens = append(tokens, markupToken{kind: markupEnd, text: "</" + styles.JSONKeyStyle.Render(xmlName(t.Name)) + ">", name: xmlName(t.Name)})
+		case xml.CharData:
+			tokens = append(tokens, markupToken{kind: markupText, text: xmlTextEscaper.Replace(string(t))})
+		case xml.Comment:
+			tokens = append(tokens, markupToken{kind: markupOther, text: styles.HelpStyle.Render("<!--" + string(t) + "-->")})
+		case xml.ProcInst:
+			tokens = append(tokens, markupToken{kind: markupOther, text: fmt.Sprintf("<?%s %s?>", t.Target, t.Inst)})
+		case xml.Directive:
+			tokens = append(tokens, markupToken{kind: markupOther, text: "<!" + string(t) + ">"})
+		}
+	}
+	return printMarkup(tokens, nil)
+}
+
+// xmlTextEscaper escapes text like xml.EscapeText, but leaves line breaks
+// for printMarkup to lay out.
+var xmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func xmlName(n xml.Name) string {
+	if n.Space != "" {
+		return n.Space + ":" + n.Local
+	}
+	return n.Local
+}
+
+// voidElements have no end tag in HTML.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// rawElements keep their content as it is written.
+var rawElements = map[string]bool{"pre": true, "script": true, "style": true, "textarea": true}
+
+// prettyHTML indents an HTML body like prettyXML. The content of pre,
+// script, style and textarea elements is left alone.
+func prettyHTML(body string) string {
+	z := html.NewTokenizer(strings.NewReader(body))
+	var tokens []markupToken
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if !errors.Is(z.Err(), io.EOF) {
+				return body
+			}
+			break
+		}
+		raw := string(z.Raw()) // before Token, which may reuse it
+		t := z.Token()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			var b strings.Builder
+			b.WriteString("<" + styles.JSONKeyStyle.Render(t.Data))
+			for _, a := range t.Attr {
+				b.WriteString(" " + a.Key)
+				if a.Val != "" {
+					b.WriteString("=" + styles.JSONStringStyle.Render(`"`+html.EscapeString(a.Val)+`"`))
+				}
+			}
+			kind := markupStart
+			if tt == html.SelfClosingTagToken || voidElements[t.Data] {
+				kind = markupEmpty
+			}
+			tokens = append(tokens, markupToken{kind: kind, text: b.String() + ">", name: t.Data})
+		case html.EndTagToken:
+			if !voidElements[t.Data] {
+				tokens = append(tokens, markupToken{kind: markupEnd, text: "</" + styles.JSONKeyStyle.Render(t.Data) + ">", name: t.Data})
+			}
+		case html.TextToken:
+			tokens = append(tokens, markupToken{kind: markupText, text: raw})
+		case html.CommentToken:
+			tokens = append(tokens, markupToken{kind: markupOther, text: styles.HelpStyle.Render("<!--" + t.Data + "-->")})
+		case html.DoctypeToken:
+			tokens = append(tokens, markupToken{kind: markupOther, text: "<!DOCTYPE " + t.Data + ">"})
+		}
+	}
+	return printMarkup(tokens, rawElements)
+}
+
+// printMarkup lays tokens out one per line, indented by nesting. An
+// element holding nothing but text stays on one line, and the text inside
+// raw elements is printed as it is.
+func printMarkup(tokens []markupToken, raw map[string]bool) string {
+	var b strings.Builder
+	depth := 0
+	var open []string
+	line := func(s string) {
+		b.WriteString(strings.Repeat("  ", depth) + s + "\n")
+	}
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch t.kind {
+		case markupStart:
+			next := func(k int) (markupToken, bool) {
+				if i+k < len(tokens) {
+					return tokens[i+k], true
+				}
+				return markupToken{}, false
+			}
+			if end, ok := next(1); ok && end.kind == markupEnd && end.name == t.name {
+				line(t.text + end.text)
+				i++
+				continue
+			}
+			if text, ok := next(1); ok && text.kind == markupText && !strings.Contains(strings.TrimSpace(text.text), "\n") {
+				if end, ok := next(2); ok && end.kind == markupEnd && end.name == t.name {
+					line(t.text + strings.TrimSpace(text.text) + end.text)
+					i += 2
+					continue
+				}
+			}
+			line(t.text)
+			open = append(open, t.name)
+			depth++
+		case markupEnd:
+			// Unbalanced HTML, such as a missing </p>, closes what it can.
+			for j := len(open) - 1; j >= 0; j-- {
+				if open[j] == t.name {
+					depth -= len(open) - j
+					open = open[:j]
+					break
+				}
+			}
+			line(t.text)
+		case markupText:
+			if len(open) > 0 && raw[open[len(open)-1]] {
+				b.WriteString(strings.Trim(t.text, "\n") + "\n")
+				continue
+			}
+			for _, l := range strings.Split(t.text, "\n") {
+				if l = strings.TrimSpace(l); l != "" {
+					line(l)
+				}
+			}
+		default:
+			line(t.text)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,173 @@
+package http
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// junitTestSuites is the root of a JUnit XML report.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// WriteJUnit writes cases as a JUnit XML report, one test suite per request
+// group; ungrouped requests go into a suite called name. Failed checks are failures; requests that could not be sent are errors.
+func WriteJUnit(w io.Writer, name string, start time.Time, cases []TestCase) error {
+	report := junitTestSuites{}
+	index := map[string]int{}
+	durations := map[int]time.Duration{}
+	var total time.Duration
+	for _, c := range cases {
+		suiteName := name
+		if c.Request.Group != "" {
+			suiteName = c.Request.Group
+		}
+		i, ok := index[suiteName]
+		if !ok {
+			i = len(report.Suites)
+			index[suiteName] = i
+			report.Suites = append(report.Suites, junitTestSuite{Name: suiteName, Timestamp: start.UTC().Format(time.RFC3339)})
+		}
+		suite := &report.Suites[i]
+
+		tc := junitTestCase{
+			Name:      c.Request.Method + " " + c.Request.Name,
+			Classname: suiteName,
+			Time:      seconds(c.Duration),
+		}
+		var lines []string
+		for _, r := range c.Results {
+			lines = append(lines, checkLine(r))
+		}
+		tc.SystemOut = strings.Join(lines, "\n")
+		switch {
+		case c.Err != nil:
+			tc.Error = &junitMessage{Message: c.Err.Error(), Type: "RequestError"}
+			suite.Errors++
+		case !c.Passed():
+			failed := len(c.Results) - countPassed(c.Results)
+			tc.Failure = &junitMessage{Message: fmt.Sprintf("%d of %d checks failed", failed, len(c.Results)), Type: "AssertionError", Text: tc.SystemOut}
+			suite.Failures++
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, tc)
+		durations[i] += c.Duration
+		total += c.Duration
+	}
+	for i := range report.Suites {
+		report.Suites[i].Time = seconds(durations[i])
+		report.Tests += report.Suites[i].Tests
+		report.Failures += report.Suites[i].Failures
+		report.Errors += report.Suites[i].Errors
+	}
+	report.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// checkLine renders a check result as plain text.
+func checkLine(r AssertionResult) string {
+	mark := "PASS"
+	if !r.Passed {
+		mark = "FAIL"
+	}
+	line := mark + " " + r.Name
+	if r.Message != "" {
+		line += ": " + r.Message
+	}
+	return line
+}
+
+// jsonReport is the JSON report format.
+type jsonReport struct {
+	Name       string           `json:"name"`
+	Start      time.Time        `json:"start"`
+	DurationMS int64            `json:"duration_ms"`
+	Passed     int              `json:"passed"`
+	Failed     int              `json:"failed"`
+	Requests   []jsonReportCase `json:"requests"`
+}
+
+type jsonReportCase struct {
+	Name       string            `json:"name"`
+	Group      string            `json:"group,omitempty"`
+	Method     string            `json:"method"`
+	URL        string            `json:"url"`
+	Status     int               `json:"status"`
+	DurationMS int64             `json:"duration_ms"`
+	Passed     bool              `json:"passed"`
+	Error      string            `json:"error,omitempty"`
+	Checks     []AssertionResult `json:"checks,omitempty"`
+}
+
+// WriteJSONReport writes cases as a JSON report.
+func WriteJSONReport(w io.Writer, name string, start time.Time, cases []TestCase) error {
+	report := jsonReport{Name: name, Start: start, DurationMS: time.Since(start).Milliseconds(), Requests: []jsonReportCase{}}
+	report.Passed, report.Failed = SummarizeCases(cases)
+	for _, c := range cases {
+		rc := jsonReportCase{
+			Name:       c.Request.Name,
+			Group:      c.Request.Group,
+			Method:     c.Request.Method,
+			URL:        c.Request.URL,
+			Status:     c.Status,
+			DurationMS: c.Duration.Milliseconds(),
+			Passed:     c.Passed(),
+			Checks:     c.Results,
+		}
+		if c.Err != nil {
+			rc.Error = c.Err.Error()
+		}
+		report.Requests = append(report.Requests, rc)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
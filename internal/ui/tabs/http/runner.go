@@ -0,0 +1,256 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"phantom/internal/ui/components/styles"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// envRefName matches {{name}} references that can be substituted. Names
+// may hold - and . as well, as Postman and OpenAPI names often do.
+var envRefName = regexp.MustCompile(`\{\{([A-Za-z0-9_.-]+)\}\}`)
+
+// maxSubstituteDepth bounds how deeply variables may refer to each other,
+// so that cycles such as a = {{b}}, b = {{a}} stop.
+const maxSubstituteDepth = 10
+
+// Substitute replaces {{name}} references with values from env, again and
+// again while the values themselves hold references, so that variables can
+// be built from others. Unknown names are left as they are.
+func Substitute(input string, env map[string]string) string {
+	for range maxSubstituteDepth {
+		out := envRefName.ReplaceAllStringFunc(input, func(s string) string {
+			if val, ok := env[envRefName.FindStringSubmatch(s)[1]]; ok {
+				return val
+			}
+			return s
+		})
+		if out == input {
+			break
+		}
+		input = out
+	}
+	return input
+}
+
+// Timeouts limit how long requests may take; zero means no limit.
+type Timeouts struct {
+	Connect time.Duration // to connect, TLS handshake included
+	Total   time.Duration // for the whole exchange, redirects and auth round trips included
+}
+
+// DefaultTimeouts apply unless config.lua sets others.
+var DefaultTimeouts = Timeouts{Connect: 10 * time.Second, Total: time.Minute}
+
+// Apply gives item the timeouts it does not set itself.
+func (t Timeouts) Apply(item RequestItem) RequestItem {
+	if item.Timeout == 0 {
+		item.Timeout = t.Total
+	}
+	if item.ConnectTimeout == 0 {
+		item.ConnectTimeout = t.Connect
+	}
+	return item
+}
+
+// Execute substitutes env into item and sends it with its Auth section,
+// within its timeouts.
+func Execute(ctx context.Context, item RequestItem, env map[string]string) (*Response, error) {
+	return execute(ctx, item, env, nil)
+}
+
+// execute is Execute, streaming the response through stream if it is a
+// streaming one. The total timeout of such a response ends once it starts.
+func execute(ctx context.Context, item RequestItem, env map[string]string, stream *responseStream) (*Response, error) {
+	if item.Method == MethodWS {
+		return nil, errors.New("WebSocket requests can only be opened in the HTTP tab")
+	}
+	if item.Timeout > 0 {
+		var cancel context.CancelFunc
+		if stream != nil {
+			ctx, cancel = stream.withTimeout(ctx, item.Timeout)
+		} else {
+			ctx, cancel = context.WithTimeoutCause(ctx, item.Timeout, fmt.Errorf("%w after %s", ErrTimeout, item.Timeout))
+		}
+		defer cancel()
+	}
+	header, err := ParseHeaders(Substitute(item.Headers, env))
+	if err != nil {
+		return nil, err
+	}
+	encodeBasicAuth(header)
+	auth, err := ParseAuth(item.Auth)
+	if err != nil {
+		return nil, err
+	}
+	dir := ""
+	if item.Source != "" {
+		dir = filepath.Dir(item.Source)
+	}
+	method := item.Method
+	var body []byte
+	var contentType string
+	if item.Method == MethodGraphQL {
+		method, contentType = http.MethodPost, "application/json"
+		body, err = graphQLBody(Substitute(item.Body, env), Substitute(item.Variables, env))
+		if header.Get("Accept") == "" {
+			header.Set("Accept", "application/graphql-response+json, application/json")
+		}
+	} else {
+		body, contentType, err = buildBody(item.BodyMode, Substitute(item.Body, env), dir, header)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Multipart boundaries are generated, so that Content-Type always wins.
+	if contentType != "" && (header.Get("Content-Type") == "" || item.BodyMode == BodyMultipart) {
+		header.Set("Content-Type", contentType)
+	}
+	return auth.Substitute(env).do(ctx, Request{
+		Method: method,
+		URL:    Substitute(item.URL, env),
+		Header: header,
+		Body:   body,
+
+		ConnectTimeout: item.ConnectTimeout,
+		stream:         stream,
+	})
+}
+
+// TestCase is the outcome of running one request of a collection.
+type TestCase struct {
+	Request  RequestItem
+	Status   int
+	Duration time.Duration
+	Err      error
+	Results  []AssertionResult
+	Captured map[string]string
+}
+
+// Passed reports whether the request was sent and all its checks passed.
+func (c TestCase) Passed() bool {
+	return c.Err == nil && countPassed(c.Results) == len(c.Results)
+}
+
+// RunRequest sends item, checks its assertions and extracts its captures.
+func RunRequest(ctx context.Context, item RequestItem, env map[string]string) TestCase {
+	c := TestCase{Request: item}
+	start := time.Now()
+	resp, err := Execute(ctx, item, env)
+	if err != nil {
+		c.Err, c.Duration = err, time.Since(start)
+		return c
+	}
+	c.Status, c.Duration = resp.StatusCode, resp.Timing.Total
+	captured, failed := item.Capture(resp)
+	c.Results, c.Captured = append(item.Check(resp), failed...), captured
+	return c
+}
+
+// collectionRun is a collection being run by the runner, one request at a time.
+type collectionRun struct {
+	Items   []RequestItem
+	Cases   []TestCase
+	Env     map[string]string
+	Start   time.Time
+	End     time.Time
+	Stopped bool // cancelled before its last request
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc // cancels the request in flight with ErrCancelled
+}
+
+func newCollectionRun(items []RequestItem, env map[string]string) *collectionRun {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	return &collectionRun{Items: items, Env: env, Start: time.Now(), ctx: ctx, cancel: cancel}
+}
+
+func (r *collectionRun) done() bool { return r.Stopped || len(r.Cases) == len(r.Items) }
+
+// RunStepMsg carries the result of one request of a collection run.
+type RunStepMsg struct {
+	Case TestCase
+}
+
+// runStep sends the next request of the run.
+func (r *collectionRun) runStep() tea.Cmd {
+	ctx, item, env := r.ctx, r.Items[len(r.Cases)], r.Env
+	return func() tea.Msg {
+		return RunStepMsg{Case: RunRequest(ctx, item, env)}
+	}
+}
+
+// renderTestCases draws cases as a tree grouped by request group, with the
+// checks of each request below it.
+func renderTestCases(cases []TestCase) string {
+	var b strings.Builder
+	var groups []string
+	byGroup := map[string][]TestCase{}
+	for _, c := range cases {
+		if _, ok := byGroup[c.Request.Group]; !ok {
+			groups = append(groups, c.Request.Group)
+		}
+		byGroup[c.Request.Group] = append(byGroup[c.Request.Group], c)
+	}
+	for _, g := range groups {
+		indent := ""
+		if g != "" {
+			b.WriteString(styles.BarHeaderStyle.Render(g) + "\n")
+			indent = "  "
+		}
+		for i, c := range byGroup[g] {
+			branch, stem := "├─ ", "│  "
+			if i == len(byGroup[g])-1 {
+				branch, stem = "└─ ", "   "
+			}
+			outcome := fmt.Sprintf("%d · %s", c.Status, c.Duration.Round(time.Millisecond))
+			switch {
+			case errors.Is(c.Err, ErrCancelled) || errors.Is(c.Err, ErrTimeout):
+				outcome = styles.ErrorStyle.Render("Request " + c.Err.Error())
+			case c.Err != nil:
+				outcome = styles.ErrorStyle.Render(c.Err.Error())
+			}
+			fmt.Fprintf(&b, "%s%s%s %s %s  %s\n", indent, branch, passMark(c.Passed()), c.Request.Method, c.Request.Name, outcome)
+			for _, r := range c.Results {
+				fmt.Fprintf(&b, "%s%s   %s\n", indent, stem, renderResult(r))
+			}
+		}
+	}
+	return b.String()
+}
+
+func renderResult(r AssertionResult) string {
+	line := passMark(r.Passed) + " " + r.Name
+	if r.Message != "" {
+		line += styles.ErrorStyle.Render(": " + r.Message)
+	}
+	return line
+}
+
+func passMark(passed bool) string {
+	if passed {
+		return styles.SuccessStyle.Render("✓")
+	}
+	return styles.ErrorStyle.Render("✗")
+}
+
+// SummarizeCases counts the passed and failed cases of a run.
+func SummarizeCases(cases []TestCase) (passed, failed int) {
+	for _, c := range cases {
+		if c.Passed() {
+			passed++
+		} else {
+			failed++
+		}
+	}
+	return passed, failed
+}
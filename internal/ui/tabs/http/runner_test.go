@@ -0,0 +1,72 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestCollectionRunCancel(t *testing.T) {
+	arrived := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		cancel func(m Model) Model
+	}{
+		{"ctrl+c", func(m Model) Model {
+			m, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+			return m
+		}},
+		{"esc", func(m Model) Model {
+			m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+			return m
+		}},
+		{"send", func(m Model) Model {
+			m.send()
+			return m
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_DATA_HOME", t.TempDir())
+			m := New()
+			m.Collections.SetItems([]list.Item{
+				RequestItem{Name: "slow", Method: "GET", URL: srv.URL + "/slow"},
+				RequestItem{Name: "never", Method: "GET", URL: srv.URL + "/never"},
+			})
+			step := m.startRun()
+			if step == nil || !m.Running() {
+				t.Fatal("the run did not start")
+			}
+			run := m.run
+			result := make(chan tea.Msg)
+			go func() { result <- step() }()
+			<-arrived
+
+			m = tt.cancel(m)
+			msg := (<-result).(RunStepMsg)
+			if !errors.Is(msg.Case.Err, ErrCancelled) {
+				t.Errorf("step error = %v, want %v", msg.Case.Err, ErrCancelled)
+			}
+			if m.run == nil { // send ends the run
+				return
+			}
+			m, cmd := m.Update(msg)
+			if cmd != nil {
+				t.Error("the run went on to the next request")
+			}
+			if m.Running() || !run.Stopped || len(run.Cases) != 1 {
+				t.Errorf("run running %v, stopped %v with %d cases; want it stopped after 1", m.Running(), run.Stopped, len(run.Cases))
+			}
+		})
+	}
+}
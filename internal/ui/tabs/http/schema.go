@@ -0,0 +1,207 @@
+package http
+
+import (
+	"strings"
+
+	"phantom/internal/ui/components/styles"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// schemaRow is one visible line of the schema browser: a root operation
+// type, or a field, argument or enum value under an expanded parent.
+type schemaRow struct {
+	Path       string // e.g. "query.user.posts", the key of expanded
+	Depth      int
+	Name       string
+	Type       string // as written in queries, e.g. [Post!]!
+	Named      string // the type to expand into
+	Desc       string
+	Expandable bool
+}
+
+// schemaBrowser is the collapsible tree of a GraphQL schema shown in place
+// of the lists.
+type schemaBrowser struct {
+	expanded map[string]bool
+	cursor   int
+	offset   int
+}
+
+// schemaRows flattens the expanded parts of schema into rows.
+func schemaRows(schema *gqlSchema, expanded map[string]bool) []schemaRow {
+	var rows []schemaRow
+	var add func(path, typeName string, depth int)
+	add = func(path, typeName string, depth int) {
+		t := schema.typ(typeName)
+		if t == nil || depth > 32 {
+			return
+		}
+		var children []schemaRow
+		for _, f := range t.Fields {
+			children = append(children, schemaRow{Name: f.Name + formatArgs(f.Args), Type: f.Type.String(), Named: f.Type.named(), Desc: f.Description})
+		}
+		for _, f := range t.InputFields {
+			children = append(children, schemaRow{Name: f.Name, Type: f.Type.String(), Named: f.Type.named(), Desc: f.Description})
+		}
+		for _, v := range t.EnumValues {
+			children = append(children, schemaRow{Name: v.Name, Desc: v.Description})
+		}
+		for _, c := range children {
+			c.Path, c.Depth = path+"."+strings.SplitN(c.Name, "(", 2)[0], depth
+			if nt := schema.typ(c.Named); nt != nil {
+				c.Expandable = len(nt.Fields)+len(nt.InputFields)+len(nt.EnumValues) > 0
+			}
+			rows = append(rows, c)
+			if c.Expandable && expanded[c.Path] {
+				add(c.Path, c.Named, depth+1)
+			}
+		}
+	}
+	for _, op := range []string{"query", "mutation", "subscription"} {
+		name := schema.rootType(op)
+		if name == "" {
+			continue
+		}
+		desc := ""
+		if t := schema.typ(name); t != nil {
+			desc = t.Description
+		}
+		rows = append(rows, schemaRow{Path: op, Name: op, Type: name, Named: name, Desc: desc, Expandable: true})
+		if expanded[op] {
+			add(op, name, 1)
+		}
+	}
+	return rows
+}
+
+// formatArgs renders the arguments of a field as in its definition.
+func formatArgs(args []gqlInputValue) string {
+	if len(args) == 0 {
+		return ""
+	}
+	parts := make([]string, len(args))
+	for i, a := range args {
+		parts[i] = a.Name + ": " + a.Type.String()
+		if a.DefaultValue != nil {
+			parts[i] += " = " + *a.DefaultValue
+		}
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// openSchemaBrowser shows the schema browser, fetching the schema if needed.
+func (m *Model) openSchemaBrowser() tea.Cmd {
+	m.browsingSchema, m.inspecting = true, false
+	m.FocusedPane = 0
+	m.focus()
+	if m.schema() == nil {
+		return m.fetchSchema(false)
+	}
+	return nil
+}
+
+// updateSchemaBrowser handles keys while the schema browser replaces the lists.
+func (m *Model) updateSchemaBrowser(msg tea.KeyMsg) tea.Cmd {
+	schema := m.schema()
+	switch msg.String() {
+	case "esc":
+		m.browsingSchema = false
+		return nil
+	case "r": // Run the introspection query again
+		return m.fetchSchema(true)
+	}
+	if schema == nil {
+		return nil
+	}
+	b := &m.schemaTree
+	if b.expanded == nil {
+		b.expanded = make(map[string]bool)
+	}
+	rows := schemaRows(schema, b.expanded)
+	if len(rows) == 0 {
+		return nil
+	}
+	b.cursor = min(b.cursor, len(rows)-1)
+	row := rows[b.cursor]
+	switch msg.String() {
+	case "up", "k":
+		b.cursor = max(b.cursor-1, 0)
+	case "down", "j":
+		b.cursor = min(b.cursor+1, len(rows)-1)
+	case "enter", " ", "right", "l":
+		if row.Expandable {
+			b.expanded[row.Path] = !b.expanded[row.Path]
+		}
+	case "left", "h": // collapse, or go to the parent
+		if b.expanded[row.Path] {
+			delete(b.expanded, row.Path)
+			break
+		}
+		parent := row.Path[:max(strings.LastIndex(row.Path, "."), 0)]
+		for i, r := range rows {
+			if r.Path == parent {
+				b.cursor = i
+			}
+		}
+	case "i": // Insert the field into the query
+		if row.Depth > 0 && m.isGraphQL() {
+			m.Body.InsertString(strings.SplitN(row.Name, "(", 2)[0])
+			m.Notice = styles.SuccessStyle.Render("Inserted " + row.Name)
+		}
+	}
+	b.cursor = min(b.cursor, len(schemaRows(schema, b.expanded))-1)
+	if b.cursor < b.offset {
+		b.offset = b.cursor
+	}
+	if height := m.schemaHeight(); b.cursor >= b.offset+height {
+		b.offset = b.cursor - height + 1
+	}
+	return nil
+}
+
+// schemaHeight is how many rows the schema browser shows.
+func (m Model) schemaHeight() int {
+	return max(m.Height-8, 3)
+}
+
+// renderSchemaBrowser draws the rows from the scroll offset and the
+// description of the selected one.
+func (m Model) renderSchemaBrowser() string {
+	header := styles.ListHeaderStyle.Render("GraphQL schema")
+	schema := m.schema()
+	if schema == nil {
+		return header + "\n" + styles.HelpStyle.Render("No schema for this endpoint yet. R fetches it.")
+	}
+	b := m.schemaTree
+	rows := schemaRows(schema, b.expanded)
+	var out strings.Builder
+	out.WriteString(header + "\n")
+	width := max(m.Width/4-2, 10)
+	for i := b.offset; i < len(rows) && i < b.offset+m.schemaHeight(); i++ {
+		r := rows[i]
+		marker := "  "
+		if r.Expandable && b.expanded[r.Path] {
+			marker = "▾ "
+		} else if r.Expandable {
+			marker = "▸ "
+		}
+		line := strings.Repeat("  ", r.Depth) + marker + r.Name
+		if i == b.cursor {
+			if r.Type != "" {
+				line += ": " + r.Type
+			}
+			out.WriteString(styles.SelectedCellStyle.Render(truncate(line, width)) + "\n")
+			continue
+		}
+		if r.Type != "" {
+			line += ": " + styles.HelpStyle.Render(r.Type)
+		}
+		out.WriteString(lipgloss.NewStyle().MaxWidth(width).Render(line) + "\n")
+	}
+	if b.cursor < len(rows) && rows[b.cursor].Desc != "" {
+		out.WriteString(styles.HelpStyle.Render(truncate(rows[b.cursor].Desc, width)))
+	}
+	return out.String()
+}
@@ -0,0 +1,312 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SnippetLanguages are the targets the current request can be exported to.
+var SnippetLanguages = []string{"curl", "HTTPie", "Go", "Python"}
+
+// Snippet renders req as code in one of SnippetLanguages. The request is
+// expected to have its environment variables substituted already.
+func Snippet(lang string, req RequestItem) (string, error) {
+	if req.Method == MethodWS {
+		return "", errors.New("WebSocket connections cannot be exported")
+	}
+	if req.Method == MethodGraphQL {
+		body, err := graphQLBody(req.Body, req.Variables)
+		if err != nil {
+			return "", err
+		}
+		req.Method, req.Body, req.BodyMode = http.MethodPost, string(body), "json"
+	}
+	headers, err := parseHeaderFields(req.Headers)
+	if err != nil {
+		return "", err
+	}
+	headers = encodeBasicAuthField(headers)
+	if headers, req.URL, err = applyAuthFields(req.Auth, headers, req.URL); err != nil {
+		return "", err
+	}
+	req, headers = snippetBody(req, headers)
+	switch lang {
+	case "curl":
+		return curlSnippet(req, headers), nil
+	case "HTTPie":
+		return httpieSnippet(req, headers), nil
+	case "Go":
+		return goSnippet(req, headers), nil
+	case "Python":
+		return pythonSnippet(req, headers), nil
+	}
+	return "", fmt.Errorf("unknown snippet language %q", lang)
+}
+
+// encodeBasicAuthField applies encodeBasicAuth to ordered headers.
+func encodeBasicAuthField(headers []headerField) []headerField {
+	out := append([]headerField(nil), headers...)
+	for i, h := range out {
+		if strings.EqualFold(h.Name, "Authorization") {
+			out[i].Value = basicAuthValue(h.Value)
+		}
+	}
+	return out
+}
+
+// applyAuthFields adds the credentials of an auth line to ordered headers
+// and the URL. Digest and OAuth2 need a round trip, so they are left out.
+func applyAuthFields(spec string, headers []headerField, rawURL string) ([]headerField, string, error) {
+	auth, err := ParseAuth(spec)
+	if err != nil {
+		return nil, "", err
+	}
+	h := make(http.Header)
+	rawURL, _ = auth.apply(h, rawURL)
+	var out []headerField
+	for _, f := range headers {
+		if h.Get(f.Name) == "" {
+			out = append(out, f)
+		}
+	}
+	for name := range h {
+		out = append(out, headerField{Name: name, Value: h.Get(name)})
+	}
+	return out, rawURL, nil
+}
+
+// snippetBody turns form bodies and Content-Type presets into a raw body and
+// headers. Multipart and file bodies are left to each language, which has
+// its own way of reading files.
+func snippetBody(req RequestItem, headers []headerField) (RequestItem, []headerField) {
+	hasContentType := false
+	for _, h := range headers {
+		hasContentType = hasContentType || strings.EqualFold(h.Name, "Content-Type")
+	}
+	switch req.BodyMode {
+	case BodyMultipart, BodyFile:
+		return req, headers
+	case BodyForm:
+		body, _, _ := buildBody(BodyForm, req.Body, "", nil)
+		req.Body = string(body)
+	}
+	if t := bodyContentTypes[req.BodyMode]; t != "" && !hasContentType && req.Body != "" {
+		headers = append(headers, headerField{Name: "Content-Type", Value: t})
+	}
+	req.BodyMode = ""
+	return req, headers
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@,+%", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func curlSnippet(req RequestItem, headers []headerField) string {
+	lines := []string{"curl " + shellQuote(req.URL)}
+	switch req.Method {
+	case "GET":
+	case "HEAD":
+		lines[0] = "curl -I " + shellQuote(req.URL)
+	default:
+		lines[0] = "curl -X " + req.Method + " " + shellQuote(req.URL)
+	}
+	for _, h := range headers {
+		lines = append(lines, "-H "+shellQuote(h.Name+": "+h.Value))
+	}
+	switch {
+	case req.BodyMode == BodyMultipart:
+		for _, f := range parseFormLines(req.Body, true) {
+			if f.File {
+				lines = append(lines, "-F "+shellQuote(f.Name+"=@"+f.Value))
+			} else {
+				lines = append(lines, "-F "+shellQuote(f.Name+"="+strings.Replace(f.Value, "< ", "<", 1)))
+			}
+		}
+	case req.BodyMode == BodyFile:
+		lines = append(lines, "--data-binary "+shellQuote("@"+strings.TrimSpace(req.Body)))
+	case req.Body != "":
+		lines = append(lines, "--data-raw "+shellQuote(req.Body))
+	}
+	return strings.Join(lines, " \\\n  ")
+}
+
+func httpieSnippet(req RequestItem, headers []headerField) string {
+	lines := []string{"http " + req.Method + " " + shellQuote(req.URL)}
+	for _, h := range headers {
+		lines = append(lines, shellQuote(h.Name+":"+h.Value))
+	}
+	switch {
+	case req.BodyMode == BodyMultipart:
+		lines[0] = "http --multipart " + req.Method + " " + shellQuote(req.URL)
+		for _, f := range parseFormLines(req.Body, true) {
+			if f.File {
+				lines = append(lines, shellQuote(f.Name+"@"+f.Value))
+			} else if path, ok := strings.CutPrefix(f.Value, "< "); ok {
+				lines = append(lines, shellQuote(f.Name+"=@"+path))
+			} else {
+				lines = append(lines, shellQuote(f.Name+"="+f.Value))
+			}
+		}
+	case req.BodyMode == BodyFile:
+		lines = append(lines, "< "+shellQuote(strings.TrimSpace(req.Body)))
+	case req.Body != "":
+		lines = append(lines, "--raw "+shellQuote(req.Body))
+	}
+	return strings.Join(lines, " \\\n  ")
+}
+
+// goString quotes s as a Go string literal, preferring a raw string for
+// multi-line text.
+func goString(s string) string {
+	if strings.Contains(s, "\n") && !strings.Contains(s, "`") && !strings.Contains(s, "\r") {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
+
+func goSnippet(req RequestItem, headers []headerField) string {
+	var b strings.Builder
+	imports := []string{`"fmt"`, `"io"`, `"net/http"`}
+	body := "nil"
+	var setup string // code building the body
+	switch {
+	case req.BodyMode == BodyMultipart:
+		fields := parseFormLines(req.Body, true)
+		imports = append(imports, `"bytes"`, `"mime/multipart"`)
+		for _, f := range fields {
+			if f.File {
+				imports = append(imports, `"os"`)
+				break
+			}
+		}
+		setup, body = goMultipart(fields), "&body"
+	case req.BodyMode == BodyFile:
+		imports = append(imports, `"os"`)
+		setup = fmt.Sprintf("\tbody, err := os.Open(%s)\n\tif err != nil {\n\t\tpanic(err)\n\t}\n\tdefer body.Close()\n\n", goString(strings.TrimSpace(req.Body)))
+		body = "body"
+	case req.Body != "":
+		imports = append(imports, `"strings"`)
+		body = "strings.NewReader(" + goString(req.Body) + ")"
+	}
+	sort.Strings(imports)
+	b.WriteString("package main\n\nimport (\n")
+	for _, imp := range imports {
+		b.WriteString("\t" + imp + "\n")
+	}
+	b.WriteString(")\n\nfunc main() {\n")
+	b.WriteString(setup)
+	fmt.Fprintf(&b, "\treq, err := http.NewRequest(%s, %s, %s)\n", strconv.Quote(req.Method), goString(req.URL), body)
+	b.WriteString("\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+	for _, h := range headers {
+		fmt.Fprintf(&b, "\treq.Header.Add(%s, %s)\n", strconv.Quote(h.Name), goString(h.Value))
+	}
+	if req.BodyMode == BodyMultipart {
+		b.WriteString("\treq.Header.Set(\"Content-Type\", form.FormDataContentType())\n")
+	}
+	b.WriteString(`
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(resp.Status)
+	fmt.Println(string(data))
+}
+`)
+	return b.String()
+}
+
+// goMultipart writes the code building a multipart body into body and form.
+func goMultipart(fields []formField) string {
+	var b strings.Builder
+	b.WriteString("\tvar body bytes.Buffer\n\tform := multipart.NewWriter(&body)\n")
+	for _, f := range fields {
+		if !f.File {
+			fmt.Fprintf(&b, "\tform.WriteField(%s, %s)\n", goString(f.Name), goString(f.Value))
+			continue
+		}
+		path, _, _ := strings.Cut(f.Value, ";type=")
+		fmt.Fprintf(&b, "\tif data, err := os.ReadFile(%s); err != nil {\n\t\tpanic(err)\n", goString(path))
+		fmt.Fprintf(&b, "\t} else if part, err := form.CreateFormFile(%s, %s); err != nil {\n\t\tpanic(err)\n", goString(f.Name), goString(fileBase(path)))
+		b.WriteString("\t} else {\n\t\tpart.Write(data)\n\t}\n")
+	}
+	b.WriteString("\tform.Close()\n\n")
+	return b.String()
+}
+
+// pyString quotes s as a Python string literal. JSON string escapes are
+// valid Python, so a JSON-encoded string works as is.
+func pyString(s string) string {
+	if strings.Contains(s, "\n") && !strings.Contains(s, `"""`) && !strings.Contains(s, `\`) && !strings.HasSuffix(s, `"`) {
+		return `"""` + s + `"""`
+	}
+	q, _ := json.Marshal(s)
+	return string(q)
+}
+
+func pythonSnippet(req RequestItem, headers []headerField) string {
+	var b strings.Builder
+	b.WriteString("import requests\n\n")
+	fmt.Fprintf(&b, "url = %s\n", pyString(req.URL))
+	args := ""
+	if len(headers) > 0 {
+		// requests takes a dict, so repeated headers are folded into one
+		var names []string
+		values := map[string][]string{}
+		for _, h := range headers {
+			if _, ok := values[h.Name]; !ok {
+				names = append(names, h.Name)
+			}
+			values[h.Name] = append(values[h.Name], h.Value)
+		}
+		b.WriteString("headers = {\n")
+		for _, n := range names {
+			fmt.Fprintf(&b, "    %s: %s,\n", pyString(n), pyString(strings.Join(values[n], ", ")))
+		}
+		b.WriteString("}\n")
+		args += ", headers=headers"
+	}
+	switch {
+	case req.BodyMode == BodyMultipart:
+		var data, files []string
+		for _, f := range parseFormLines(req.Body, true) {
+			if f.File {
+				path, _, _ := strings.Cut(f.Value, ";type=")
+				files = append(files, fmt.Sprintf("    %s: open(%s, \"rb\"),", pyString(f.Name), pyString(path)))
+			} else {
+				data = append(data, fmt.Sprintf("    %s: %s,", pyString(f.Name), pyString(f.Value)))
+			}
+		}
+		if len(data) > 0 {
+			fmt.Fprintf(&b, "data = {\n%s\n}\n", strings.Join(data, "\n"))
+			args += ", data=data"
+		}
+		fmt.Fprintf(&b, "files = {\n%s\n}\n", strings.Join(files, "\n"))
+		args += ", files=files"
+	case req.BodyMode == BodyFile:
+		fmt.Fprintf(&b, "data = open(%s, \"rb\")\n", pyString(strings.TrimSpace(req.Body)))
+		args += ", data=data"
+	case req.Body != "":
+		fmt.Fprintf(&b, "data = %s\n", pyString(req.Body))
+		args += ", data=data"
+	}
+	fmt.Fprintf(&b, "\nresponse = requests.request(%s, url%s)\n", pyString(req.Method), args)
+	b.WriteString("print(response.status_code)\nprint(response.text)\n")
+	return b.String()
+}
@@ -0,0 +1,30 @@
+package http
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGoSnippetImports(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    RequestItem
+		wantOS bool
+	}{
+		{"multipart fields", RequestItem{Method: "POST", URL: "https://example.com", BodyMode: BodyMultipart, Body: "a=b\nc=d"}, false},
+		{"multipart file", RequestItem{Method: "POST", URL: "https://example.com", BodyMode: BodyMultipart, Body: "a=b\nupload=@./photo.png"}, true},
+		{"file body", RequestItem{Method: "PUT", URL: "https://example.com", BodyMode: BodyFile, Body: "./data.bin"}, true},
+		{"raw body", RequestItem{Method: "POST", URL: "https://example.com", Body: `{"a": 1}`}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := goSnippet(tt.req, nil)
+			if got := strings.Contains(src, "\t\"os\"\n"); got != tt.wantOS {
+				t.Errorf("imports os = %v, want %v in:\n%s", got, tt.wantOS, src)
+			}
+			if got := strings.Contains(src, "os."); got != tt.wantOS {
+				t.Errorf("uses os = %v, want %v in:\n%s", got, tt.wantOS, src)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"phantom/internal/ui/components/styles"
+	"phantom/internal/utils"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Kinds of streaming responses, see streamKind.
+const (
+	StreamSSE    = "SSE"
+	StreamNDJSON = "NDJSON"
+	StreamLines  = "lines"
+)
+
+const maxStreamEvents = 1000
+
+// streamTypes are the Content-Types streamed without being asked to.
+var streamTypes = map[string]string{
+	"text/event-stream":          StreamSSE,
+	"application/x-ndjson":       StreamNDJSON,
+	"application/ndjson":         StreamNDJSON,
+	"application/jsonl":          StreamNDJSON,
+	"application/x-jsonlines":    StreamNDJSON,
+	"application/stream+json":    StreamNDJSON,
+	"application/json-seq":       StreamNDJSON,
+	"application/x-json-stream":  StreamNDJSON,
+	"application/jsonlines+json": StreamNDJSON,
+}
+
+// streamKind tells how to stream a response with header, or "" to read it
+// whole. force streams any response line by line.
+func streamKind(header http.Header, force bool) string {
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if kind, ok := streamTypes[mediaType]; ok {
+		return kind
+	}
+	if force {
+		return StreamLines
+	}
+	return ""
+}
+
+// StreamEvent is one server-sent event, or one line of other streams.
+type StreamEvent struct {
+	Time  time.Time
+	Event string // SSE event type; empty for "message" and for other streams
+	ID    string
+	Data  string
+}
+
+// responseStream carries a streaming response from Do to the Model while it
+// arrives. The outcome of the request is delivered through done once
+// updates is closed.
+type responseStream struct {
+	Force   bool // stream whatever the Content-Type, see RequestItem.Stream
+	updates chan streamUpdate
+	done    chan HTTPResponseMsg
+	timer   *time.Timer // the total timeout, which stops once the response starts
+	// Set before updates is closed.
+	stopped bool  // cancelled after the response started
+	err     error // why the stream broke off, if it did
+}
+
+// streamUpdate is what Do reports of a streaming response: its head once,
+// then the events and raw text read from its body.
+type streamUpdate struct {
+	head  *Response
+	kind  string
+	event *StreamEvent
+	raw   string
+}
+
+// StreamMsg carries the updates of a streaming response.
+type StreamMsg struct {
+	Stream *responseStream
+	Head   *Response // set once the status and headers arrived
+	Kind   string
+	Events []StreamEvent
+	Raw    string
+}
+
+func newResponseStream(force bool) *responseStream {
+	return &responseStream{Force: force, updates: make(chan streamUpdate, 256), done: make(chan HTTPResponseMsg, 1)}
+}
+
+// withTimeout limits how long s may take to start, with the cause Execute
+// uses for its total timeout. Once the response starts it may go on for as
+// long as it likes.
+func (s *responseStream) withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	s.timer = time.AfterFunc(d, func() { cancel(fmt.Errorf("%w after %s", ErrTimeout, d)) })
+	return ctx, func() { s.timer.Stop(); cancel(nil) }
+}
+
+// read streams body, the response to resp, and returns as much of it as
+// fits in maxResponseBody. Cancelling ctx stops the stream without an error.
+func (s *responseStream) read(ctx context.Context, resp *Response, kind string, body io.Reader) []byte {
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	head := *resp
+	s.updates <- streamUpdate{head: &head, kind: kind}
+
+	var raw []byte
+	var event StreamEvent
+	var data []string
+	r := bufio.NewReader(body)
+	for {
+		line, err := r.ReadString('\n')
+		if line != "" {
+			if len(raw)+len(line) <= maxResponseBody {
+				raw = append(raw, line...)
+			}
+			s.updates <- streamUpdate{raw: line}
+		}
+		text := strings.TrimRight(line, "\r\n")
+		switch {
+		case kind != StreamSSE:
+			if strings.TrimSpace(text) != "" {
+				s.updates <- streamUpdate{event: &StreamEvent{Time: time.Now(), Data: text}}
+			}
+		case text == "" && (strings.HasSuffix(line, "\n") || err != nil):
+			// A blank line dispatches the event, if it has data.
+			if data != nil {
+				e := event
+				e.Time, e.Data = time.Now(), strings.Join(data, "\n")
+				s.updates <- streamUpdate{event: &e}
+			}
+			event, data = StreamEvent{ID: event.ID}, nil // the last id carries over
+		case strings.HasPrefix(text, ":"): // comment, often a keep-alive
+		default:
+			field, value, _ := strings.Cut(text, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "event":
+				event.Event = value
+			case "data":
+				data = append(data, value)
+			case "id":
+				event.ID = value
+			}
+		}
+		if err != nil {
+			if errors.Is(context.Cause(ctx), ErrCancelled) {
+				s.stopped = true
+			} else if err != io.EOF {
+				s.err = requestError(ctx, err, 0)
+			}
+			return raw
+		}
+	}
+}
+
+// wait returns a command that delivers the next updates of s, or the
+// outcome of its request once it is over.
+func (s *responseStream) wait() tea.Cmd {
+	return func() tea.Msg {
+		u, ok := <-s.updates
+		if !ok {
+			return <-s.done
+		}
+		msg := StreamMsg{Stream: s}
+		msg.add(u)
+		// Coalesce whatever else is already buffered to keep redraws down.
+		for len(msg.Events) < 500 {
+			select {
+			case u, ok := <-s.updates:
+				if !ok {
+					return msg
+				}
+				msg.add(u)
+			default:
+				return msg
+			}
+		}
+		return msg
+	}
+}
+
+func (msg *StreamMsg) add(u streamUpdate) {
+	switch {
+	case u.head != nil:
+		msg.Head, msg.Kind = u.head, u.kind
+	case u.event != nil:
+		msg.Events = append(msg.Events, *u.event)
+	default:
+		msg.Raw += u.raw
+	}
+}
+
+// logStream appends events to the event list, dropping the oldest ones past
+// maxStreamEvents.
+func (m *Model) logStream(events ...StreamEvent) {
+	m.streamCount += len(events)
+	m.streamEvents = append(m.streamEvents, events...)
+	if over := len(m.streamEvents) - maxStreamEvents; over > 0 {
+		m.streamEvents = m.streamEvents[over:]
+	}
+}
+
+// refreshStream redraws the Pretty or Raw view of a streaming response. It
+// keeps following the end of the stream unless scrolled away from it, or
+// always with jump.
+func (m *Model) refreshStream(jump bool) {
+	if m.streamKind == "" || m.ResponseViewTab > 1 || m.isWS() {
+		return
+	}
+	follow := jump || m.Response.AtBottom()
+	if m.ResponseViewTab == 0 {
+		m.Response.SetContent(m.renderStream())
+	} else {
+		m.Response.SetContent(m.ResponseBody)
+	}
+	if follow {
+		m.Response.GotoBottom()
+	}
+}
+
+// renderStream draws the events of the streaming response: server-sent
+// events under a header line with their JSON data pretty-printed, and the
+// lines of other streams as they are.
+func (m Model) renderStream() string {
+	var b strings.Builder
+	if dropped := m.streamCount - len(m.streamEvents); dropped > 0 {
+		b.WriteString(styles.HelpStyle.Render(fmt.Sprintf("%d earlier events not shown", dropped)) + "\n")
+	}
+	for _, e := range m.streamEvents {
+		line := styles.HelpStyle.Render(e.Time.Format("15:04:05.000"))
+		if e.Event != "" {
+			line += " " + styles.FocusedInputStyle.Render(e.Event)
+		}
+		if e.ID != "" {
+			line += " " + styles.HelpStyle.Render("#"+e.ID)
+		}
+		if m.streamKind == StreamSSE {
+			fmt.Fprintf(&b, "%s\n%s\n", line, utils.PrettyPrintJSON(e.Data))
+		} else {
+			fmt.Fprintf(&b, "%s %s\n", line, e.Data)
+		}
+	}
+	if m.streamErr != "" {
+		b.WriteString(styles.ErrorStyle.Render("· "+m.streamErr) + "\n")
+	}
+	if m.streamCount == 0 {
+		b.WriteString(styles.HelpStyle.Render("Waiting for events…"))
+	}
+	return b.String()
+}
+
+// streamSummary is the event counter of the response header.
+func (m Model) streamSummary() string {
+	s := fmt.Sprintf(" · %s · %d events", m.streamKind, m.streamCount)
+	if m.streamKind == StreamLines {
+		s = fmt.Sprintf(" · %d lines", m.streamCount)
+	}
+	switch {
+	case m.Sending:
+		return s + " " + m.Spinner.View()
+	case m.streamStopped:
+		return s + " · stopped"
+	}
+	return s
+}
@@ -0,0 +1,91 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStreamKind(t *testing.T) {
+	tests := []struct {
+		contentType string
+		force       bool
+		want        string
+	}{
+		{"text/event-stream", false, StreamSSE},
+		{"text/event-stream; charset=utf-8", false, StreamSSE},
+		{"application/x-ndjson", false, StreamNDJSON},
+		{"application/jsonl", true, StreamNDJSON},
+		{"application/json", false, ""},
+		{"application/json", true, StreamLines},
+		{"", false, ""},
+	}
+	for _, tt := range tests {
+		header := http.Header{"Content-Type": {tt.contentType}}
+		if got := streamKind(header, tt.force); got != tt.want {
+			t.Errorf("streamKind(%q, %v) = %q, want %q", tt.contentType, tt.force, got, tt.want)
+		}
+	}
+}
+
+func TestStreamRead(t *testing.T) {
+	tests := []struct {
+		name string
+		kind string
+		body string
+		want []StreamEvent // without Time
+	}{
+		{"sse", StreamSSE, "data: one\n\nevent: update\nid: 7\ndata: {\"a\":1}\n\n", []StreamEvent{
+			{Data: "one"},
+			{Event: "update", ID: "7", Data: `{"a":1}`},
+		}},
+		{"sse multiline data", StreamSSE, "data: a\ndata: b\n\n", []StreamEvent{{Data: "a\nb"}}},
+		{"sse crlf and comments", StreamSSE, ": keep-alive\r\n\r\ndata:x\r\n\r\n", []StreamEvent{{Data: "x"}}},
+		{"sse id carries over", StreamSSE, "id: 1\ndata: a\n\nevent: e\ndata: b\n\n", []StreamEvent{
+			{ID: "1", Data: "a"},
+			{Event: "e", ID: "1", Data: "b"},
+		}},
+		{"sse ends without blank line", StreamSSE, "data: a\n\ndata: b\n", []StreamEvent{{Data: "a"}, {Data: "b"}}},
+		{"sse ends mid-line", StreamSSE, "data: a\n\ndata: b", []StreamEvent{{Data: "a"}}},
+		{"sse no data", StreamSSE, "event: ping\n\n", nil},
+		{"ndjson", StreamNDJSON, "{\"n\":1}\n\n{\"n\":2}\n{\"n\":3}", []StreamEvent{
+			{Data: `{"n":1}`},
+			{Data: `{"n":2}`},
+			{Data: `{"n":3}`},
+		}},
+		{"lines", StreamLines, "a\r\nb\n", []StreamEvent{{Data: "a"}, {Data: "b"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newResponseStream(false)
+			raw := s.read(context.Background(), &Response{StatusCode: 200}, tt.kind, strings.NewReader(tt.body))
+			close(s.updates)
+			if string(raw) != tt.body {
+				t.Errorf("raw = %q, want %q", raw, tt.body)
+			}
+			if s.err != nil || s.stopped {
+				t.Errorf("stream ended with err %v, stopped %v", s.err, s.stopped)
+			}
+
+			var msg StreamMsg
+			for u := range s.updates {
+				msg.add(u)
+			}
+			if msg.Head == nil || msg.Kind != tt.kind || msg.Raw != tt.body {
+				t.Errorf("head %v, kind %q, raw %q; want the head, %q and the body", msg.Head, msg.Kind, msg.Raw, tt.kind)
+			}
+			var got []StreamEvent
+			for _, e := range msg.Events {
+				if e.Time.IsZero() {
+					t.Errorf("event %q has no time", e.Data)
+				}
+				got = append(got, StreamEvent{Event: e.Event, ID: e.ID, Data: e.Data})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,405 @@
+package http
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"phantom/internal/ui/components/styles"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// kvRow is one row of a key/value table.
+type kvRow struct {
+	Key, Value string
+	Enabled    bool
+}
+
+// kvTable edits key/value rows, such as query parameters or headers, with
+// a toggle per row. It does not know what the rows mean: the Model converts
+// them to and from the URL and the Headers text.
+type kvTable struct {
+	Title   string
+	Rows    []kvRow
+	cursor  int // selected row
+	col     int // 0: key, 1: value
+	offset  int // first visible row
+	height  int // visible rows
+	width   int
+	editing bool
+	adding  bool // the row being edited was just added
+	input   textinput.Model
+	sep     string             // what separates a pasted "key<sep>value" in the key cell
+	empty   string             // shown when there are no rows
+	check   func(kvRow) string // returns why a row is invalid, if it is
+}
+
+func newKVTable(title, sep, empty string, height int, check func(kvRow) string) kvTable {
+	input := textinput.New()
+	input.Prompt = ""
+	return kvTable{Title: title, height: height, input: input, sep: sep, empty: empty, check: check}
+}
+
+// setRows replaces the rows, keeping the cursor in range.
+func (t *kvTable) setRows(rows []kvRow) {
+	t.Rows = rows
+	t.editing, t.adding = false, false
+	t.input.Blur()
+	t.cursor = max(0, min(t.cursor, len(rows)-1))
+	t.scroll()
+}
+
+func (t *kvTable) setWidth(w int) {
+	t.width = w
+	t.input.Width = w / 2
+}
+
+// update handles a key while the table is focused. handled is false for
+// keys the table has no use for, such as up on the first row, so the
+// caller can move the focus instead. changed reports edited rows.
+func (t *kvTable) update(msg tea.KeyMsg) (changed, handled bool, cmd tea.Cmd) {
+	if t.editing {
+		switch msg.String() {
+		case "enter":
+			t.commit()
+			if t.col == 0 { // go on with the value
+				t.col = 1
+				return true, true, t.edit()
+			}
+			return true, true, nil
+		case "esc":
+			t.editing = false
+			t.input.Blur()
+			if t.adding && t.Rows[t.cursor] == (kvRow{Enabled: true}) {
+				t.remove()
+			}
+			t.adding = false
+			return false, true, nil
+		}
+		t.input, cmd = t.input.Update(msg)
+		return false, true, cmd
+	}
+
+	switch msg.String() {
+	case "up", "k":
+		if t.cursor == 0 {
+			return false, false, nil
+		}
+		t.cursor--
+	case "down", "j":
+		if t.cursor >= len(t.Rows)-1 {
+			return false, false, nil
+		}
+		t.cursor++
+	case "left", "h", "right", "l":
+		t.col = 1 - t.col
+	case "a", "+": // add a row below the cursor
+		at := min(t.cursor+1, len(t.Rows))
+		t.Rows = append(t.Rows[:at], append([]kvRow{{Enabled: true}}, t.Rows[at:]...)...)
+		t.cursor, t.col, t.adding = at, 0, true
+		t.scroll()
+		return false, true, t.edit()
+	case "enter", "e":
+		if len(t.Rows) == 0 {
+			t.Rows, t.cursor, t.col, t.adding = []kvRow{{Enabled: true}}, 0, 0, true
+		}
+		return false, true, t.edit()
+	case " ", "x":
+		if len(t.Rows) == 0 {
+			return false, true, nil
+		}
+		t.Rows[t.cursor].Enabled = !t.Rows[t.cursor].Enabled
+		return true, true, nil
+	case "d", "delete":
+		if len(t.Rows) == 0 {
+			return false, true, nil
+		}
+		t.remove()
+		return true, true, nil
+	default:
+		return false, false, nil
+	}
+	t.scroll()
+	return false, true, nil
+}
+
+// edit starts editing the selected cell.
+func (t *kvTable) edit() tea.Cmd {
+	t.editing = true
+	value := t.Rows[t.cursor].Key
+	if t.col == 1 {
+		value = t.Rows[t.cursor].Value
+	}
+	t.input.SetValue(value)
+	t.input.CursorEnd()
+	return t.input.Focus()
+}
+
+// commit stores the cell being edited. A "key: value" pasted into the key
+// cell of a headers table is split into both cells.
+func (t *kvTable) commit() {
+	t.editing = false
+	t.input.Blur()
+	value := strings.TrimSpace(t.input.Value())
+	row := &t.Rows[t.cursor]
+	if t.col == 1 {
+		row.Value = value
+		t.adding = false
+		return
+	}
+	row.Key = value
+	if k, v, ok := strings.Cut(value, t.sep); ok && t.sep != "" && row.Value == "" {
+		row.Key, row.Value = strings.TrimSpace(k), strings.TrimSpace(v)
+	}
+}
+
+// stopEditing commits an edit in progress, as when the table loses focus.
+// It reports whether the rows changed.
+func (t *kvTable) stopEditing() bool {
+	if !t.editing {
+		return false
+	}
+	t.commit()
+	t.adding = false
+	return true
+}
+
+func (t *kvTable) remove() {
+	t.Rows = append(t.Rows[:t.cursor], t.Rows[t.cursor+1:]...)
+	t.cursor = max(0, min(t.cursor, len(t.Rows)-1))
+	t.scroll()
+}
+
+// scroll keeps the cursor within the visible rows.
+func (t *kvTable) scroll() {
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if t.cursor >= t.offset+t.height {
+		t.offset = t.cursor - t.height + 1
+	}
+	t.offset = max(0, min(t.offset, len(t.Rows)-t.height))
+}
+
+// view draws the table. resolve substitutes variables into a value and
+// reports whether some of them are unknown; the result is shown next to
+// values that contain any.
+func (t kvTable) view(focused bool, resolve func(string) (string, bool)) string {
+	titleStyle := styles.BlurredInputStyle
+	if focused {
+		titleStyle = styles.FocusedInputStyle
+	}
+	var b strings.Builder
+	b.WriteString(titleStyle.Render(t.Title))
+	if focused {
+		b.WriteString(styles.HelpStyle.Render(" (enter edit · a add · space toggle · d delete)"))
+	}
+	b.WriteString("\n")
+	if len(t.Rows) == 0 {
+		b.WriteString(styles.HelpStyle.Render(t.empty) + "\n")
+		return b.String()
+	}
+
+	keyWidth := max(8, t.width/3)
+	restWidth := max(8, t.width-keyWidth-6)
+	end := min(len(t.Rows), t.offset+t.height)
+	for i := t.offset; i < end; i++ {
+		row := t.Rows[i]
+		marker, check := " ", "[ ]"
+		if row.Enabled {
+			check = "[x]"
+		}
+		if focused && i == t.cursor {
+			marker = titleStyle.Render("›")
+		}
+		rowStyle := lipgloss.NewStyle()
+		if !row.Enabled {
+			rowStyle = styles.HelpStyle
+		}
+
+		key := rowStyle.Render(truncate(row.Key, keyWidth))
+		value := truncate(row.Value, restWidth)
+		rest := rowStyle.Render(value)
+		if focused && i == t.cursor && !t.editing {
+			if t.col == 0 {
+				key = styles.SelectedCellStyle.Render(truncate(row.Key, keyWidth))
+			} else {
+				rest = styles.SelectedCellStyle.Render(value)
+			}
+		}
+		if t.editing && i == t.cursor {
+			if t.col == 0 {
+				key = t.input.View()
+			} else {
+				rest = t.input.View()
+			}
+		}
+		key += strings.Repeat(" ", max(0, keyWidth-lipgloss.Width(key)))
+
+		room := restWidth - lipgloss.Width(value) - 3
+		if problem := t.check(row); problem != "" && row.Enabled {
+			rest += styles.ErrorStyle.Render(" ! " + truncate(problem, max(1, room)))
+		} else if resolved, unknown := resolve(row.Value); resolved != row.Value && room > 3 {
+			style := styles.HelpStyle
+			if unknown {
+				style = styles.ErrorStyle
+			}
+			rest += style.Render(" → " + truncate(resolved, room))
+		}
+		fmt.Fprintf(&b, "%s%s %s %s\n", marker, rowStyle.Render(check), key, rest)
+	}
+	if hidden := len(t.Rows) - end + t.offset; hidden > 0 {
+		b.WriteString(styles.HelpStyle.Render(fmt.Sprintf("  %d/%d rows", t.cursor+1, len(t.Rows))) + "\n")
+	}
+	return b.String()
+}
+
+// queryRows splits the query string of rawURL into rows, in order.
+// Values are decoded; ones that are not valid escapes are kept as they are.
+func queryRows(rawURL string) []kvRow {
+	_, query, _ := splitQuery(rawURL)
+	var rows []kvRow
+	for _, pair := range strings.Split(query, "&") {
+		if pair == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(pair, "=")
+		rows = append(rows, kvRow{Key: unescapeQuery(k), Value: unescapeQuery(v), Enabled: true})
+	}
+	return rows
+}
+
+// withQuery replaces the query string of rawURL with the enabled rows.
+func withQuery(rawURL string, rows []kvRow) string {
+	base, _, fragment := splitQuery(rawURL)
+	var pairs []string
+	for _, r := range rows {
+		if !r.Enabled || r.Key == "" {
+			continue
+		}
+		if r.Value == "" {
+			pairs = append(pairs, escapeQuery(r.Key))
+		} else {
+			pairs = append(pairs, escapeQuery(r.Key)+"="+escapeQuery(r.Value))
+		}
+	}
+	if len(pairs) > 0 {
+		base += "?" + strings.Join(pairs, "&")
+	}
+	return base + fragment
+}
+
+// splitQuery splits rawURL into what comes before its query string, the
+// query string and the fragment, including its #.
+func splitQuery(rawURL string) (base, query, fragment string) {
+	if i := strings.Index(rawURL, "#"); i >= 0 {
+		rawURL, fragment = rawURL[:i], rawURL[i:]
+	}
+	base, query, _ = strings.Cut(rawURL, "?")
+	return base, query, fragment
+}
+
+func unescapeQuery(s string) string {
+	if u, err := url.QueryUnescape(s); err == nil {
+		return u
+	}
+	return s
+}
+
+// mergeParams returns the rows of the edited URL followed by the disabled
+// rows of the table, which the URL cannot hold.
+func mergeParams(fromURL, table []kvRow) []kvRow {
+	for _, r := range table {
+		if !r.Enabled {
+			fromURL = append(fromURL, r)
+		}
+	}
+	return fromURL
+}
+
+// headerRows reads the Headers text into rows. Disabled headers are
+// written as "# Name: value" lines.
+func headerRows(text string) []kvRow {
+	var rows []kvRow
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if h, ok := disabledHeader(line); ok {
+			rows = append(rows, kvRow{Key: h.Name, Value: h.Value})
+			continue
+		}
+		k, v, _ := strings.Cut(line, ":")
+		rows = append(rows, kvRow{
+			Key:     strings.Trim(strings.TrimSpace(k), `"`),
+			Value:   strings.Trim(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(v), ",")), `"`),
+			Enabled: true,
+		})
+	}
+	return rows
+}
+
+// headerText is the inverse of headerRows.
+func headerText(rows []kvRow) string {
+	var lines []string
+	for _, r := range rows {
+		if r.Key == "" {
+			continue
+		}
+		line := r.Key + ": " + r.Value
+		if !r.Enabled {
+			line = "# " + line
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// disabledHeader parses a "# Name: value" line, as opposed to a comment.
+func disabledHeader(line string) (headerField, bool) {
+	rest, ok := strings.CutPrefix(line, "#")
+	if !ok {
+		return headerField{}, false
+	}
+	k, v, ok := strings.Cut(strings.TrimSpace(rest), ":")
+	if !ok || !validHeaderName(k) {
+		return headerField{}, false
+	}
+	return headerField{Name: k, Value: strings.TrimSpace(v)}, true
+}
+
+// validHeaderName reports whether name is an HTTP token, once {{variable}}
+// references are taken out.
+func validHeaderName(name string) bool {
+	name = envRefName.ReplaceAllString(name, "x")
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r > 0x7e || r <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	return true
+}
+
+func checkHeaderRow(r kvRow) string {
+	switch {
+	case r.Key == "":
+		return "missing name"
+	case !validHeaderName(r.Key):
+		return "invalid header name"
+	}
+	return ""
+}
+
+func checkParamRow(r kvRow) string {
+	if r.Key == "" {
+		return "missing name"
+	}
+	return ""
+}
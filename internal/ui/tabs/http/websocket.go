@@ -0,0 +1,341 @@
+package http
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"phantom/internal/secrets"
+	"phantom/internal/ui/components/styles"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/gorilla/websocket"
+)
+
+// MethodWS is the pseudo-method of WebSocket connections. Its requests hold
+// saved messages instead of a body, see RequestItem.Messages.
+const MethodWS = "WS"
+
+const maxWSFrames = 1000
+
+// wsFrame is one entry of the message log of a connection.
+type wsFrame struct {
+	Time   time.Time
+	Dir    string // "sent", "received", or "info" for connection events
+	Text   string
+	Binary bool
+	Err    bool
+}
+
+// wsConn is an open WebSocket connection. Frames read from it are delivered
+// to the Model through frames, and the reason it closed through done.
+type wsConn struct {
+	URL    string
+	conn   *websocket.Conn
+	mu     sync.Mutex // gorilla/websocket allows one writer at a time
+	frames chan wsFrame
+	done   chan error
+}
+
+// WSConnectedMsg is sent when the handshake of a connection completes.
+type WSConnectedMsg struct {
+	Conn    *wsConn
+	Request RequestItem // the request as typed, before substitution
+	Code    int
+	Status  string
+	Headers string
+	Timing  Timing
+	Err     error
+}
+
+// WSFrameMsg carries frames sent or received on a connection.
+type WSFrameMsg struct {
+	Conn   *wsConn
+	Frames []wsFrame
+	read   bool // from wait, which must then be called again
+}
+
+// WSClosedMsg is sent once a connection is closed, by either side.
+type WSClosedMsg struct {
+	Conn *wsConn
+	Err  error
+}
+
+// dialWS opens a WebSocket connection for item. The handshake carries its
+// headers and the credentials of its Auth section.
+func dialWS(ctx context.Context, item RequestItem, env map[string]string) tea.Cmd {
+	return func() tea.Msg {
+		start := time.Now()
+		conn, resp, err := dialWSConn(ctx, item, env)
+		msg := WSConnectedMsg{Request: item, Timing: Timing{Total: time.Since(start)}}
+		if resp != nil {
+			msg.Code, msg.Status = resp.StatusCode, resp.Status
+			msg.Headers = FormatHeaders(resp.Proto, resp.Status, resp.Header)
+		}
+		if err != nil {
+			if resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+				err = fmt.Errorf("handshake failed: %s", resp.Status)
+			}
+			msg.Err = requestError(ctx, err, item.ConnectTimeout)
+			return msg
+		}
+		msg.Conn = conn
+		return msg
+	}
+}
+
+func dialWSConn(ctx context.Context, item RequestItem, env map[string]string) (*wsConn, *http.Response, error) {
+	if item.Timeout > 0 { // only the handshake: the connection itself stays open
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, item.Timeout, fmt.Errorf("%w after %s", ErrTimeout, item.Timeout))
+		defer cancel()
+	}
+	header, err := ParseHeaders(Substitute(item.Headers, env))
+	if err != nil {
+		return nil, nil, err
+	}
+	encodeBasicAuth(header)
+	auth, err := ParseAuth(item.Auth)
+	if err != nil {
+		return nil, nil, err
+	}
+	auth = auth.Substitute(env)
+	rawURL := wsURL(Substitute(item.URL, env))
+	switch auth.Kind {
+	case AuthDigest:
+		return nil, nil, errors.New("digest auth is not supported for WebSocket connections")
+	case AuthOAuth2:
+		token, err := oauth2Token(ctx, auth, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		header.Set("Authorization", "Bearer "+token)
+	default:
+		rawURL, _ = auth.apply(header, rawURL)
+	}
+	// The dialer sets these itself and refuses duplicates.
+	for _, h := range []string{"Upgrade", "Connection", "Sec-Websocket-Key", "Sec-Websocket-Version", "Sec-Websocket-Extensions"} {
+		header.Del(h)
+	}
+
+	dialer := *websocket.DefaultDialer
+	dialer.HandshakeTimeout = 0 // ctx has the timeout
+	if item.ConnectTimeout > 0 {
+		dialer.NetDialContext = transportFor(item.ConnectTimeout).(*http.Transport).DialContext
+	}
+	conn, resp, err := dialer.DialContext(ctx, rawURL, header)
+	if err != nil {
+		return nil, resp, err
+	}
+	c := &wsConn{URL: rawURL, conn: conn, frames: make(chan wsFrame, 256), done: make(chan error, 1)}
+	go c.read()
+	return c, resp, nil
+}
+
+// wsURL turns http(s) URLs into ws(s) ones, and adds ws:// to bare hosts.
+func wsURL(rawURL string) string {
+	switch {
+	case strings.HasPrefix(rawURL, "http://"):
+		return "ws://" + strings.TrimPrefix(rawURL, "http://")
+	case strings.HasPrefix(rawURL, "https://"):
+		return "wss://" + strings.TrimPrefix(rawURL, "https://")
+	case !strings.Contains(rawURL, "://"):
+		return "ws://" + rawURL
+	}
+	return rawURL
+}
+
+// read delivers incoming messages until the connection fails or closes.
+func (c *wsConn) read() {
+	for {
+		kind, data, err := c.conn.ReadMessage()
+		if err != nil {
+			close(c.frames)
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) && closeErr.Code == websocket.CloseNormalClosure {
+				err = nil
+			}
+			c.done <- err
+			return
+		}
+		f := wsFrame{Time: time.Now(), Dir: "received", Text: string(data)}
+		if kind == websocket.BinaryMessage {
+			f.Binary = true
+		}
+		c.frames <- f
+	}
+}
+
+// wait returns a command that delivers the next frames read from c, or its
+// WSClosedMsg once it is closed.
+func (c *wsConn) wait() tea.Cmd {
+	return func() tea.Msg {
+		f, ok := <-c.frames
+		if !ok {
+			return WSClosedMsg{Conn: c, Err: <-c.done}
+		}
+		msg := WSFrameMsg{Conn: c, Frames: []wsFrame{f}, read: true}
+		// Coalesce whatever else is already buffered to keep redraws down.
+		for len(msg.Frames) < 500 {
+			select {
+			case f, ok := <-c.frames:
+				if !ok {
+					return msg
+				}
+				msg.Frames = append(msg.Frames, f)
+			default:
+				return msg
+			}
+		}
+		return msg
+	}
+}
+
+// send writes a text message, reporting it as a sent frame.
+func (c *wsConn) send(text string) tea.Cmd {
+	return func() tea.Msg {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		f := wsFrame{Time: time.Now(), Dir: "sent", Text: text}
+		if err := c.conn.WriteMessage(websocket.TextMessage, []byte(text)); err != nil {
+			f = wsFrame{Time: time.Now(), Dir: "info", Text: "send failed: " + err.Error(), Err: true}
+		}
+		return WSFrameMsg{Conn: c, Frames: []wsFrame{f}}
+	}
+}
+
+// close starts the closing handshake. The read loop reports the outcome.
+func (c *wsConn) close() tea.Cmd {
+	return func() tea.Msg {
+		c.mu.Lock()
+		err := c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		c.mu.Unlock()
+		if err != nil {
+			c.conn.Close()
+			return nil
+		}
+		// Give the server a moment to answer before dropping the connection.
+		time.AfterFunc(2*time.Second, func() { c.conn.Close() })
+		return nil
+	}
+}
+
+// logWS appends frames to the message log, dropping the oldest ones past maxWSFrames.
+func (m *Model) logWS(frames ...wsFrame) {
+	m.wsLog = append(m.wsLog, frames...)
+	if over := len(m.wsLog) - maxWSFrames; over > 0 {
+		m.wsLog = m.wsLog[over:]
+	}
+	if m.isWS() {
+		m.Response.SetContent(m.renderWSLog())
+		m.Response.GotoBottom()
+	}
+}
+
+// isWS reports whether the editor holds a WebSocket request.
+func (m Model) isWS() bool {
+	return m.Methods[m.SelectedMethod] == MethodWS
+}
+
+// sendWS connects, or sends the Body as a message once connected.
+func (m *Model) sendWS() tea.Cmd {
+	if m.ws != nil {
+		text := m.substituteEnv(m.Body.Value())
+		if strings.TrimSpace(text) == "" {
+			m.Notice = styles.HelpStyle.Render("Nothing to send: type a message in the Body editor")
+			return nil
+		}
+		return m.ws.send(text)
+	}
+	m.commitTables()
+	item := m.currentRequest()
+	item.Timeout, item.ConnectTimeout = m.Loaded.Timeout, m.Loaded.ConnectTimeout
+	item = m.Timeouts.Apply(item)
+	ctx, cancel := context.WithCancelCause(context.Background())
+	m.Sending, m.cancel = true, cancel
+	m.LastError = ""
+	m.wsLog = nil
+	m.logWS(wsFrame{Time: time.Now(), Dir: "info", Text: "connecting to " + secrets.Mask(wsURL(m.substituteEnv(item.URL)))})
+	return tea.Batch(m.Spinner.Tick, dialWS(ctx, item, m.Environment))
+}
+
+// cycleMessage loads the next saved message of the request into the Body editor.
+func (m *Model) cycleMessage() {
+	msgs := m.Loaded.Messages
+	if len(msgs) == 0 {
+		m.Notice = styles.HelpStyle.Render("No saved messages for this request")
+		return
+	}
+	m.wsMessage = (m.wsMessage + 1) % len(msgs)
+	m.Body.SetValue(msgs[m.wsMessage])
+}
+
+// renderWSPane replaces the response pane for WS requests.
+func (m Model) renderWSPane() string {
+	pane := m.renderWSHeader() + "\n"
+	if m.Sending {
+		pane += fmt.Sprintf("%s Connecting... %s\n", m.Spinner.View(), styles.HelpStyle.Render("(Esc to cancel)"))
+	}
+	return pane + m.Response.View()
+}
+
+// renderWSHeader summarises the connection above the message log.
+func (m Model) renderWSHeader() string {
+	var sent, received int
+	for _, f := range m.wsLog {
+		switch f.Dir {
+		case "sent":
+			sent++
+		case "received":
+			received++
+		}
+	}
+	state := styles.HelpStyle.Render("not connected")
+	if m.ws != nil {
+		state = styles.SuccessStyle.Render("connected")
+	}
+	header := styles.ListHeaderStyle.Render(fmt.Sprintf("WebSocket - %s · ↑%d ↓%d", state, sent, received))
+	if badge := m.environmentBadge(); badge != "" {
+		header = lipgloss.JoinHorizontal(lipgloss.Top, header, badge)
+	}
+	return header
+}
+
+// renderWSLog draws the message log, one timestamped frame per entry.
+func (m Model) renderWSLog() string {
+	if len(m.wsLog) == 0 {
+		return styles.HelpStyle.Render("Ctrl+S opens the connection.")
+	}
+	var b strings.Builder
+	for _, f := range m.wsLog {
+		stamp := styles.HelpStyle.Render(f.Time.Format("15:04:05.000"))
+		text := secrets.Mask(f.Text)
+		if f.Binary {
+			n := min(len(f.Text), 32)
+			text = fmt.Sprintf("binary, %d bytes: %s", len(f.Text), hex.EncodeToString([]byte(f.Text[:n])))
+			if n < len(f.Text) {
+				text += "…"
+			}
+		}
+		text = strings.ReplaceAll(strings.TrimRight(text, "\n"), "\n", "\n               ")
+		switch f.Dir {
+		case "sent":
+			fmt.Fprintf(&b, "%s %s %s\n", stamp, styles.FocusedInputStyle.Render("↑"), text)
+		case "received":
+			fmt.Fprintf(&b, "%s %s %s\n", stamp, styles.SuccessStyle.Render("↓"), text)
+		default:
+			style := styles.HelpStyle
+			if f.Err {
+				style = styles.ErrorStyle
+			}
+			fmt.Fprintf(&b, "%s %s\n", stamp, style.Render("· "+text))
+		}
+	}
+	return b.String()
+}
@@ -99,11 +99,7 @@ func (m Model) Init() tea.Cmd {
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
-		m.Width, m.Height = msg.Width, msg.Height
-		m.clusters.SetSize(msg.Width-4, msg.Height-6)
-		m.descView.Width = msg.Width - 4
-		m.descView.Height = msg.Height - 6
-		m.textPrompt.Width = msg.Width - 8
+		m.SetSize(msg.Width, msg.Height)
 
 	case spinner.TickMsg:
 		if m.jobRunning {
@@ -147,7 +143,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.textPrompt, cmd = m.textPrompt.Update(msg)
 				return m, cmd
 			}
-		} else {
+		} else if !m.clusters.SettingFilter() {
 			switch msg.String() {
 			case "r": // refresh
 				m.jobRunning, m.jobTitle = true, "refreshing"
@@ -361,6 +357,22 @@ func runKindCmd(done tea.Msg, args ...string) tea.Cmd {
 		return opDoneMsg{err}
 	}
 }
+
+// Editing reports whether keys are typed into the prompt or the cluster
+// filter, so they must not be taken as global shortcuts.
+func (m Model) Editing() bool {
+	return m.promptAction != "" || m.clusters.SettingFilter()
+}
+
+// SetSize sets the size of the kind model.
+func (m *Model) SetSize(w, h int) {
+	m.Width, m.Height = w, h
+	m.clusters.SetSize(w-4, h-6)
+	m.descView.Width = w - 4
+	m.descView.Height = h - 6
+	m.textPrompt.Width = w - 8
+}
+
 func (m *Model) SetInstalled(installed bool) {
 	m.isInstalled = installed
 }
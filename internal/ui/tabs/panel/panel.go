@@ -0,0 +1,82 @@
+package panel
+
+import (
+	"time"
+
+	"phantom/internal/ui/components/styles"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Model represents a custom panel registered from config.lua.
+type Model struct {
+	Width, Height int
+	Name          string
+	Interval      time.Duration
+	Content       string
+	Err           error
+	render        func() (string, error)
+}
+
+// RefreshMsg asks the named panel to call its render function again.
+type RefreshMsg struct{ Name string }
+
+// ContentMsg carries the output of a panel's render function.
+type ContentMsg struct {
+	Name    string
+	Content string
+	Err     error
+}
+
+// New creates a new panel model that calls render every interval.
+func New(name string, interval time.Duration, render func() (string, error)) Model {
+	return Model{Name: name, Interval: interval, render: render}
+}
+
+// Init initializes the panel model.
+func (m Model) Init() tea.Cmd {
+	return m.refresh()
+}
+
+// Update handles messages for the panel model.
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case RefreshMsg:
+		if msg.Name == m.Name {
+			return m, m.refresh()
+		}
+	case ContentMsg:
+		if msg.Name == m.Name {
+			m.Content, m.Err = msg.Content, msg.Err
+			return m, m.tick()
+		}
+	}
+	return m, nil
+}
+
+// View renders the panel model.
+func (m Model) View() string {
+	if m.Err != nil {
+		return styles.ErrorStyle.Width(m.Width).Render(m.Err.Error())
+	}
+	return lipgloss.NewStyle().MaxWidth(m.Width).MaxHeight(m.Height).Render(m.Content)
+}
+
+// SetSize sets the size of the panel model.
+func (m *Model) SetSize(w, h int) {
+	m.Width, m.Height = w, h
+}
+
+func (m Model) refresh() tea.Cmd {
+	name, render := m.Name, m.render
+	return func() tea.Msg {
+		content, err := render()
+		return ContentMsg{Name: name, Content: content, Err: err}
+	}
+}
+
+func (m Model) tick() tea.Cmd {
+	name := m.Name
+	return tea.Tick(m.Interval, func(time.Time) tea.Msg { return RefreshMsg{Name: name} })
+}
@@ -0,0 +1,474 @@
+package tasks
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"phantom/internal/ui/components/styles"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	maxRunsPerTask = 20
+	maxOutputLines = 5000
+	maxLineLength  = 1024 * 1024 // longer output lines are cut
+)
+
+// Command is a named shell command from Config.commands.
+type Command struct {
+	Name, Command string
+}
+
+// Status is the state of a single task run.
+type Status int
+
+const (
+	Running Status = iota
+	Succeeded
+	Failed
+	Cancelled
+)
+
+func (s Status) String() string {
+	switch s {
+	case Running:
+		return "running"
+	case Succeeded:
+		return "ok"
+	case Failed:
+		return "failed"
+	case Cancelled:
+		return "cancelled"
+	}
+	return "unknown"
+}
+
+// Run is one execution of a task.
+type Run struct {
+	ID       int
+	Task     string
+	Command  string
+	Status   Status
+	ExitCode int
+	Err      error
+	Started  time.Time
+	Duration time.Duration
+	Output   []string
+}
+
+// Elapsed returns how long the run took, or has been running so far.
+func (r *Run) Elapsed() time.Duration {
+	if r.Status == Running {
+		return time.Since(r.Started)
+	}
+	return r.Duration
+}
+
+// OutputMsg carries lines of output from a running task.
+type OutputMsg struct {
+	ID    int
+	Lines []string
+}
+
+// DoneMsg is sent when a task run exits.
+type DoneMsg struct {
+	ID        int
+	ExitCode  int
+	Err       error
+	Duration  time.Duration
+	Cancelled bool
+}
+
+type taskItem struct {
+	Command
+	last *Run
+}
+
+func (i taskItem) Title() string { return i.Name }
+func (i taskItem) Description() string {
+	if i.last == nil {
+		return i.Command.Command
+	}
+	return fmt.Sprintf("%s · %s", i.last.Status, i.Command.Command)
+}
+func (i taskItem) FilterValue() string { return i.Name }
+
+// Model represents the task runner tab.
+type Model struct {
+	Width, Height int
+	Commands      []Command
+	Runs          []*Run // newest first
+	// UI widgets
+	tasks   list.Model
+	output  viewport.Model
+	spinner spinner.Model
+	// State
+	nextID  int
+	viewing int // index into the selected task's run history, 0 is the latest
+	cancels map[int]context.CancelFunc
+	streams map[int]stream
+}
+
+// New creates a new task runner model.
+func New() Model {
+	l := list.New(nil, list.NewDefaultDelegate(), 0, 0)
+	l.Title = "Tasks"
+	l.SetShowHelp(false)
+	return Model{
+		tasks:   l,
+		output:  viewport.New(0, 0),
+		spinner: spinner.New(spinner.WithSpinner(spinner.Dot), spinner.WithStyle(styles.SpinnerStyle)),
+		cancels: make(map[int]context.CancelFunc),
+		streams: make(map[int]stream),
+	}
+}
+
+// Init initializes the task runner model.
+func (m Model) Init() tea.Cmd {
+	return m.spinner.Tick
+}
+
+// SetCommands replaces the list of runnable commands.
+func (m *Model) SetCommands(commands []Command) {
+	m.Commands = commands
+	m.refreshItems()
+}
+
+// Update handles messages for the task runner model.
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if m.tasks.SettingFilter() { // keys are typed into the filter
+			return m.updateList(msg)
+		}
+		switch msg.String() {
+		case "enter", "r":
+			if item, ok := m.tasks.SelectedItem().(taskItem); ok {
+				return m, m.start(item.Command)
+			}
+		case "x":
+			if run := m.selectedRun(); run != nil && run.Status == Running {
+				m.cancels[run.ID]()
+			}
+			return m, nil
+		case "[":
+			if m.viewing < len(m.history(m.selectedTask()))-1 {
+				m.viewing++
+				m.refreshOutput(true)
+			}
+			return m, nil
+		case "]":
+			if m.viewing > 0 {
+				m.viewing--
+				m.refreshOutput(true)
+			}
+			return m, nil
+		case "pgup", "pgdown", "ctrl+u", "ctrl+d", "g", "G":
+			var cmd tea.Cmd
+			m.output, cmd = m.output.Update(msg)
+			return m, cmd
+		}
+		return m.updateList(msg)
+
+	case OutputMsg:
+		if run := m.run(msg.ID); run != nil {
+			run.Output = append(run.Output, msg.Lines...)
+			if over := len(run.Output) - maxOutputLines; over > 0 {
+				run.Output = run.Output[over:]
+			}
+			if run == m.selectedRun() {
+				m.refreshOutput(false)
+			}
+		}
+		return m, m.wait(msg.ID)
+
+	case DoneMsg:
+		delete(m.cancels, msg.ID)
+		delete(m.streams, msg.ID)
+		if run := m.run(msg.ID); run != nil {
+			run.ExitCode, run.Err, run.Duration = msg.ExitCode, msg.Err, msg.Duration
+			switch {
+			case msg.Cancelled:
+				run.Status = Cancelled
+			case msg.ExitCode == 0 && msg.Err == nil:
+				run.Status = Succeeded
+			default:
+				run.Status = Failed
+			}
+			m.refreshItems()
+			if run == m.selectedRun() {
+				m.refreshOutput(false)
+			}
+		}
+		return m, nil
+
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+// updateList passes msg to the task list, showing the latest run of the
+// task it selects.
+func (m Model) updateList(msg tea.KeyMsg) (Model, tea.Cmd) {
+	prev := m.tasks.Index()
+	var cmd tea.Cmd
+	m.tasks, cmd = m.tasks.Update(msg)
+	if m.tasks.Index() != prev {
+		m.viewing = 0
+		m.refreshOutput(true)
+	}
+	return m, cmd
+}
+
+// Editing reports whether keys are typed into the task filter, so they
+// must not be taken as global shortcuts.
+func (m Model) Editing() bool {
+	return m.tasks.SettingFilter()
+}
+
+// View renders the task runner model.
+func (m Model) View() string {
+	listWidth := m.Width / 4
+
+	var header string
+	if run := m.selectedRun(); run == nil {
+		header = styles.ListHeaderStyle.Render("Output")
+	} else {
+		header = styles.ListHeaderStyle.Render(fmt.Sprintf("%s · run %d/%d", run.Task, m.viewing+1, len(m.history(run.Task)))) + " " + m.renderStatus(run)
+	}
+
+	outputPane := lipgloss.JoinVertical(lipgloss.Left, header, m.output.View())
+	if m.selectedRun() == nil {
+		outputPane = lipgloss.JoinVertical(lipgloss.Left, header, styles.HelpStyle.Render("Not run yet. Press Enter to run."))
+	}
+
+	help := styles.HelpStyle.Render("enter/r:run  x:cancel  [/]:older/newer run  pgup/pgdown:scroll")
+	return lipgloss.JoinVertical(lipgloss.Left,
+		lipgloss.JoinHorizontal(lipgloss.Top,
+			lipgloss.NewStyle().Width(listWidth).Render(m.tasks.View()),
+			lipgloss.NewStyle().Width(m.Width-listWidth).Render(outputPane),
+		),
+		help,
+	)
+}
+
+// SetSize sets the size of the task runner model.
+func (m *Model) SetSize(w, h int) {
+	m.Width, m.Height = w, h
+	m.tasks.SetSize(w/4, h-1)
+	m.output.Width = w - w/4
+	m.output.Height = h - 3
+}
+
+func (m Model) renderStatus(run *Run) string {
+	elapsed := run.Elapsed().Round(time.Millisecond)
+	switch run.Status {
+	case Running:
+		return fmt.Sprintf("%s running %s", m.spinner.View(), elapsed.Round(time.Second/10))
+	case Succeeded:
+		return styles.SuccessStyle.Render(fmt.Sprintf("exit %d in %s", run.ExitCode, elapsed))
+	case Cancelled:
+		return styles.HelpStyle.Render(fmt.Sprintf("cancelled after %s", elapsed))
+	}
+	if run.Err != nil && run.ExitCode < 0 {
+		return styles.ErrorStyle.Render(run.Err.Error())
+	}
+	return styles.ErrorStyle.Render(fmt.Sprintf("exit %d in %s", run.ExitCode, elapsed))
+}
+
+func (m *Model) refreshItems() {
+	items := make([]list.Item, len(m.Commands))
+	for i, c := range m.Commands {
+		item := taskItem{Command: c}
+		if h := m.history(c.Name); len(h) > 0 {
+			item.last = h[0]
+		}
+		items[i] = item
+	}
+	m.tasks.SetItems(items)
+}
+
+// refreshOutput shows the selected run in the output viewport, following the
+// tail unless the user has scrolled up or reset is set.
+func (m *Model) refreshOutput(reset bool) {
+	run := m.selectedRun()
+	if run == nil {
+		m.output.SetContent("")
+		return
+	}
+	follow := reset || m.output.AtBottom()
+	m.output.SetContent(strings.Join(run.Output, "\n"))
+	if follow {
+		m.output.GotoBottom()
+	}
+}
+
+func (m Model) selectedTask() string {
+	if item, ok := m.tasks.SelectedItem().(taskItem); ok {
+		return item.Name
+	}
+	return ""
+}
+
+func (m Model) selectedRun() *Run {
+	h := m.history(m.selectedTask())
+	if m.viewing < len(h) {
+		return h[m.viewing]
+	}
+	return nil
+}
+
+// history returns the runs of the named task, newest first.
+func (m Model) history(task string) []*Run {
+	var runs []*Run
+	for _, r := range m.Runs {
+		if r.Task == task {
+			runs = append(runs, r)
+		}
+	}
+	return runs
+}
+
+func (m Model) run(id int) *Run {
+	for _, r := range m.Runs {
+		if r.ID == id {
+			return r
+		}
+	}
+	return nil
+}
+
+func (m *Model) start(c Command) tea.Cmd {
+	m.nextID++
+	run := &Run{ID: m.nextID, Task: c.Name, Command: c.Command, Status: Running, Started: time.Now()}
+	m.Runs = append([]*Run{run}, m.Runs...)
+	m.pruneHistory(c.Name)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancels[run.ID] = cancel
+	m.streams[run.ID] = execute(ctx, run.ID, c.Command)
+
+	m.viewing = 0
+	m.refreshItems()
+	m.refreshOutput(true)
+	return tea.Batch(m.wait(run.ID), m.spinner.Tick)
+}
+
+// pruneHistory drops the oldest finished runs of a task beyond maxRunsPerTask.
+func (m *Model) pruneHistory(task string) {
+	kept, count := m.Runs[:0], 0
+	for _, r := range m.Runs {
+		if r.Task == task {
+			count++
+			if count > maxRunsPerTask && r.Status != Running {
+				continue
+			}
+		}
+		kept = append(kept, r)
+	}
+	m.Runs = kept
+}
+
+// wait returns a command that delivers the next batch of output from a run,
+// or its DoneMsg once the output is exhausted.
+func (m Model) wait(id int) tea.Cmd {
+	st, ok := m.streams[id]
+	if !ok {
+		return nil
+	}
+	return func() tea.Msg {
+		line, ok := <-st.lines
+		if !ok {
+			return <-st.done
+		}
+		out := OutputMsg{ID: id, Lines: []string{line}}
+		// Coalesce whatever else is already buffered to keep redraws down.
+		for len(out.Lines) < 500 {
+			select {
+			case line, ok := <-st.lines:
+				if !ok {
+					return out
+				}
+				out.Lines = append(out.Lines, line)
+			default:
+				return out
+			}
+		}
+		return out
+	}
+}
+
+// stream carries the output lines of a run, then its DoneMsg.
+type stream struct {
+	lines chan string
+	done  chan DoneMsg
+}
+
+// execute runs command through the shell, streaming combined stdout and stderr.
+func execute(ctx context.Context, id int, command string) stream {
+	st := stream{lines: make(chan string, 256), done: make(chan DoneMsg, 1)}
+	go func() {
+		started := time.Now()
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.WaitDelay = time.Second // don't hang on grandchildren holding the pipe open
+		pr, pw := io.Pipe()
+		cmd.Stdout, cmd.Stderr = pw, pw
+
+		scanned := make(chan struct{})
+		go func() {
+			defer close(scanned)
+			r := bufio.NewReaderSize(pr, 64*1024)
+			var line []byte
+			cut := false
+			for {
+				chunk, more, err := r.ReadLine()
+				if err != nil {
+					break
+				}
+				if room := maxLineLength - len(line); len(chunk) > room {
+					chunk, cut = chunk[:room], true
+				}
+				line = append(line, chunk...)
+				if more {
+					continue
+				}
+				text := string(line)
+				if cut {
+					text += " … (line truncated)"
+				}
+				st.lines <- text
+				line, cut = line[:0], false
+			}
+		}()
+
+		err := cmd.Run()
+		pw.Close()
+		<-scanned
+		close(st.lines)
+
+		done := DoneMsg{ID: id, Duration: time.Since(started), Cancelled: ctx.Err() != nil}
+		var exitErr *exec.ExitError
+		switch {
+		case errors.As(err, &exitErr):
+			done.ExitCode = exitErr.ExitCode()
+		case err != nil:
+			done.ExitCode, done.Err = -1, err
+		}
+		st.done <- done
+	}()
+	return st
+}
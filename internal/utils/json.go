@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"encoding/json"
+	"strings"
+
+	"phantom/internal/ui/components/styles"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// JSONKind is the kind of a JSON token.
+type JSONKind int
+
+const (
+	JSONPunct JSONKind = iota // { } [ ] : ,
+	JSONKey
+	JSONString
+	JSONNumber
+	JSONBool
+	JSONNull
+)
+
+// ScanJSON calls emit with each token of input, in order and as written:
+// strings keep their escapes and numbers their digits. input must be valid
+// JSON, see json.Valid; anything else is split on a best-effort basis.
+func ScanJSON(input string, emit func(kind JSONKind, text string)) {
+	var open []byte // the brackets of the enclosing containers
+	key := false    // the next string is an object key
+	for i := 0; i < len(input); {
+		switch c := input[i]; c {
+		case ' ', '\t', '\n', '\r':
+			i++
+		case '{', '[':
+			open = append(open, c)
+			key = c == '{'
+			emit(JSONPunct, input[i:i+1])
+			i++
+		case '}', ']':
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+			key = false
+			emit(JSONPunct, input[i:i+1])
+			i++
+		case ',':
+			key = len(open) > 0 && open[len(open)-1] == '{'
+			emit(JSONPunct, ",")
+			i++
+		case ':':
+			key = false
+			emit(JSONPunct, ":")
+			i++
+		case '"':
+			j := i + 1
+			for j < len(input) && input[j] != '"' {
+				if input[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j = min(j+1, len(input))
+			kind := JSONString
+			if key {
+				kind = JSONKey
+			}
+			emit(kind, input[i:j])
+			i = j
+		case 't', 'f', 'n':
+			j := i + 1
+			for j < len(input) && input[j] >= 'a' && input[j] <= 'z' {
+				j++
+			}
+			kind := JSONBool
+			if c == 'n' {
+				kind = JSONNull
+			}
+			emit(kind, input[i:j])
+			i = j
+		default:
+			j := i + 1
+			for j < len(input) && strings.IndexByte("+-.0123456789eE", input[j]) >= 0 {
+				j++
+			}
+			emit(JSONNumber, input[i:j])
+			i = j
+		}
+	}
+}
+
+// jsonStyle is how tokens of kind are highlighted.
+func jsonStyle(kind JSONKind) lipgloss.Style {
+	switch kind {
+	case JSONKey:
+		return styles.JSONKeyStyle
+	case JSONString:
+		return styles.JSONStringStyle
+	case JSONNumber:
+		return styles.JSONNumberStyle
+	case JSONBool:
+		return styles.JSONBoolStyle
+	case JSONNull:
+		return styles.JSONNullStyle
+	}
+	return lipgloss.NewStyle()
+}
+
+// HighlightJSON colors a token of kind.
+func HighlightJSON(kind JSONKind, text string) string {
+	if kind == JSONPunct {
+		return text
+	}
+	return jsonStyle(kind).Render(text)
+}
+
+// PrettyPrintJSON formats and colorizes a JSON string, keeping its key
+// order and numbers as written. Anything that is not valid JSON is
+// returned as is.
+func PrettyPrintJSON(input string) string {
+	if !json.Valid([]byte(input)) {
+		return input
+	}
+	// Rendering every token through lipgloss is slow on large bodies, so
+	// the escape sequences of each style are worked out once.
+	var colors [JSONNull + 1][2]string
+	for kind := JSONKey; kind <= JSONNull; kind++ {
+		colors[kind][0], colors[kind][1], _ = strings.Cut(jsonStyle(kind).Render("\x00"), "\x00")
+	}
+
+	var b strings.Builder
+	b.Grow(len(input) + len(input)/2)
+	depth := 0
+	pending := false // a line break is due before the next token, unless it closes an empty container
+	newline := func() {
+		b.WriteByte('\n')
+		for range depth {
+			b.WriteString("  ")
+		}
+	}
+	ScanJSON(input, func(kind JSONKind, text string) {
+		if kind != JSONPunct {
+			if pending {
+				newline()
+				pending = false
+			}
+			b.WriteString(colors[kind][0] + text + colors[kind][1])
+			return
+		}
+		switch text {
+		case "{", "[":
+			if pending {
+				newline()
+			}
+			b.WriteString(text)
+			depth++
+			pending = true
+		case "}", "]":
+			depth--
+			if !pending {
+				newline()
+			}
+			pending = false
+			b.WriteString(text)
+		case ",":
+			b.WriteString(",")
+			newline()
+		case ":":
+			b.WriteString(": ")
+		}
+	})
+	return b.String()
+}
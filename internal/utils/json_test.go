@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestScanJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string // tokens as kind:text, space separated
+	}{
+		{"scalars", `[1, -2.5e+10, true, false, null, "s"]`,
+			`p:[ n:1 p:, n:-2.5e+10 p:, b:true p:, b:false p:, 0:null p:, s:"s" p:]`},
+		{"keys and values", `{"a": "b", "c": {"d": "e"}}`,
+			`p:{ k:"a" p:: s:"b" p:, k:"c" p:: p:{ k:"d" p:: s:"e" p:} p:}`},
+		{"escaped quotes", `{"a\"b": "c\\", "d": "\"\\\""}`,
+			`p:{ k:"a\"b" p:: s:"c\\" p:, k:"d" p:: s:"\"\\\"" p:}`},
+		{"separators in strings", `{"a": ": ,{}[]", "b": 1}`,
+			`p:{ k:"a" p:: s:": ,{}[]" p:, k:"b" p:: n:1 p:}`},
+		{"keys after arrays", `{"a": [1, {"b": 2}], "c": [[]]}`,
+			`p:{ k:"a" p:: p:[ n:1 p:, p:{ k:"b" p:: n:2 p:} p:] p:, k:"c" p:: p:[ p:[ p:] p:] p:}`},
+		{"strings in arrays of objects", `[{"a": 1}, "b"]`,
+			`p:[ p:{ k:"a" p:: n:1 p:} p:, s:"b" p:]`},
+		{"number precision", `[12345678901234567890, 0.10000000000000000001, 1E400]`,
+			`p:[ n:12345678901234567890 p:, n:0.10000000000000000001 p:, n:1E400 p:]`},
+		{"unicode", `{"ключ": "é é 🙂"}`,
+			`p:{ k:"ключ" p:: s:"é é 🙂" p:}`},
+		{"whitespace", " \r\n\t{ }\n", `p:{ p:}`},
+		{"unterminated string", `["abc`, `p:[ s:"abc`},
+		{"trailing backslash", `["abc\`, `p:[ s:"abc\`},
+	}
+	kinds := map[JSONKind]string{JSONPunct: "p", JSONKey: "k", JSONString: "s", JSONNumber: "n", JSONBool: "b", JSONNull: "0"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tokens []string
+			ScanJSON(tt.in, func(kind JSONKind, text string) {
+				tokens = append(tokens, fmt.Sprintf("%s:%s", kinds[kind], text))
+			})
+			if got := strings.Join(tokens, " "); got != tt.want {
+				t.Errorf("ScanJSON(%q) =\n%s\nwant\n%s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+var ansi = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func TestPrettyPrintJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"object", `{"b":1,"a":[true,null]}`, "{\n  \"b\": 1,\n  \"a\": [\n    true,\n    null\n  ]\n}"},
+		{"empty containers", `{"a":{},"b":[],"c":[{}]}`, "{\n  \"a\": {},\n  \"b\": [],\n  \"c\": [\n    {}\n  ]\n}"},
+		{"empty root", `[]`, "[]"},
+		{"scalar root", ` "x" `, `"x"`},
+		{"strings kept as written", `{"a\"b":"\u00e9: ,{}"}`, "{\n  \"a\\\"b\": \"\\u00e9: ,{}\"\n}"},
+		{"number precision", `[1.10, 12345678901234567890, 1e-7]`, "[\n  1.10,\n  12345678901234567890,\n  1e-7\n]"},
+		{"already indented", "{\n    \"a\": 1\n}", "{\n  \"a\": 1\n}"},
+		{"invalid", `{"a": 1,}`, `{"a": 1,}`},
+		{"not json", "plain text", "plain text"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ansi.ReplaceAllString(PrettyPrintJSON(tt.in), ""); got != tt.want {
+				t.Errorf("PrettyPrintJSON(%q) =\n%s\nwant\n%s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
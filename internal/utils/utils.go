@@ -1,11 +1,11 @@
 package utils
 
 import (
-	"encoding/json"
 	"fmt"
-	"regexp"
+	"os"
+	"strings"
 
-	"phantom/internal/ui/components/styles" // Corrected import path
+	"github.com/aymanbagabas/go-osc52/v2"
 )
 
 // FormatBytes converts bytes to a human-readable string.
@@ -22,19 +22,15 @@ func FormatBytes(b uint64) string {
 	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
 }
 
-// PrettyPrintJSON formats and colorizes a JSON string.
-func PrettyPrintJSON(input string) string {
-	var data interface{}
-	if err := json.Unmarshal([]byte(input), &data); err != nil {
-		return input // Not valid JSON, return as is
+// CopyToClipboard copies text to the system clipboard with an OSC52 escape
+// sequence, which also works over SSH and inside tmux or screen.
+func CopyToClipboard(text string) error {
+	seq := osc52.New(text)
+	if os.Getenv("TMUX") != "" {
+		seq = seq.Tmux()
+	} else if strings.HasPrefix(os.Getenv("TERM"), "screen") {
+		seq = seq.Screen()
 	}
-	pretty, _ := json.MarshalIndent(data, "", "  ")
-
-	s := string(pretty)
-	s = regexp.MustCompile(`"([^"]+)":`).ReplaceAllString(s, styles.JSONKeyStyle.Render(`"$1"`)+":")
-	s = regexp.MustCompile(`: "([^"]*)"`).ReplaceAllString(s, `: `+styles.JSONStringStyle.Render(`"$1"`))
-	s = regexp.MustCompile(`: ([0-9\.]+)`).ReplaceAllString(s, `: `+styles.JSONNumberStyle.Render(`$1`))
-	s = regexp.MustCompile(`: (true|false)`).ReplaceAllString(s, `: `+styles.JSONBoolStyle.Render(`$1`))
-	s = regexp.MustCompile(`: null`).ReplaceAllString(s, `: `+styles.JSONNullStyle.Render(`null`))
-	return s
+	_, err := seq.WriteTo(os.Stderr)
+	return err
 }